@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -11,34 +12,116 @@ import (
 	"time"
 
 	"github.com/raffaelramalhorosa/rss-aggregator/internal/api"
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/audit"
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/config"
 	"github.com/raffaelramalhorosa/rss-aggregator/internal/fetcher"
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
 	"github.com/raffaelramalhorosa/rss-aggregator/internal/store"
 )
 
+// Build details, injected at build time with
+//
+//	go build -ldflags "-X main.Version=v1.2.0 -X main.Commit=abc123 -X main.BuildTime=2024-01-01T00:00:00Z"
+//
+// Unset values are reported as "dev" or "unknown".
+var Version, Commit, BuildTime string
+
 func main() {
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	var level slog.LevelVar
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: &level}))
 
 	// --- Configuration ---
-	port := envOrDefault("PORT", "8080")
-	fetchInterval := 5 * time.Minute
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	level.Set(cfg.LogLevel)
 
 	// --- Dependencies ---
-	st := store.New()
-	fetch := fetcher.New(st, fetchInterval, logger)
-	srv := api.New(st, logger)
+	auditLog, auditCloser, err := audit.Open(cfg.AuditLog)
+	if err != nil {
+		logger.Error("cannot open audit log", "dest", cfg.AuditLog, "error", err)
+		os.Exit(1)
+	}
+	defer auditCloser.Close()
+
+	st := store.New(
+		store.WithLogger(logger),
+		store.WithHistorySize(cfg.FetchHistorySize),
+		store.WithTitleDedup(cfg.TitleDedupWindow),
+		store.WithMaxArticlesPerFeed(cfg.MaxArticlesPerFeed),
+		store.WithMaxFeeds(cfg.MaxFeeds),
+		store.WithArticleUpdates(cfg.UpdateArticles),
+		store.WithPruneBatchSize(cfg.PruneBatchSize),
+	)
+	fetchOpts := []fetcher.Option{
+		fetcher.WithMaxArticlesPerFetch(cfg.MaxArticlesPerFetch),
+		fetcher.WithMaxTextLengths(cfg.MaxTitleLength, cfg.MaxDescriptionLength),
+		fetcher.WithStartupSpread(cfg.StartupSpread),
+		fetcher.WithConcurrency(cfg.FetchConcurrency),
+		fetcher.WithStaleFeedBackoff(cfg.StaleFeedAfter, cfg.StaleFeedInterval),
+		fetcher.WithSlowFetchWarning(cfg.SlowFetchThreshold),
+		fetcher.WithMaxFeedBytes(int64(cfg.MaxFeedBytes)),
+		fetcher.WithCompactInterval(cfg.CompactInterval),
+		fetcher.WithDiscoveryPaths(cfg.DiscoveryPaths),
+		fetcher.WithTransportSettings(fetcher.TransportSettings{
+			MaxIdleConnsPerHost: cfg.FetchMaxIdleConnsPerHost,
+			MaxConnsPerHost:     cfg.FetchMaxConnsPerHost,
+			IdleConnTimeout:     cfg.FetchIdleConnTimeout,
+		}),
+	}
+	if cfg.FetchProxy != nil {
+		fetchOpts = append(fetchOpts, fetcher.WithProxy(cfg.FetchProxy))
+	}
+	if cfg.AllowFileFeeds {
+		fetchOpts = append(fetchOpts, fetcher.WithFileFeeds())
+	}
+	if cfg.FollowFeedMoves {
+		fetchOpts = append(fetchOpts, fetcher.WithPermanentRedirectUpdates())
+	}
+	if cfg.BatchSaves {
+		fetchOpts = append(fetchOpts, fetcher.WithBatchedSaves())
+	}
+	if len(cfg.DiscoveryPaths) > fetcher.MaxDiscoveryPaths {
+		logger.Warn("too many discovery paths, extra ones ignored", "paths", len(cfg.DiscoveryPaths), "max", fetcher.MaxDiscoveryPaths)
+	}
+	fetch := fetcher.New(st, cfg.FetchInterval, logger, fetchOpts...)
+	srv := api.New(st, fetch, logger,
+		api.WithArticleLimits(cfg.DefaultArticleLimit, cfg.MaxArticleLimit),
+		api.WithAuditLogger(auditLog),
+		api.WithTrendingWindow(cfg.TrendingWindow),
+		api.WithMaxBodyBytes(int64(cfg.MaxBodyBytes)),
+		api.WithIdempotencyTTL(cfg.IdempotencyTTL),
+		api.WithArticleCacheTTL(cfg.ArticleCacheTTL),
+		api.WithTrustedProxies(cfg.TrustedProxies),
+		api.WithMaintenanceToken(cfg.MaintenanceToken),
+		api.WithBuildInfo(models.BuildInfo{Version: Version, Commit: Commit, BuildTime: BuildTime}),
+	)
 
 	// --- Seed some default feeds (optional, remove for production) ---
 	seedFeeds(st)
 
+	if cfg.StartupCheck {
+		if err := startupCheck(context.Background(), fetch, cfg.StartupCheckMaxFailed, logger); err != nil {
+			logger.Error("startup check failed", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	// --- Background fetcher ---
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go fetch.Start(ctx)
+	fetchDone := make(chan struct{})
+	go func() {
+		defer close(fetchDone)
+		fetch.Start(ctx)
+	}()
 
 	// --- HTTP server ---
 	httpServer := &http.Server{
-		Addr:         ":" + port,
+		Addr:         ":" + cfg.Port,
 		Handler:      srv,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
@@ -46,7 +129,7 @@ func main() {
 	}
 
 	go func() {
-		logger.Info("server started", "port", port)
+		logger.Info("server started", "port", cfg.Port)
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Error("server error", "error", err)
 			os.Exit(1)
@@ -62,16 +145,43 @@ func main() {
 
 	cancel() // stop the fetcher
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer shutdownCancel()
-
-	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+	if err := shutdown(httpServer, fetchDone, cfg.ShutdownTimeout); err != nil {
 		logger.Error("shutdown error", "error", err)
 	}
 
 	logger.Info("server stopped")
 }
 
+// shutdown drains in-flight HTTP requests and waits for the fetcher to
+// finish its current cycle. timeout bounds both steps together.
+func shutdown(srv *http.Server, fetchDone <-chan struct{}, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+
+	select {
+	case <-fetchDone:
+	case <-ctx.Done():
+		err = errors.Join(err, fmt.Errorf("fetcher did not stop: %w", ctx.Err()))
+	}
+	return err
+}
+
+// startupCheck fetches every feed once and fails if more than maxFailed
+// (a fraction from 0 to 1) of them could not be fetched or parsed.
+func startupCheck(ctx context.Context, fetch *fetcher.Fetcher, maxFailed float64, logger *slog.Logger) error {
+	ok, failed, err := fetch.SelfCheck(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Info("startup check complete", "ok", ok, "failed", failed)
+	if total := ok + failed; total > 0 && float64(failed)/float64(total) > maxFailed {
+		return fmt.Errorf("%d of %d feeds failed, above the allowed %.0f%%", failed, total, maxFailed*100)
+	}
+	return nil
+}
+
 func seedFeeds(s *store.Store) {
 	defaults := []struct{ name, url string }{
 		{"Go Blog", "https://go.dev/blog/feed.atom"},
@@ -83,13 +193,6 @@ func seedFeeds(s *store.Store) {
 	}
 }
 
-func envOrDefault(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
-	}
-	return fallback
-}
-
 func init() {
 	fmt.Println(`
   ____  ____ ____     _                                _
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/fetcher"
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/store"
+)
+
+func TestShutdownRespectsTimeout(t *testing.T) {
+	inFlight := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(inFlight)
+		<-release // deliberately slow handler
+	})}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	go http.Get("http://" + ln.Addr().String())
+	<-inFlight
+
+	fetchDone := make(chan struct{})
+	close(fetchDone)
+
+	start := time.Now()
+	err = shutdown(srv, fetchDone, 100*time.Millisecond)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if elapsed > time.Second {
+		t.Fatalf("shutdown took %v, ignoring the 100ms timeout", elapsed)
+	}
+}
+
+func TestShutdownWaitsForFetcher(t *testing.T) {
+	srv := &http.Server{}
+
+	fetchDone := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(fetchDone)
+	}()
+
+	if err := shutdown(srv, fetchDone, time.Second); err != nil {
+		t.Fatalf("expected clean shutdown, got %v", err)
+	}
+
+	select {
+	case <-fetchDone:
+	default:
+		t.Fatal("shutdown returned before the fetcher finished")
+	}
+}
+
+func TestStartupCheckThreshold(t *testing.T) {
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, `<rss version="2.0"><channel><title>Up</title></channel></rss>`)
+	}))
+	defer good.Close()
+	bad := httptest.NewServer(http.NotFoundHandler())
+	defer bad.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	st := store.New()
+	st.AddFeed("Up", good.URL)
+	st.AddFeed("Down", bad.URL)
+	fetch := fetcher.New(st, time.Minute, logger)
+
+	if err := startupCheck(context.Background(), fetch, 0.5, logger); err != nil {
+		t.Fatalf("expected half the feeds failing to be allowed at 0.5, got %v", err)
+	}
+	if err := startupCheck(context.Background(), fetch, 0.25, logger); err == nil {
+		t.Fatal("expected startup check to fail above 0.25")
+	}
+}
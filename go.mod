@@ -2,7 +2,10 @@ module github.com/raffaelramalhorosa/rss-aggregator
 
 go 1.23
 
-require github.com/mmcdole/gofeed v1.3.0
+require (
+	github.com/mmcdole/gofeed v1.3.0
+	golang.org/x/net v0.33.0
+)
 
 require (
 	github.com/PuerkitoBio/goquery v1.10.1 // indirect
@@ -11,6 +14,5 @@ require (
 	github.com/mmcdole/goxpp v1.1.1 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
-	golang.org/x/net v0.33.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 )
@@ -1,113 +1,1344 @@
 package api
 
 import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/audit"
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/fetcher"
 	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/opml"
 	"github.com/raffaelramalhorosa/rss-aggregator/internal/store"
 )
 
 // Server holds dependencies for the HTTP handlers.
 type Server struct {
-	store  *store.Store
-	logger *slog.Logger
-	mux    *http.ServeMux
+	store   *store.Store
+	fetcher *fetcher.Fetcher
+	logger  *slog.Logger
+	audit   *audit.Logger
+	mux     *http.ServeMux
+
+	// allowMux matches a request path to its route pattern regardless of
+	// method; allowed lists the methods registered for each pattern.
+	allowMux *http.ServeMux
+	allowed  map[string][]string
+
+	defaultLimit   int
+	maxLimit       int
+	trendingWindow time.Duration
+
+	maxBodyBytes int64
+
+	build   models.BuildInfo
+	started time.Time
+
+	trustedProxies []netip.Prefix // peers whose X-Forwarded-For is believed
+
+	idempotency idempotencyCache
+
+	articleCache articleCache // off unless WithArticleCacheTTL is set
+
+	maintenanceToken string // required by /api/maintenance; "" disables them
+}
+
+// Option configures optional Server behaviour.
+type Option func(*Server)
+
+// WithArticleLimits sets the page size used when a client sends no limit and
+// the cap that larger requested limits are clamped to.
+func WithArticleLimits(defaultLimit, maxLimit int) Option {
+	return func(s *Server) {
+		s.defaultLimit = defaultLimit
+		s.maxLimit = maxLimit
+	}
+}
+
+// WithTrendingWindow sets how far back /api/articles/trending looks when
+// the client sends no window.
+func WithTrendingWindow(d time.Duration) Option {
+	return func(s *Server) {
+		if d > 0 {
+			s.trendingWindow = d
+		}
+	}
+}
+
+// WithMaxBodyBytes caps the size of request bodies; larger ones are
+// rejected with 413.
+func WithMaxBodyBytes(n int64) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxBodyBytes = n
+		}
+	}
+}
+
+// WithAuditLogger records feed mutations to a. Without it the audit trail
+// is discarded.
+func WithAuditLogger(a *audit.Logger) Option {
+	return func(s *Server) {
+		s.audit = a
+	}
+}
+
+// WithBuildInfo sets the build details reported by /api/version. Empty
+// fields keep their defaults.
+func WithBuildInfo(info models.BuildInfo) Option {
+	return func(s *Server) {
+		if info.Version != "" {
+			s.build.Version = info.Version
+		}
+		if info.Commit != "" {
+			s.build.Commit = info.Commit
+		}
+		if info.BuildTime != "" {
+			s.build.BuildTime = info.BuildTime
+		}
+	}
 }
 
 // New wires up routes and returns a ready-to-use Server.
-func New(s *store.Store, logger *slog.Logger) *Server {
-	srv := &Server{store: s, logger: logger, mux: http.NewServeMux()}
+func New(s *store.Store, f *fetcher.Fetcher, logger *slog.Logger, opts ...Option) *Server {
+	srv := &Server{
+		store:          s,
+		fetcher:        f,
+		logger:         logger,
+		audit:          audit.New(io.Discard),
+		mux:            http.NewServeMux(),
+		allowMux:       http.NewServeMux(),
+		allowed:        make(map[string][]string),
+		defaultLimit:   50,
+		maxLimit:       500,
+		trendingWindow: 6 * time.Hour,
+		maxBodyBytes:   1 << 20,
+		build:          models.BuildInfo{Version: "dev", Commit: "unknown", BuildTime: "unknown"},
+		started:        time.Now(),
+		idempotency:    idempotencyCache{ttl: 24 * time.Hour, entries: make(map[string]*idempotentResponse)},
+		articleCache:   articleCache{order: list.New(), entries: make(map[string]*list.Element)},
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
 	srv.routes()
 	return srv
 }
 
 // ServeHTTP makes Server satisfy the http.Handler interface
 // and adds CORS headers so the frontend can call the API.
+// Every request is tagged with an X-Request-ID, reusing the client's if sent.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get("X-Request-ID")
+	if id == "" {
+		id = newRequestID()
+	}
+	w.Header().Set("X-Request-ID", id)
+	ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+	r = r.WithContext(context.WithValue(ctx, clientIPKey{}, s.clientIP(r)))
+
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Idempotency-Key, Authorization")
 
 	if r.Method == http.MethodOptions {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	}
+
+	if h, pattern := s.allowMux.Handler(r); pattern != "" && !s.allows(pattern, r.Method) {
+		h.ServeHTTP(w, r)
+		return
+	}
+
 	s.mux.ServeHTTP(w, r)
 }
 
 // ---------- Routes ----------
 
 func (s *Server) routes() {
-	s.mux.HandleFunc("GET /api/health", s.handleHealth)
+	s.handle(http.MethodGet, "/api/health", s.handleLive) // alias kept for existing probes
+	s.handle(http.MethodGet, "/api/health/live", s.handleLive)
+	s.handle(http.MethodGet, "/api/health/ready", s.handleReady)
+	s.handle(http.MethodGet, "/api/version", s.handleVersion)
+	s.handle(http.MethodGet, "/api/metrics", s.handleMetrics)
+	s.handle(http.MethodGet, "/api/stats/dedup", s.handleDedupStats)
+	s.handle(http.MethodPatch, "/api/config/fetch-interval", s.handleSetFetchInterval)
+	s.handle(http.MethodPost, "/api/maintenance/prune", s.maintenance(s.handlePrune))
+
+	s.handle(http.MethodGet, "/api/feeds", s.handleListFeeds)
+	s.handle(http.MethodPost, "/api/feeds", s.idempotent(s.handleAddFeed))
+	s.handle(http.MethodPost, "/api/feeds/batch", s.handleBatchAddFeeds)
+	s.handle(http.MethodPost, "/api/feeds/batch-delete", s.handleBatchDeleteFeeds)
+	s.handle(http.MethodPost, "/api/feeds/merge", s.handleMergeFeeds)
+	s.handle(http.MethodPost, "/api/feeds/validate", s.handleValidateFeed)
+	s.handle(http.MethodPost, "/api/feeds/discover", s.handleDiscoverFeed)
+	s.handle(http.MethodPost, "/api/feeds/import", s.handleImportOPML)
+	s.handle(http.MethodPatch, "/api/feeds/priorities", s.handleSetPriorities)
+	s.handle(http.MethodPatch, "/api/feeds/{id}", s.handleUpdateFeed)
+	s.handle(http.MethodDelete, "/api/feeds/{id}", s.handleRemoveFeed)
+	s.handle(http.MethodGet, "/api/feeds/{id}/articles", s.handleFeedArticles)
+	s.handle(http.MethodGet, "/api/feeds/{id}/history", s.handleFeedHistory)
+	s.handle(http.MethodGet, "/api/feeds/{id}/raw", s.handleFeedRaw)
+	s.handle(http.MethodPost, "/api/feeds/{id}/enable", s.handleSetFeedEnabled(true))
+	s.handle(http.MethodPost, "/api/feeds/{id}/disable", s.handleSetFeedEnabled(false))
 
-	s.mux.HandleFunc("GET /api/feeds", s.handleListFeeds)
-	s.mux.HandleFunc("POST /api/feeds", s.handleAddFeed)
-	s.mux.HandleFunc("DELETE /api/feeds/{id}", s.handleRemoveFeed)
+	s.handle(http.MethodGet, "/api/folders", s.handleListFolders)
 
-	s.mux.HandleFunc("GET /api/articles", s.handleListArticles)
+	s.handle(http.MethodGet, "/api/articles", s.cachedArticles(s.handleListArticles))
+	s.handle(http.MethodDelete, "/api/articles", s.handleClearArticles)
+	s.handle(http.MethodGet, "/api/articles/trending", s.handleTrending)
+	s.handle(http.MethodGet, "/api/articles/new-last-cycle", s.handleLastCycleArticles)
+	s.handle(http.MethodPatch, "/api/articles/{id}", s.handleUpdateArticle)
+	s.handle(http.MethodDelete, "/api/articles/{id}", s.handleDeleteArticle)
+	s.handle(http.MethodPost, "/api/articles/{id}/save-later", s.handleSaveLater(true))
+	s.handle(http.MethodDelete, "/api/articles/{id}/save-later", s.handleSaveLater(false))
+
+	s.handle(http.MethodGet, "/api/blocklist", s.handleListBlocklist)
+	s.handle(http.MethodPost, "/api/blocklist", s.handleBlock)
+	s.handle(http.MethodDelete, "/api/blocklist/{pattern}", s.handleUnblock)
+
+	s.handle(http.MethodGet, "/api/fetch/last", s.handleLastCycle)
+	s.handle(http.MethodGet, "/api/fetch/status", s.handleFetchStatus)
 
 	// Serve the frontend from the static directory.
 	s.mux.Handle("GET /", http.FileServer(http.Dir("static")))
 }
 
+// handle registers h for method on path and records the method so that
+// other methods on the same path get a 405 with an accurate Allow header.
+func (s *Server) handle(method, path string, h http.HandlerFunc) {
+	s.mux.HandleFunc(method+" "+path, h)
+
+	if _, ok := s.allowed[path]; !ok {
+		s.allowMux.HandleFunc(path, s.methodNotAllowed(path))
+	}
+	s.allowed[path] = append(s.allowed[path], method)
+}
+
+// allows reports whether method is registered for pattern. HEAD is served
+// wherever GET is.
+func (s *Server) allows(pattern, method string) bool {
+	for _, m := range s.allowed[pattern] {
+		if m == method || (m == http.MethodGet && method == http.MethodHead) {
+			return true
+		}
+	}
+	return false
+}
+
+// methodNotAllowed answers requests to path that use an unregistered method.
+func (s *Server) methodNotAllowed(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		methods := slices.Clone(s.allowed[path])
+		if slices.Contains(methods, http.MethodGet) {
+			methods = append(methods, http.MethodHead)
+		}
+		slices.Sort(methods)
+
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
 // ---------- Handlers ----------
 
-func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+// handleLive reports that the process is up and serving HTTP.
+func (s *Server) handleLive(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
-func (s *Server) handleListFeeds(w http.ResponseWriter, _ *http.Request) {
-	feeds := s.store.ListFeeds()
-	writeJSON(w, http.StatusOK, feeds)
+// handleReady reports whether the server's dependencies can serve requests.
+func (s *Server) handleReady(w http.ResponseWriter, _ *http.Request) {
+	if err := s.store.Ready(); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.build)
+}
+
+// handleMetrics reports basic counters as JSON, for setups without a
+// Prometheus scraper.
+func (s *Server) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	m := models.Metrics{
+		UptimeSeconds: int64(time.Since(s.started).Seconds()),
+		FetchCycles:   s.fetcher.Cycles(),
+		SlowFetches:   s.fetcher.SlowFetches(),
+		Feeds:         s.store.FeedCount(),
+		Articles:      s.store.ArticleCount(),
+	}
+	if last, ok := s.store.LastCycle(); ok {
+		m.LastCycleAt = &last.StartedAt
+		m.LastCycleNewArticles = last.NewArticles
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// handleDedupStats reports how effective article deduplication has been.
+func (s *Server) handleDedupStats(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.store.DedupStats())
 }
 
+// handleSetFetchInterval changes how often the running fetcher polls.
+func (s *Server) handleSetFetchInterval(w http.ResponseWriter, r *http.Request) {
+	var req models.FetchIntervalRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	d, err := time.ParseDuration(req.Interval)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid duration %q", req.Interval)})
+		return
+	}
+	if err := s.fetcher.SetInterval(d); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("fetch interval updated", "interval", d)
+	writeJSON(w, http.StatusOK, models.FetchIntervalRequest{Interval: d.String()})
+}
+
+// handleListFeeds lists feeds sorted by name, newest first with
+// sort=created_desc, or least healthy first with sort=health_asc. Without a
+// limit every feed is returned; envelope=true wraps the page in pagination
+// metadata as for articles.
+func (s *Server) handleListFeeds(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pageParams(r, 0, s.maxLimit)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	query := store.FeedQuery{Limit: limit, Offset: offset, NeverFetched: r.URL.Query().Get("never_fetched") == "true"}
+	switch sort := r.URL.Query().Get("sort"); sort {
+	case "", store.FeedSortName, store.FeedSortCreatedDesc, store.FeedSortHealthAsc:
+		query.Sort = sort
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unsupported sort %q", sort)})
+		return
+	}
+
+	feeds, total := s.store.ListFeedsPage(query)
+	for i := range feeds {
+		feeds[i] = redactFeed(feeds[i])
+	}
+
+	if r.URL.Query().Get("envelope") == "true" {
+		writeResponse(w, r, http.StatusOK, models.FeedPage{
+			Data:    feeds,
+			Total:   total,
+			Limit:   limit,
+			Offset:  offset,
+			HasMore: offset+len(feeds) < total,
+		})
+		return
+	}
+	writeResponse(w, r, http.StatusOK, feeds)
+}
+
+// handleAddFeed subscribes to a feed. With verify=true the feed is fetched
+// first and only stored if that succeeds; otherwise the fetch outcome is
+// returned with 422.
 func (s *Server) handleAddFeed(w http.ResponseWriter, r *http.Request) {
 	var req models.AddFeedRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	if req.Name == "" || req.URL == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "name and url are required"})
+	if r.URL.Query().Get("verify") == "true" {
+		if err := s.validateAddFeed(req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		result := s.fetcher.ValidateFeed(r.Context(), models.Feed{
+			URL:     req.URL,
+			Headers: req.Headers,
+			Cookie:  req.Cookie,
+			Strict:  req.Strict,
+		})
+		if !result.Valid {
+			writeJSON(w, http.StatusUnprocessableEntity, result)
+			return
+		}
+	}
+
+	feed, err := s.addFeed(r.Context(), req)
+	switch {
+	case errors.Is(err, store.ErrDuplicateFeed):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, store.ErrFeedLimit):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "feed limit reached; remove a feed before adding another"})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, redactFeed(feed))
+}
+
+// handleBatchAddFeeds adds several feeds at once. Entries are independent:
+// valid ones are created even when others fail.
+func (s *Server) handleBatchAddFeeds(w http.ResponseWriter, r *http.Request) {
+	var req models.BatchAddFeedsRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	feed := s.store.AddFeed(req.Name, req.URL)
+	results := make([]models.BatchAddResult, len(req.Feeds))
+	for i, entry := range req.Feeds {
+		results[i].URL = entry.URL
+
+		feed, err := s.addFeed(r.Context(), entry)
+		switch {
+		case errors.Is(err, store.ErrDuplicateFeed):
+			results[i].Error, results[i].Reason = err.Error(), models.ReasonDuplicate
+		case errors.Is(err, store.ErrFeedLimit):
+			results[i].Error, results[i].Reason = err.Error(), models.ReasonFeedLimit
+		case err != nil:
+			results[i].Error, results[i].Reason = err.Error(), models.ReasonInvalid
+		default:
+			feed = redactFeed(feed)
+			results[i].Feed = &feed
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// addFeed validates req and creates the feed, logging and auditing it.
+// It is shared by the single and batch add endpoints.
+func (s *Server) addFeed(ctx context.Context, req models.AddFeedRequest) (models.Feed, error) {
+	if err := s.validateAddFeed(req); err != nil {
+		return models.Feed{}, err
+	}
+
+	feed, err := s.store.CreateFeed(models.Feed{
+		Name:     req.Name,
+		URL:      req.URL,
+		Folder:   req.Folder,
+		Priority: req.Priority,
+		Headers:  req.Headers,
+		Cookie:   req.Cookie,
+		Filters:  req.Filters,
+		Strict:   req.Strict,
+		Timezone: req.Timezone,
+
+		IDStrategy: req.IDStrategy,
+		KeepRaw:    req.KeepRaw,
+
+		IncludeKeywords: req.IncludeKeywords,
+		ExcludeKeywords: req.ExcludeKeywords,
+
+		MaxArticles: req.MaxArticles,
+	})
+	if err != nil {
+		return models.Feed{}, err
+	}
+
 	s.logger.Info("feed added", "id", feed.ID, "name", feed.Name)
-	writeJSON(w, http.StatusCreated, feed)
+	s.audit.Record(ctx, audit.FeedAdded, feed, requestID(ctx), clientIPFrom(ctx))
+	return feed, nil
+}
+
+func (s *Server) handleUpdateFeed(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateFeedRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if (req.Name != nil && *req.Name == "") || (req.URL != nil && *req.URL == "") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "name and url cannot be empty"})
+		return
+	}
+	if req.URL != nil {
+		if err := s.validateFeedURL(*req.URL); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if req.MaxArticles != nil && *req.MaxArticles < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "max_articles cannot be negative"})
+		return
+	}
+	if req.Timezone != nil {
+		if err := validateTimezone(*req.Timezone); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if req.IDStrategy != nil {
+		if err := validateIDStrategy(*req.IDStrategy); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if err := validateFilters(req.Filters); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	feed, err := s.store.UpdateFeed(r.PathValue("id"), req)
+	switch {
+	case errors.Is(err, store.ErrFeedNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, store.ErrDuplicateFeed):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("feed updated", "id", feed.ID)
+	s.audit.Record(r.Context(), audit.FeedUpdated, feed, requestID(r.Context()), clientIPFrom(r.Context()))
+	writeJSON(w, http.StatusOK, redactFeed(feed))
+}
+
+// handleImportOPML subscribes to every feed in an OPML document, skipping
+// URLs that are already subscribed. URLs that are invalid or over the feed
+// limit are reported as failed rather than skipped.
+func (s *Server) handleImportOPML(w http.ResponseWriter, r *http.Request) {
+	subs, err := opml.Parse(r.Body)
+	if err != nil {
+		writeBodyError(w, err, "invalid OPML body")
+		return
+	}
+
+	summary := models.ImportSummary{Imported: []models.Feed{}, Skipped: []string{}, Failed: []models.BatchAddResult{}}
+	for _, sub := range subs {
+		if err := s.validateFeedURL(sub.URL); err != nil {
+			summary.Failed = append(summary.Failed, models.BatchAddResult{URL: sub.URL, Error: err.Error(), Reason: models.ReasonInvalid})
+			continue
+		}
+		feed, err := s.store.CreateFeed(models.Feed{Name: sub.Name, URL: sub.URL})
+		switch {
+		case errors.Is(err, store.ErrDuplicateFeed):
+			summary.Skipped = append(summary.Skipped, sub.URL)
+			continue
+		case errors.Is(err, store.ErrFeedLimit):
+			summary.Failed = append(summary.Failed, models.BatchAddResult{URL: sub.URL, Error: err.Error(), Reason: models.ReasonFeedLimit})
+			continue
+		}
+		s.audit.Record(r.Context(), audit.FeedAdded, feed, requestID(r.Context()), clientIPFrom(r.Context()))
+		summary.Imported = append(summary.Imported, redactFeed(feed))
+	}
+
+	s.logger.Info("opml imported", "imported", len(summary.Imported), "skipped", len(summary.Skipped), "failed", len(summary.Failed))
+	writeJSON(w, http.StatusOK, summary)
 }
 
+func (s *Server) handleValidateFeed(w http.ResponseWriter, r *http.Request) {
+	var req models.ValidateFeedRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.URL == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "url is required"})
+		return
+	}
+
+	result := s.fetcher.Validate(r.Context(), req.URL)
+	if !result.Valid {
+		writeJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleDiscoverFeed finds the feed of a site URL without storing it: 404
+// if the site has none, 422 if the site could not be fetched.
+func (s *Server) handleDiscoverFeed(w http.ResponseWriter, r *http.Request) {
+	var req models.DiscoverFeedRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	u, err := url.Parse(req.URL)
+	if req.URL == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "url must be an absolute http or https URL"})
+		return
+	}
+
+	found, err := s.fetcher.Discover(r.Context(), req.URL)
+	switch {
+	case errors.Is(err, fetcher.ErrNoFeedFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error(), "reason": fetcher.Classify(err)})
+	default:
+		writeJSON(w, http.StatusOK, found)
+	}
+}
+
+// handleRemoveFeed removes a feed and its articles, reporting how many
+// articles went with it. With dry_run=true it only reports the count.
 func (s *Server) handleRemoveFeed(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	if !s.store.RemoveFeed(id) {
+	feed, ok := s.store.GetFeed(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "feed not found"})
+		return
+	}
+
+	if r.URL.Query().Get("dry_run") == "true" {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"dry_run":          true,
+			"articles_removed": s.store.FeedArticleCount(id),
+		})
+		return
+	}
+
+	removed, ok := s.removeFeed(r.Context(), feed)
+	if !ok {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "feed not found"})
 		return
 	}
-	s.logger.Info("feed removed", "id", id)
-	writeJSON(w, http.StatusOK, map[string]string{"message": "feed removed"})
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message":          "feed removed",
+		"articles_removed": removed,
+	})
+}
+
+// handleSetPriorities reorders feeds in one call, taking a map of feed ID
+// to priority. Nothing changes unless every ID exists.
+func (s *Server) handleSetPriorities(w http.ResponseWriter, r *http.Request) {
+	var req map[string]int
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "map at least one feed ID to a priority"})
+		return
+	}
+
+	feeds, err := s.store.SetPriorities(req)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	for i, feed := range feeds {
+		s.audit.Record(r.Context(), audit.FeedUpdated, feed, requestID(r.Context()), clientIPFrom(r.Context()))
+		feeds[i] = redactFeed(feed)
+	}
+	s.logger.Info("feed priorities updated", "feeds", len(feeds))
+	writeJSON(w, http.StatusOK, feeds)
+}
+
+// handleBatchDeleteFeeds removes several feeds and their articles,
+// reporting the outcome of each ID. A missing ID does not stop the rest.
+func (s *Server) handleBatchDeleteFeeds(w http.ResponseWriter, r *http.Request) {
+	var req models.BatchDeleteFeedsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "ids is required"})
+		return
+	}
+
+	results := make([]models.BatchDeleteResult, len(req.IDs))
+	for i, id := range req.IDs {
+		results[i] = models.BatchDeleteResult{ID: id, Result: models.ResultNotFound}
+		feed, ok := s.store.GetFeed(id)
+		if !ok {
+			continue
+		}
+		if removed, ok := s.removeFeed(r.Context(), feed); ok {
+			results[i].Result, results[i].ArticlesRemoved = models.ResultRemoved, removed
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handleMergeFeeds moves a duplicate feed's articles to the primary feed
+// and removes the duplicate, e.g. after two imports subscribed to the same
+// resource under different URLs.
+func (s *Server) handleMergeFeeds(w http.ResponseWriter, r *http.Request) {
+	var req models.MergeFeedsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.PrimaryID == "" || req.DuplicateID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "primary_id and duplicate_id are required"})
+		return
+	}
+	if req.PrimaryID == req.DuplicateID {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cannot merge a feed into itself"})
+		return
+	}
+	dup, ok := s.store.GetFeed(req.DuplicateID)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "feed " + req.DuplicateID + " not found"})
+		return
+	}
+
+	moved, ok := s.store.MergeFeeds(req.PrimaryID, req.DuplicateID)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "feed " + req.PrimaryID + " not found"})
+		return
+	}
+	s.logger.Info("feeds merged", "primary_id", req.PrimaryID, "duplicate_id", req.DuplicateID, "articles", moved)
+	s.audit.Record(r.Context(), audit.FeedRemoved, dup, requestID(r.Context()), clientIPFrom(r.Context()))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message":        "feeds merged",
+		"articles_moved": moved,
+	})
+}
+
+// removeFeed removes feed and its articles, logging and auditing it. It
+// is shared by the single and batch delete endpoints.
+func (s *Server) removeFeed(ctx context.Context, feed models.Feed) (int, bool) {
+	removed, ok := s.store.RemoveFeed(feed.ID)
+	if !ok {
+		return 0, false
+	}
+	s.logger.Info("feed removed", "id", feed.ID, "articles", removed)
+	s.audit.Record(ctx, audit.FeedRemoved, feed, requestID(ctx), clientIPFrom(ctx))
+	return removed, true
+}
+
+func (s *Server) handleFeedHistory(w http.ResponseWriter, r *http.Request) {
+	history, ok := s.store.FetchHistory(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "feed not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+// handleFeedRaw returns the body of a feed's last response as the feed
+// sent it, for feeds with keep_raw set. The body comes from a third party,
+// so it is always served as an inert plain-text download, never under the
+// type the feed declared, which could make browsers render it as HTML on
+// our origin; that type is passed on in X-Original-Content-Type instead.
+// X-Fetched-At says when it was fetched and X-Truncated marks a body cut
+// at the size limit.
+func (s *Server) handleFeedRaw(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := s.store.GetFeed(id); !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "feed not found"})
+		return
+	}
+	raw, ok := s.store.RawResponse(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no raw response kept; set keep_raw and wait for the next fetch"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if raw.ContentType != "" {
+		w.Header().Set("X-Original-Content-Type", raw.ContentType)
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Content-Disposition", "attachment")
+	w.Header().Set("X-Fetched-At", raw.FetchedAt.UTC().Format(time.RFC3339))
+	if raw.Truncated {
+		w.Header().Set("X-Truncated", "true")
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(raw.Body)
+}
+
+// handleSetFeedEnabled returns a handler that resumes or pauses a feed.
+func (s *Server) handleSetFeedEnabled(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feed, ok := s.store.SetFeedEnabled(r.PathValue("id"), enabled)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "feed not found"})
+			return
+		}
+		s.logger.Info("feed updated", "id", feed.ID, "enabled", enabled)
+		s.audit.Record(r.Context(), audit.FeedUpdated, feed, requestID(r.Context()), clientIPFrom(r.Context()))
+		writeJSON(w, http.StatusOK, redactFeed(feed))
+	}
+}
+
+func (s *Server) handleListFolders(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.store.Folders())
 }
 
 func (s *Server) handleListArticles(w http.ResponseWriter, r *http.Request) {
-	feedID := r.URL.Query().Get("feed_id")
+	query, err := s.articleQuery(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// feed_id may be repeated or comma-separated.
+	for _, v := range r.URL.Query()["feed_id"] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				query.FeedIDs = append(query.FeedIDs, id)
+			}
+		}
+	}
+	query.Folder = r.URL.Query().Get("folder")
+	query.Language = r.URL.Query().Get("lang")
+	query.Saved = r.URL.Query().Get("saved") == "true"
+
+	if v := r.URL.Query().Get("per_feed_limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("per_feed_limit must be a positive integer, got %q", v)})
+			return
+		}
+		// Each keyset page would be capped on its own, so the pages would
+		// not add up to one capped list.
+		if query.Before != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "per_feed_limit cannot be combined with before"})
+			return
+		}
+		query.PerFeedLimit = n
+	}
+	switch mix := r.URL.Query().Get("mix"); mix {
+	case "", store.MixChronological:
+	case store.MixBalanced:
+		if query.Before != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "mix=balanced cannot be combined with before"})
+			return
+		}
+		query.Mix = mix
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unsupported mix %q", mix)})
+		return
+	}
 
-	limit := 50
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
-			limit = parsed
+	s.writeArticles(w, r, query)
+}
+
+// handleTrending ranks articles published within window (default
+// s.trendingWindow) by recency and how busy their feed has been.
+func (s *Server) handleTrending(w http.ResponseWriter, r *http.Request) {
+	window := s.trendingWindow
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("window must be a positive duration, got %q", v)})
+			return
 		}
+		window = d
+	}
+	limit, _, err := pageParams(r, s.defaultLimit, s.maxLimit)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.store.Trending(window, time.Now(), limit))
+}
+
+// handleLastCycleArticles lists exactly what the most recent fetch cycle
+// stored, which a time-based query cannot pin down.
+func (s *Server) handleLastCycleArticles(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.fetcher.LastCycleArticles())
+}
+
+// handleClearArticles wipes every article but keeps feed subscriptions.
+// It requires confirm=true so a stray request can't empty the store.
+func (s *Server) handleClearArticles(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("confirm") != "true" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "add confirm=true to delete all articles"})
+		return
+	}
+
+	removed := s.store.ClearArticles()
+	s.logger.Info("articles cleared", "removed", removed)
+	writeJSON(w, http.StatusOK, map[string]int{"removed": removed})
+}
+
+// handleUpdateArticle moves an article to another feed, e.g. after feeds
+// are merged or split.
+func (s *Server) handleUpdateArticle(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateArticleRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.FeedID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "feed_id is required"})
+		return
+	}
+	if _, ok := s.store.GetFeed(req.FeedID); !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "feed " + req.FeedID + " does not exist"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !s.store.MoveArticle(id, req.FeedID) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "article not found"})
+		return
+	}
+	s.logger.Info("article moved", "id", id, "feed_id", req.FeedID)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "article moved", "feed_id": req.FeedID})
+}
+
+// handlePrune removes old articles, and articles beyond a per-feed count,
+// on demand.
+func (s *Server) handlePrune(w http.ResponseWriter, r *http.Request) {
+	var req models.PruneRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.MaxPerFeed < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "max_per_feed must not be negative"})
+		return
+	}
+	var maxAge time.Duration
+	if req.MaxAge != "" {
+		d, err := time.ParseDuration(req.MaxAge)
+		if err != nil || d <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("max_age must be a positive duration, got %q", req.MaxAge)})
+			return
+		}
+		maxAge = d
+	}
+	if req.MaxPerFeed == 0 && maxAge == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "set max_per_feed, max_age or both"})
+		return
+	}
+
+	removed := s.store.PruneArticles(req.MaxPerFeed, maxAge, time.Now())
+	s.logger.Info("articles pruned", "removed", removed, "max_per_feed", req.MaxPerFeed, "max_age", maxAge)
+	writeJSON(w, http.StatusOK, map[string]int{"removed": removed})
+}
+
+// handleDeleteArticle removes one article. With tombstone=true the ID is
+// also blocked so the next fetch cannot re-add it.
+func (s *Server) handleDeleteArticle(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.store.DeleteArticle(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "article not found"})
+		return
+	}
+	tombstone := r.URL.Query().Get("tombstone") == "true"
+	if tombstone {
+		s.store.Tombstone(id)
+	}
+
+	s.logger.Info("article deleted", "id", id, "tombstone", tombstone)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "article deleted"})
+}
+
+func (s *Server) handleListBlocklist(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.store.Blocklist())
+}
+
+// handleBlock adds a domain or URL to the block list. Matching items are
+// dropped by the fetcher from then on; already stored ones are kept.
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
+	var req models.BlockRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	pattern, ok := s.store.BlockDomain(req.Pattern)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "pattern must be a domain or URL"})
+		return
+	}
+
+	s.logger.Info("domain blocked", "pattern", pattern)
+	writeJSON(w, http.StatusCreated, map[string]string{"pattern": pattern})
+}
+
+func (s *Server) handleUnblock(w http.ResponseWriter, r *http.Request) {
+	pattern := r.PathValue("pattern")
+	if !s.store.UnblockDomain(pattern) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "pattern not blocked"})
+		return
+	}
+
+	s.logger.Info("domain unblocked", "pattern", pattern)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "pattern removed"})
+}
+
+// handleSaveLater returns a handler that adds an article to, or removes it
+// from, the read-later queue.
+func (s *Server) handleSaveLater(save bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		var ok bool
+		if save {
+			ok = s.store.SaveForLater(id)
+		} else {
+			ok = s.store.RemoveFromSaveLater(id)
+		}
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "article not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"saved": save})
+	}
+}
+
+func (s *Server) handleFeedArticles(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := s.store.GetFeed(id); !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "feed not found"})
+		return
+	}
+
+	query, err := s.articleQuery(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
 	}
+	query.FeedIDs = []string{id}
 
-	articles := s.store.ListArticles(feedID, limit)
-	writeJSON(w, http.StatusOK, articles)
+	s.writeArticles(w, r, query)
+}
+
+// articleQuery reads the paging and sorting parameters shared by the
+// article list endpoints.
+func (s *Server) articleQuery(r *http.Request) (store.ArticleQuery, error) {
+	q := r.URL.Query()
+
+	limit, offset, err := pageParams(r, s.defaultLimit, s.maxLimit)
+	if err != nil {
+		return store.ArticleQuery{}, err
+	}
+	query := store.ArticleQuery{Limit: limit, Offset: offset}
+
+	if v := q.Get("after_seq"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 0 {
+			return store.ArticleQuery{}, fmt.Errorf("after_seq must be a non-negative integer, got %q", v)
+		}
+		query.AfterSeq = parsed
+		// Syncing clients page forward through save order by default.
+		query.Sort = store.SortSeqAsc
+	}
+
+	switch sort := q.Get("sort"); sort {
+	case "":
+	case store.SortPublishedDesc, store.SortPublishedAsc, store.SortSeqAsc, store.SortSavedDesc:
+		query.Sort = sort
+	default:
+		return store.ArticleQuery{}, fmt.Errorf("unsupported sort %q", sort)
+	}
+
+	// An empty before starts keyset paging from the newest article.
+	if q.Has("before") {
+		var key store.ArticleKey
+		if v := q.Get("before"); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return store.ArticleQuery{}, fmt.Errorf("before must be an RFC 3339 timestamp, got %q", v)
+			}
+			key = store.ArticleKey{PublishedAt: t, ID: q.Get("before_id")}
+		}
+		// after_seq switches the default sort, so it is rejected here too.
+		if query.Sort != "" && query.Sort != store.SortPublishedDesc {
+			return store.ArticleQuery{}, errors.New("before pages newest first and cannot be combined with another sort or after_seq")
+		}
+		query.Before = &key
+	} else if q.Get("before_id") != "" {
+		return store.ArticleQuery{}, errors.New("before_id requires before")
+	}
+	return query, nil
+}
+
+// writeArticles runs query and writes the page, wrapped in pagination
+// metadata when the client asks for envelope=true, as JSON or XML per the
+// Accept header. A bare JSON list is streamed element by element.
+// Neighbouring pages are always linked from a Link header.
+func (s *Server) writeArticles(w http.ResponseWriter, r *http.Request, query store.ArticleQuery) {
+	callback := r.URL.Query().Get("callback")
+	if callback != "" && !validCallback(callback) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "callback must be a JavaScript identifier such as render or widget.onArticles"})
+		return
+	}
+
+	articles, total, err := s.store.QueryArticlesContext(r.Context(), query)
+	if err != nil {
+		s.logger.Warn("article query abandoned", "error", err)
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "request timed out"})
+		return
+	}
+
+	link := pageLinks(r.URL, query.Limit, query.Offset, total)
+	if query.Before != nil {
+		link = keysetLink(r.URL, articles, query.Offset+len(articles) < total)
+	}
+	if link != "" {
+		w.Header().Set("Link", link)
+	}
+
+	envelope := r.URL.Query().Get("envelope") == "true"
+	var data any = articles
+	if envelope {
+		data = models.ArticlePage{
+			Data:    articles,
+			Total:   total,
+			Limit:   query.Limit,
+			Offset:  query.Offset,
+			HasMore: query.Offset+len(articles) < total,
+		}
+	}
+
+	switch format, _ := negotiate(r.Header.Get("Accept")); {
+	case callback != "":
+		// JSONP is script, whatever the client asked for.
+		writeJSONP(w, http.StatusOK, callback, data)
+	case !envelope && format == formatJSON:
+		writeJSONArray(w, http.StatusOK, articles)
+	default:
+		writeResponse(w, r, http.StatusOK, data)
+	}
+}
+
+func (s *Server) handleLastCycle(w http.ResponseWriter, _ *http.Request) {
+	summary, ok := s.store.LastCycle()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no fetch cycle has completed yet"})
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func (s *Server) handleFetchStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.fetcher.Status())
 }
 
 // ---------- Helpers ----------
 
+// decodeJSON decodes the request body into v, rejecting fields v does not
+// have so that typos are reported rather than ignored. On failure it
+// writes the error response and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		// encoding/json has no typed error for this case.
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown field " + field})
+			return false
+		}
+		writeBodyError(w, err, "invalid JSON body")
+		return false
+	}
+	return true
+}
+
+// writeBodyError reports a request body that could not be read: 413 if it
+// was over the size limit, otherwise 400 with msg.
+func writeBodyError(w http.ResponseWriter, err error, msg string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{
+			"error": fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit),
+		})
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": msg})
+}
+
+// pageParams reads the limit and offset query parameters. limit defaults to
+// defaultLimit and is clamped to maxLimit.
+func pageParams(r *http.Request, defaultLimit, maxLimit int) (limit, offset int, err error) {
+	q := r.URL.Query()
+
+	limit = defaultLimit
+	if l := q.Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			return 0, 0, fmt.Errorf("limit must be a positive integer, got %q", l)
+		}
+		limit = min(parsed, maxLimit)
+	}
+	if o := q.Get("offset"); o != "" {
+		parsed, err := strconv.Atoi(o)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer, got %q", o)
+		}
+		offset = parsed
+	}
+	return limit, offset, nil
+}
+
+// pageLinks builds an RFC 8288 Link header value pointing at the pages
+// before and after the one at offset, keeping the request's other query
+// parameters. It returns "" when there is neither.
+func pageLinks(u *url.URL, limit, offset, total int) string {
+	link := func(offset int, rel string) string {
+		q := u.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(offset))
+		return fmt.Sprintf("<%s?%s>; rel=%q", u.Path, q.Encode(), rel)
+	}
+
+	var links []string
+	if offset+limit < total {
+		links = append(links, link(offset+limit, "next"))
+	}
+	if offset > 0 {
+		links = append(links, link(max(offset-limit, 0), "prev"))
+	}
+	return strings.Join(links, ", ")
+}
+
+// keysetLink builds the Link header value for a keyset page: when there is
+// more, a next link keyed on the page's last article. Keyset pages have no
+// prev link.
+func keysetLink(u *url.URL, page []models.Article, more bool) string {
+	if !more || len(page) == 0 {
+		return ""
+	}
+	last := page[len(page)-1]
+	q := u.Query()
+	q.Del("offset")
+	q.Set("before", last.PublishedAt.Format(time.RFC3339Nano))
+	q.Set("before_id", last.ID)
+	return fmt.Sprintf("<%s?%s>; rel=%q", u.Path, q.Encode(), "next")
+}
+
+// validateAddFeed checks the fields required to subscribe to a feed.
+func (s *Server) validateAddFeed(req models.AddFeedRequest) error {
+	if req.Name == "" || req.URL == "" {
+		return errors.New("name and url are required")
+	}
+	if err := s.validateFeedURL(req.URL); err != nil {
+		return err
+	}
+	if req.MaxArticles < 0 {
+		return errors.New("max_articles cannot be negative")
+	}
+	if err := validateTimezone(req.Timezone); err != nil {
+		return err
+	}
+	if err := validateIDStrategy(req.IDStrategy); err != nil {
+		return err
+	}
+	return validateFilters(req.Filters)
+}
+
+// validateIDStrategy accepts "" or one of the models.IDStrategy values.
+func validateIDStrategy(strategy string) error {
+	switch strategy {
+	case "", models.IDStrategyGUID, models.IDStrategyLink:
+		return nil
+	}
+	return fmt.Errorf("id_strategy must be %q or %q, got %q", models.IDStrategyGUID, models.IDStrategyLink, strategy)
+}
+
+// validateTimezone accepts "" or an IANA zone name such as Europe/Berlin.
+func validateTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+	// LoadLocation also accepts "Local", which would depend on the host.
+	if _, err := time.LoadLocation(name); err != nil || name == "Local" {
+		return fmt.Errorf("timezone must be an IANA zone name such as Europe/Berlin, got %q", name)
+	}
+	return nil
+}
+
+// validateFilters checks that every filter can be built by the fetcher.
+func validateFilters(filters []models.Filter) error {
+	for _, f := range filters {
+		if _, err := fetcher.NewTransformer(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateFeedURL accepts absolute http(s) URLs, and file:// URLs when the
+// fetcher has file feeds enabled.
+func (s *Server) validateFeedURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err == nil && u.Scheme == "file" && s.fetcher.AllowsScheme("file") && u.Path != "" {
+		return nil
+	}
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// sensitiveHeaders lists (lowercased) feed headers whose values are never
+// echoed back to clients.
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"x-api-key":           true,
+}
+
+// redactFeed returns a copy of f with its cookie and credential-bearing
+// header values masked.
+func redactFeed(f models.Feed) models.Feed {
+	if f.Cookie != "" {
+		f.Cookie = "[REDACTED]"
+	}
+	if len(f.Headers) == 0 {
+		return f
+	}
+	headers := make(map[string]string, len(f.Headers))
+	for k, v := range f.Headers {
+		if sensitiveHeaders[strings.ToLower(k)] {
+			v = "[REDACTED]"
+		}
+		headers[k] = v
+	}
+	f.Headers = headers
+	return f
+}
+
+type requestIDKey struct{}
+
+// requestID returns the ID ServeHTTP attached to ctx.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// writeJSONArray writes items as a JSON array one element at a time, so
+// the encoded response is never held in memory whole.
+func writeJSONArray[T any](w http.ResponseWriter, status int, items []T) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	io.WriteString(w, "[")
+	for i, item := range items {
+		if i > 0 {
+			io.WriteString(w, ",")
+		}
+		if err := enc.Encode(item); err != nil {
+			return // the client is gone
+		}
+	}
+	io.WriteString(w, "]\n")
+}
+
 func writeJSON(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -2,35 +2,337 @@ package api_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/raffaelramalhorosa/rss-aggregator/internal/api"
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/audit"
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/fetcher"
 	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
 	"github.com/raffaelramalhorosa/rss-aggregator/internal/store"
 )
 
-func setup() (*api.Server, *store.Store) {
+func setup(opts ...api.Option) (*api.Server, *store.Store) {
 	s := store.New()
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
-	return api.New(s, logger), s
+	return api.New(s, fetcher.New(s, time.Minute, logger), logger, opts...), s
+}
+
+// saveArticles stores n articles for feedID with distinct publish times.
+func saveArticles(s *store.Store, feedID string, n int) {
+	now := time.Now()
+	articles := make([]models.Article, n)
+	for i := range articles {
+		articles[i] = models.Article{
+			ID:          fmt.Sprintf("%s-%d", feedID, i),
+			FeedID:      feedID,
+			Title:       fmt.Sprintf("Article %d", i),
+			PublishedAt: now.Add(-time.Duration(i) * time.Minute),
+		}
+	}
+	s.SaveArticles(articles)
+}
+
+const rssFixture = `<?xml version="1.0"?>
+<rss version="2.0">
+  <channel>
+    <title>Fixture Feed</title>
+    <link>https://example.com</link>
+    <item><title>One</title><link>https://example.com/1</link></item>
+    <item><title>Two</title><link>https://example.com/2</link></item>
+  </channel>
+</rss>`
+
+// serveFixture starts a server that answers every request with body.
+func serveFixture(t *testing.T, contentType, body string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
 }
 
 func TestHealthEndpoint(t *testing.T) {
 	srv, _ := setup()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+	for _, path := range []string{"/api/health", "/api/health/live", "/api/health/ready"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+
+		srv.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestVersionEndpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []api.Option
+		want models.BuildInfo
+	}{
+		{"defaults", nil, models.BuildInfo{Version: "dev", Commit: "unknown", BuildTime: "unknown"}},
+		{"injected", []api.Option{api.WithBuildInfo(models.BuildInfo{Version: "v1.2.0", Commit: "abc123", BuildTime: "2024-01-01T00:00:00Z"})},
+			models.BuildInfo{Version: "v1.2.0", Commit: "abc123", BuildTime: "2024-01-01T00:00:00Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := setup(tt.opts...)
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/version", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", rec.Code)
+			}
+
+			var got models.BuildInfo
+			json.NewDecoder(rec.Body).Decode(&got)
+			if got != tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, `<rss version="2.0"><channel><title>T</title>
+<item><title>One</title><link>https://example.com/1</link></item>
+<item><title>Two</title><link>https://example.com/2</link></item>
+</channel></rss>`)
+	}))
+	defer feedServer.Close()
+
+	s := store.New()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.New(s, time.Hour, logger, fetcher.WithStartupSpread(0))
+	srv := api.New(s, f, logger)
+	s.AddFeed("Fixture", feedServer.URL)
+
+	metrics := func() models.Metrics {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/metrics", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		var m models.Metrics
+		json.NewDecoder(rec.Body).Decode(&m)
+		return m
+	}
+
+	if m := metrics(); m.FetchCycles != 0 || m.LastCycleAt != nil || m.Feeds != 1 || m.Articles != 0 {
+		t.Fatalf("unexpected metrics before any cycle: %+v", m)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.Start(ctx) // the first cycle runs immediately
+
+	deadline := time.Now().Add(5 * time.Second)
+	for f.Cycles() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	m := metrics()
+	if m.FetchCycles != 1 || m.LastCycleAt == nil || m.LastCycleNewArticles != 2 || m.Articles != 2 {
+		t.Fatalf("expected metrics to reflect one cycle with 2 new articles, got %+v", m)
+	}
+}
+
+func TestLastCycleArticlesEndpoint(t *testing.T) {
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, rssFixture)
+	}))
+	defer feedServer.Close()
+
+	s := store.New()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.New(s, time.Hour, logger, fetcher.WithStartupSpread(0))
+	srv := api.New(s, f, logger)
+	feed := s.AddFeed("Fixture", feedServer.URL)
+	saveArticles(s, "older", 3) // stored outside the cycle
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.Start(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for f.Cycles() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
 	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles/new-last-cycle", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var articles []models.Article
+	json.NewDecoder(rec.Body).Decode(&articles)
+	if len(articles) != 2 {
+		t.Fatalf("expected the cycle's 2 new articles, got %d", len(articles))
+	}
+	for _, a := range articles {
+		if a.FeedID != feed.ID {
+			t.Fatalf("expected only articles from the cycle, got %+v", a)
+		}
+	}
+}
 
-	srv.ServeHTTP(rec, req)
+func TestPruneEndpoint(t *testing.T) {
+	srv, s := setup(api.WithMaintenanceToken("s3cret"))
+	saveArticles(s, "f1", 5)
+	saveArticles(s, "f2", 2)
+
+	prune := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/maintenance/prune", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := prune(`{"max_per_feed": 2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp map[string]int
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp["removed"] != 3 {
+		t.Fatalf("expected 3 removed, got %v", resp)
+	}
+	if _, total := s.QueryArticles(store.ArticleQuery{FeedIDs: []string{"f1"}}); total != 2 {
+		t.Fatalf("expected f1 to keep 2 articles, got %d", total)
+	}
+
+	for _, body := range []string{`{}`, `{"max_per_feed": -1}`, `{"max_age": "soon"}`, `{"max_age": "-1h"}`} {
+		if rec := prune(body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+}
+
+func TestPruneEndpointRequiresMaintenanceToken(t *testing.T) {
+	post := func(srv *api.Server, auth string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/maintenance/prune", strings.NewReader(`{"max_per_feed": 1}`))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	disabled, s := setup()
+	saveArticles(s, "f1", 3)
+	if code := post(disabled, "Bearer anything"); code != http.StatusForbidden {
+		t.Fatalf("expected 403 without a configured token, got %d", code)
+	}
+
+	srv, s := setup(api.WithMaintenanceToken("s3cret"))
+	saveArticles(s, "f1", 3)
+	for _, auth := range []string{"", "Bearer wrong", "s3cret", "Basic s3cret"} {
+		if code := post(srv, auth); code != http.StatusUnauthorized {
+			t.Fatalf("Authorization %q: expected 401, got %d", auth, code)
+		}
+	}
+	if n := s.FeedArticleCount("f1"); n != 3 {
+		t.Fatalf("expected nothing pruned, f1 has %d articles", n)
+	}
+}
+
+func TestDedupStatsEndpoint(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "f1", 3)
+	saveArticles(s, "f1", 3)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/dedup", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var stats models.DedupStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if want := (models.DedupStats{Seen: 6, Stored: 3, DuplicateID: 3}); stats != want {
+		t.Fatalf("expected %+v, got %+v", want, stats)
+	}
+}
+func TestFetchStatusEndpoint(t *testing.T) {
+	srv, _ := setup()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/fetch/status", nil))
+
+	var st models.FetchStatus
+	json.NewDecoder(rec.Body).Decode(&st)
+	if rec.Code != http.StatusOK || st.Running || st.StartedAt != nil {
+		t.Fatalf("expected an idle status, got %d %+v", rec.Code, st)
+	}
+}
+
+func TestSetFetchIntervalEndpoint(t *testing.T) {
+	s := store.New()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	f := fetcher.New(s, time.Minute, logger)
+	srv := api.New(s, f, logger)
 
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/config/fetch-interval", strings.NewReader(`{"interval": "90s"}`)))
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rec.Code)
 	}
+	if f.Interval() != 90*time.Second {
+		t.Fatalf("expected fetcher interval 90s, got %v", f.Interval())
+	}
+
+	for _, body := range []string{`{"interval": "soon"}`, `{"interval": "-1m"}`, `{}`} {
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/config/fetch-interval", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+	if f.Interval() != 90*time.Second {
+		t.Fatalf("invalid requests changed the interval to %v", f.Interval())
+	}
+}
+
+func TestReadinessReflectsStore(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	unready := &store.Store{} // never initialized by store.New
+	srv := api.New(unready, fetcher.New(unready, time.Minute, logger), logger)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/health/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 from readiness, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/health/live", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected liveness to stay 200, got %d", rec.Code)
+	}
 }
 
 func TestAddFeedEndpoint(t *testing.T) {
@@ -68,6 +370,61 @@ func TestAddFeedValidation(t *testing.T) {
 	}
 }
 
+func TestAddFeedLimit(t *testing.T) {
+	s := store.New(store.WithMaxFeeds(1))
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := api.New(s, fetcher.New(s, time.Minute, logger), logger)
+
+	post := func(url string) int {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", strings.NewReader(`{"name": "Feed", "url": "`+url+`"}`)))
+		return rec.Code
+	}
+
+	if code := post("https://example.com/1"); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if code := post("https://example.com/2"); code != http.StatusForbidden {
+		t.Fatalf("expected 403 over the limit, got %d", code)
+	}
+}
+
+func TestAddFeedIdempotencyKey(t *testing.T) {
+	srv, s := setup()
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/feeds", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	body := `{"name": "Go Blog", "url": "https://go.dev/blog/feed.atom"}`
+	first := post("retry-1", body)
+	second := post("retry-1", body)
+
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Fatalf("expected 201 twice, got %d and %d", first.Code, second.Code)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Fatalf("replayed response differs:\n%s\n%s", first.Body, second.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("expected the retry to be marked as replayed")
+	}
+	if feeds := s.ListFeeds(); len(feeds) != 1 {
+		t.Fatalf("expected one feed, got %d", len(feeds))
+	}
+
+	if rec := post("retry-1", `{"name": "Other", "url": "https://example.com/feed"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a reused key, got %d", rec.Code)
+	}
+	if rec := post("retry-2", body); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a new key on an existing feed, got %d", rec.Code)
+	}
+}
+
 func TestListFeedsEndpoint(t *testing.T) {
 	srv, s := setup()
 	s.AddFeed("Feed 1", "https://example.com/1")
@@ -86,17 +443,107 @@ func TestListFeedsEndpoint(t *testing.T) {
 	}
 }
 
+func TestListFeedsPagination(t *testing.T) {
+	srv, s := setup()
+	for _, name := range []string{"C", "A", "E", "B", "D"} {
+		s.AddFeed(name, "https://example.com/"+name)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feeds?limit=2&offset=2&envelope=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var page models.FeedPage
+	json.NewDecoder(rec.Body).Decode(&page)
+	if page.Total != 5 || !page.HasMore || len(page.Data) != 2 || page.Data[0].Name != "C" || page.Data[1].Name != "D" {
+		t.Fatalf("expected page [C D] of 5 with more, got %+v", page)
+	}
+
+	for _, q := range []string{"limit=0", "offset=-1", "limit=abc"} {
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feeds?"+q, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", q, rec.Code)
+		}
+	}
+}
+
+func TestListFeedsSortedByCreation(t *testing.T) {
+	srv, s := setup()
+	for _, name := range []string{"Zulu", "Alpha", "Mike"} {
+		s.AddFeed(name, "https://example.com/"+name)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feeds?sort=created_desc&limit=2", nil))
+	var feeds []models.Feed
+	json.NewDecoder(rec.Body).Decode(&feeds)
+	if len(feeds) != 2 || feeds[0].Name != "Mike" || feeds[1].Name != "Alpha" || feeds[0].CreatedAt.IsZero() {
+		t.Fatalf("expected [Mike Alpha] with created_at set, got %+v", feeds)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feeds?sort=oldest", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown sort, got %d", rec.Code)
+	}
+}
+
+func TestListFeedsNeverFetched(t *testing.T) {
+	srv, s := setup()
+	fresh := s.AddFeed("Fresh", "https://example.com/fresh")
+	fetched := s.AddFeed("Fetched", "https://example.com/fetched")
+	s.UpdateLastFetched(fetched.ID, time.Now())
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feeds?never_fetched=true&envelope=true", nil))
+	var page models.FeedPage
+	json.NewDecoder(rec.Body).Decode(&page)
+	if rec.Code != http.StatusOK || page.Total != 1 || len(page.Data) != 1 || page.Data[0].ID != fresh.ID {
+		t.Fatalf("expected only the unfetched feed, got %d %+v", rec.Code, page)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feeds", nil))
+	var feeds []models.Feed
+	json.NewDecoder(rec.Body).Decode(&feeds)
+	if len(feeds) != 2 {
+		t.Fatalf("expected both feeds without the filter, got %d", len(feeds))
+	}
+}
+
 func TestRemoveFeedEndpoint(t *testing.T) {
 	srv, s := setup()
 	f := s.AddFeed("To Remove", "https://example.com/rss")
+	saveArticles(s, f.ID, 3)
 
-	req := httptest.NewRequest(http.MethodDelete, "/api/feeds/"+f.ID, nil)
+	var result struct {
+		DryRun          bool `json:"dry_run"`
+		ArticlesRemoved int  `json:"articles_removed"`
+	}
 	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/feeds/"+f.ID+"?dry_run=true", nil))
+	json.NewDecoder(rec.Body).Decode(&result)
+	if rec.Code != http.StatusOK || !result.DryRun || result.ArticlesRemoved != 3 {
+		t.Fatalf("expected a dry run counting 3 articles, got %d %+v", rec.Code, result)
+	}
+	if _, ok := s.GetFeed(f.ID); !ok {
+		t.Fatal("expected the feed to survive a dry run")
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/feeds/"+f.ID, nil)
+	rec = httptest.NewRecorder()
 
 	srv.ServeHTTP(rec, req)
 
-	if rec.Code != http.StatusOK {
-		t.Fatalf("expected 200, got %d", rec.Code)
+	result.ArticlesRemoved = 0
+	json.NewDecoder(rec.Body).Decode(&result)
+	if rec.Code != http.StatusOK || result.ArticlesRemoved != 3 {
+		t.Fatalf("expected 200 removing 3 articles, got %d %+v", rec.Code, result)
+	}
+	if n := s.ArticleCount(); n != 0 {
+		t.Fatalf("expected the articles removed, %d left", n)
 	}
 
 	// Removing again should 404.
@@ -127,3 +574,1581 @@ func TestListArticlesEndpoint(t *testing.T) {
 		t.Fatalf("expected 1 article with limit=1, got %d", len(articles))
 	}
 }
+
+func TestValidateFeedEndpoint(t *testing.T) {
+	srv, s := setup()
+	ts := serveFixture(t, "application/rss+xml", rssFixture)
+
+	body, _ := json.Marshal(models.ValidateFeedRequest{URL: ts.URL})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds/validate", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var result models.FeedValidation
+	json.NewDecoder(rec.Body).Decode(&result)
+
+	if !result.Valid || result.Title != "Fixture Feed" || result.ItemCount != 2 || result.FeedType != "rss" {
+		t.Fatalf("unexpected validation result: %+v", result)
+	}
+
+	if len(s.ListFeeds()) != 0 {
+		t.Fatal("validation must not store the feed")
+	}
+}
+
+func TestAddFeedVerify(t *testing.T) {
+	srv, s := setup()
+	ok := serveFixture(t, "application/rss+xml", rssFixture)
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	add := func(url string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.AddFeedRequest{Name: "Feed", URL: url})
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds?verify=true", bytes.NewReader(body)))
+		return rec
+	}
+
+	rec := add(missing.URL)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an unreachable feed, got %d", rec.Code)
+	}
+	var result models.FeedValidation
+	json.NewDecoder(rec.Body).Decode(&result)
+	if result.Valid || result.Reason != fetcher.ReasonHTTPStatus || result.Error == "" {
+		t.Fatalf("unexpected validation result: %+v", result)
+	}
+	if len(s.ListFeeds()) != 0 {
+		t.Fatal("a feed that failed verification must not be stored")
+	}
+
+	rec = add(ok.URL)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for a reachable feed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if feeds := s.ListFeeds(); len(feeds) != 1 || feeds[0].URL != ok.URL {
+		t.Fatalf("expected the verified feed to be stored, got %+v", feeds)
+	}
+}
+
+func TestValidateFeedEndpointInvalid(t *testing.T) {
+	srv, _ := setup()
+
+	notFeed := serveFixture(t, "text/html", "<html><body>not a feed</body></html>")
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	cases := []struct {
+		name, url, reason string
+	}{
+		{"not a feed", notFeed.URL, fetcher.ReasonParse},
+		{"http error", missing.URL, fetcher.ReasonHTTPStatus},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, _ := json.Marshal(models.ValidateFeedRequest{URL: tc.url})
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds/validate", bytes.NewReader(body)))
+
+			if rec.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected 422, got %d", rec.Code)
+			}
+
+			var result models.FeedValidation
+			json.NewDecoder(rec.Body).Decode(&result)
+
+			if result.Valid || result.Reason != tc.reason {
+				t.Fatalf("expected reason %q, got %+v", tc.reason, result)
+			}
+		})
+	}
+}
+
+func TestListArticlesStreamsJSONArray(t *testing.T) {
+	srv, s := setup()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles", nil))
+	if body := rec.Body.String(); body != "[]\n" {
+		t.Fatalf("expected an empty array, got %q", body)
+	}
+
+	saveArticles(s, "f1", 5)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?limit=3", nil))
+
+	var articles []models.Article
+	if err := json.Unmarshal(rec.Body.Bytes(), &articles); err != nil {
+		t.Fatalf("streamed body is not a JSON array: %v\n%s", err, rec.Body)
+	}
+	want := s.ListArticles("f1", 3)
+	if len(articles) != len(want) {
+		t.Fatalf("expected %d articles, got %d", len(want), len(articles))
+	}
+	for i := range want {
+		if articles[i].ID != want[i].ID || articles[i].Title != want[i].Title {
+			t.Fatalf("article %d: expected %s, got %s", i, want[i].ID, articles[i].ID)
+		}
+	}
+}
+
+func TestListArticlesKeysetPaging(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "f1", 10)
+
+	seen := make(map[string]bool)
+	next := "/api/articles?limit=4&before="
+	for pages := 0; next != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("expected 3 pages")
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, next, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		var articles []models.Article
+		json.NewDecoder(rec.Body).Decode(&articles)
+		for _, a := range articles {
+			if seen[a.ID] {
+				t.Fatalf("%s returned twice", a.ID)
+			}
+			seen[a.ID] = true
+		}
+
+		// An article arriving mid-paging is newer than the key.
+		saveArticles(s, fmt.Sprint("late", pages), 1)
+
+		next = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			next = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		}
+	}
+	if len(seen) != 10 {
+		t.Fatalf("expected all 10 articles once, got %d", len(seen))
+	}
+
+	for _, q := range []string{"before=yesterday", "before_id=f1-0", "before=2024-01-01T00:00:00Z&sort=published_asc"} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?"+q, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", q, rec.Code)
+		}
+	}
+}
+
+func TestListArticlesEnvelope(t *testing.T) {
+	srv, s := setup()
+	now := time.Now()
+	s.SaveArticles([]models.Article{
+		{ID: "a1", FeedID: "f1", PublishedAt: now},
+		{ID: "a2", FeedID: "f1", PublishedAt: now.Add(-time.Minute)},
+		{ID: "a3", FeedID: "f1", PublishedAt: now.Add(-2 * time.Minute)},
+	})
+
+	cases := []struct {
+		query   string
+		count   int
+		hasMore bool
+	}{
+		{"limit=2&offset=0", 2, true},
+		{"limit=2&offset=1", 2, false}, // exactly reaches the last item
+		{"limit=2&offset=2", 1, false},
+		{"limit=2&offset=5", 0, false},
+	}
+
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?envelope=true&"+tc.query, nil))
+
+		var page models.ArticlePage
+		json.NewDecoder(rec.Body).Decode(&page)
+
+		if page.Total != 3 || len(page.Data) != tc.count || page.HasMore != tc.hasMore {
+			t.Fatalf("%s: unexpected page %+v", tc.query, page)
+		}
+	}
+}
+
+func TestListArticlesLinkHeader(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "f1", 5)
+
+	link := func(query string) string {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?"+query, nil))
+		return rec.Header().Get("Link")
+	}
+
+	if got, want := link("feed_id=f1&limit=2"), `</api/articles?feed_id=f1&limit=2&offset=2>; rel="next"`; got != want {
+		t.Fatalf("first page: expected %s, got %s", want, got)
+	}
+	if got := link("limit=2&offset=2"); !strings.Contains(got, `offset=4>; rel="next"`) || !strings.Contains(got, `offset=0>; rel="prev"`) {
+		t.Fatalf("middle page: unexpected Link %s", got)
+	}
+	if got := link("limit=2&offset=4"); strings.Contains(got, `rel="next"`) || !strings.Contains(got, `offset=2>; rel="prev"`) {
+		t.Fatalf("last page: unexpected Link %s", got)
+	}
+	if got := link("limit=10"); got != "" {
+		t.Fatalf("single page: expected no Link, got %s", got)
+	}
+}
+
+func TestListArticlesLimits(t *testing.T) {
+	srv, s := setup(api.WithArticleLimits(3, 5))
+	saveArticles(s, "f1", 10)
+
+	cases := []struct {
+		query string
+		want  int
+	}{
+		{"", 3},               // default applied
+		{"?limit=4", 4},       // within cap
+		{"?limit=1000000", 5}, // clamped to max
+	}
+
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles"+tc.query, nil))
+
+		var articles []models.Article
+		json.NewDecoder(rec.Body).Decode(&articles)
+
+		if len(articles) != tc.want {
+			t.Fatalf("%q: expected %d articles, got %d", tc.query, tc.want, len(articles))
+		}
+	}
+}
+
+func TestListArticlesRejectsMalformedPaging(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "f1", 3)
+
+	for _, query := range []string{"limit=abc", "limit=-1", "limit=0", "offset=-5", "offset=x"} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?"+query, nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rec.Code)
+		}
+		var body map[string]string
+		json.NewDecoder(rec.Body).Decode(&body)
+		if body["error"] == "" {
+			t.Fatalf("%s: expected an error message", query)
+		}
+	}
+}
+
+func TestAuditTrailOnAddAndRemove(t *testing.T) {
+	var buf bytes.Buffer
+	srv, _ := setup(api.WithAuditLogger(audit.New(&buf)))
+
+	body, _ := json.Marshal(models.AddFeedRequest{Name: "Audited", URL: "https://example.com/audited"})
+	req := httptest.NewRequest(http.MethodPost, "/api/feeds", bytes.NewReader(body))
+	req.Header.Set("X-Request-ID", "req-add")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	var feed models.Feed
+	json.NewDecoder(rec.Body).Decode(&feed)
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/feeds/"+feed.ID, nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d: %s", len(lines), buf.String())
+	}
+
+	var added, removed map[string]any
+	json.Unmarshal([]byte(lines[0]), &added)
+	json.Unmarshal([]byte(lines[1]), &removed)
+
+	if added["event"] != audit.FeedAdded || added["feed_id"] != feed.ID || added["request_id"] != "req-add" {
+		t.Fatalf("unexpected add entry: %v", added)
+	}
+	if removed["event"] != audit.FeedRemoved || removed["url"] != "https://example.com/audited" {
+		t.Fatalf("unexpected remove entry: %v", removed)
+	}
+	if removed["request_id"] != rec.Header().Get("X-Request-ID") {
+		t.Fatal("expected generated request ID to match the response header")
+	}
+}
+
+func TestEnableDisableFeedEndpoints(t *testing.T) {
+	srv, s := setup()
+	f := s.AddFeed("Noisy", "https://example.com/rss")
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds/"+f.ID+"/disable", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feeds", nil))
+
+	var feeds []models.Feed
+	json.NewDecoder(rec.Body).Decode(&feeds)
+	if len(feeds) != 1 || feeds[0].Enabled {
+		t.Fatalf("expected disabled feed to be listed, got %+v", feeds)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds/"+f.ID+"/enable", nil))
+
+	var feed models.Feed
+	json.NewDecoder(rec.Body).Decode(&feed)
+	if !feed.Enabled {
+		t.Fatal("expected feed to be re-enabled")
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds/missing/disable", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown feed, got %d", rec.Code)
+	}
+}
+
+func TestAddFeedRejectsDuplicateURL(t *testing.T) {
+	srv, s := setup()
+	s.AddFeed("Go Blog", "https://go.dev/blog/feed.atom")
+
+	body, _ := json.Marshal(models.AddFeedRequest{Name: "Again", URL: "https://go.dev/blog/feed.atom/"})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rec.Code)
+	}
+}
+
+func TestImportOPMLSkipsExistingFeeds(t *testing.T) {
+	srv, s := setup()
+	s.AddFeed("Go Blog", "https://go.dev/blog/feed.atom")
+
+	doc := `<?xml version="1.0"?>
+<opml version="2.0">
+  <body>
+    <outline text="Go Blog" xmlUrl="https://GO.dev/blog/feed.atom"/>
+    <outline text="Tech">
+      <outline text="Lobsters" xmlUrl="https://lobste.rs/rss"/>
+      <outline text="Lobsters again" xmlUrl="https://lobste.rs/rss/"/>
+    </outline>
+  </body>
+</opml>`
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds/import", strings.NewReader(doc)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var summary models.ImportSummary
+	json.NewDecoder(rec.Body).Decode(&summary)
+
+	if len(summary.Imported) != 1 || summary.Imported[0].URL != "https://lobste.rs/rss" {
+		t.Fatalf("expected only Lobsters to be imported, got %+v", summary.Imported)
+	}
+	if len(summary.Skipped) != 2 {
+		t.Fatalf("expected 2 skipped URLs, got %v", summary.Skipped)
+	}
+	if len(s.ListFeeds()) != 2 {
+		t.Fatalf("expected 2 feeds after import, got %d", len(s.ListFeeds()))
+	}
+}
+
+func TestImportOPMLReportsFailures(t *testing.T) {
+	s := store.New(store.WithMaxFeeds(2))
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := api.New(s, fetcher.New(s, time.Minute, logger), logger)
+	s.AddFeed("Go Blog", "https://go.dev/blog/feed.atom")
+
+	doc := `<opml version="2.0"><body>
+    <outline text="Go Blog" xmlUrl="https://go.dev/blog/feed.atom"/>
+    <outline text="Script" xmlUrl="javascript:alert(1)"/>
+    <outline text="FTP" xmlUrl="ftp://example.com/rss"/>
+    <outline text="Lobsters" xmlUrl="https://lobste.rs/rss"/>
+    <outline text="One too many" xmlUrl="https://example.com/rss"/>
+  </body></opml>`
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds/import", strings.NewReader(doc)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var summary models.ImportSummary
+	json.NewDecoder(rec.Body).Decode(&summary)
+
+	if len(summary.Imported) != 1 || summary.Imported[0].URL != "https://lobste.rs/rss" {
+		t.Fatalf("expected only Lobsters to be imported, got %+v", summary.Imported)
+	}
+	if !slices.Equal(summary.Skipped, []string{"https://go.dev/blog/feed.atom"}) {
+		t.Fatalf("expected only the subscribed feed skipped, got %v", summary.Skipped)
+	}
+	reasons := make(map[string]string)
+	for _, f := range summary.Failed {
+		reasons[f.URL] = f.Reason
+	}
+	want := map[string]string{
+		"javascript:alert(1)":     models.ReasonInvalid,
+		"ftp://example.com/rss":   models.ReasonInvalid,
+		"https://example.com/rss": models.ReasonFeedLimit,
+	}
+	if !maps.Equal(reasons, want) {
+		t.Fatalf("expected failures %v, got %+v", want, summary.Failed)
+	}
+}
+
+func TestFeedCookieIsRedacted(t *testing.T) {
+	srv, s := setup()
+
+	body, _ := json.Marshal(models.AddFeedRequest{Name: "Members", URL: "https://example.com/members", Cookie: "session=abc123"})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", bytes.NewReader(body)))
+	if rec.Code != http.StatusCreated || strings.Contains(rec.Body.String(), "abc123") {
+		t.Fatalf("expected 201 with the cookie redacted, got %d %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feeds", nil))
+	if strings.Contains(rec.Body.String(), "abc123") {
+		t.Fatal("cookie leaked in listing")
+	}
+
+	if feeds := s.ListFeeds(); feeds[0].Cookie != "session=abc123" {
+		t.Fatalf("expected the stored cookie to be intact, got %q", feeds[0].Cookie)
+	}
+}
+
+func TestFeedHeadersAreRedacted(t *testing.T) {
+	srv, s := setup()
+
+	body, _ := json.Marshal(models.AddFeedRequest{
+		Name:    "Private",
+		URL:     "https://example.com/private",
+		Headers: map[string]string{"Authorization": "token xyz", "Accept": "application/rss+xml"},
+	})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feeds", nil))
+
+	var feeds []models.Feed
+	json.NewDecoder(rec.Body).Decode(&feeds)
+
+	if len(feeds) != 1 {
+		t.Fatalf("expected 1 feed, got %d", len(feeds))
+	}
+	if feeds[0].Headers["Authorization"] == "token xyz" {
+		t.Fatal("authorization header leaked in listing")
+	}
+	if feeds[0].Headers["Accept"] != "application/rss+xml" {
+		t.Fatal("non-sensitive header should be shown as-is")
+	}
+
+	// The stored value must stay intact for the fetcher.
+	stored, _ := s.GetFeed(feeds[0].ID)
+	if stored.Headers["Authorization"] != "token xyz" {
+		t.Fatal("redaction must not modify the stored feed")
+	}
+}
+
+func TestUpdateFeedEndpoint(t *testing.T) {
+	srv, s := setup()
+	f := s.AddFeed("Old", "https://example.com/rss")
+
+	body := `{"name": "New", "headers": {"X-Api-Key": "secret"}}`
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/feeds/"+f.ID, strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	stored, _ := s.GetFeed(f.ID)
+	if stored.Name != "New" || stored.URL != f.URL || stored.Headers["X-Api-Key"] != "secret" {
+		t.Fatalf("unexpected stored feed: %+v", stored)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/feeds/missing", strings.NewReader(body)))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+
+	for _, url := range []string{"javascript:x", "ftp://example.com/rss", "/relative/rss"} {
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/feeds/"+f.ID, strings.NewReader(`{"url": "`+url+`"}`)))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for url %q, got %d", url, rec.Code)
+		}
+	}
+	if stored, _ := s.GetFeed(f.ID); stored.URL != f.URL {
+		t.Fatalf("expected the URL unchanged, got %q", stored.URL)
+	}
+}
+
+func TestSetPrioritiesEndpoint(t *testing.T) {
+	srv, s := setup()
+	low := s.AddFeed("Low", "https://example.com/low")
+	high := s.AddFeed("High", "https://example.com/high")
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/feeds/priorities", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := patch(fmt.Sprintf(`{%q: 1, %q: 9}`, low.ID, high.ID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var feeds []models.Feed
+	json.NewDecoder(rec.Body).Decode(&feeds)
+	if len(feeds) != 2 || feeds[0].ID != high.ID || feeds[0].Priority != 9 || feeds[1].Priority != 1 {
+		t.Fatalf("expected the updated feeds highest priority first, got %+v", feeds)
+	}
+
+	if rec := patch(fmt.Sprintf(`{%q: 5, "missing": 1}`, low.ID)); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown ID, got %d", rec.Code)
+	}
+	if got, _ := s.GetFeed(low.ID); got.Priority != 1 {
+		t.Fatalf("expected nothing to change, priority is %d", got.Priority)
+	}
+	if rec := patch(`{}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty body, got %d", rec.Code)
+	}
+}
+
+func TestUpdateFeedKeywordRules(t *testing.T) {
+	srv, s := setup()
+	f := s.AddFeed("Noisy", "https://example.com/rss")
+
+	body := `{"exclude_keywords": ["sponsored"]}`
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/feeds/"+f.ID, strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	stored, _ := s.GetFeed(f.ID)
+	if len(stored.ExcludeKeywords) != 1 || stored.ExcludeKeywords[0] != "sponsored" || stored.Name != "Noisy" {
+		t.Fatalf("unexpected stored feed: %+v", stored)
+	}
+}
+
+func TestFeedTimezoneValidation(t *testing.T) {
+	srv, s := setup()
+
+	for body, want := range map[string]int{
+		`{"name": "Berlin", "url": "https://example.com/de", "timezone": "Europe/Berlin"}`: http.StatusCreated,
+		`{"name": "Bad", "url": "https://example.com/bad", "timezone": "Mars/Olympus"}`:    http.StatusBadRequest,
+		`{"name": "Local", "url": "https://example.com/local", "timezone": "Local"}`:       http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", strings.NewReader(body)))
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d: %s", body, want, rec.Code, rec.Body)
+		}
+	}
+
+	f := s.AddFeed("Tokyo", "https://example.com/jp")
+	for body, want := range map[string]int{
+		`{"timezone": "Not/AZone"}`:  http.StatusBadRequest,
+		`{"timezone": "Asia/Tokyo"}`: http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/feeds/"+f.ID, strings.NewReader(body)))
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d", body, want, rec.Code)
+		}
+	}
+	if stored, _ := s.GetFeed(f.ID); stored.Timezone != "Asia/Tokyo" {
+		t.Fatalf("expected timezone to be stored, got %q", stored.Timezone)
+	}
+}
+
+func TestFeedIDStrategyValidation(t *testing.T) {
+	srv, s := setup()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", strings.NewReader(`{"name": "A", "url": "https://example.com/a", "id_strategy": "title"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown strategy, got %d", rec.Code)
+	}
+
+	f := s.AddFeed("B", "https://example.com/b")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/feeds/"+f.ID, strings.NewReader(`{"id_strategy": "link"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if stored, _ := s.GetFeed(f.ID); stored.IDStrategy != models.IDStrategyLink {
+		t.Fatalf("expected the strategy to be stored, got %q", stored.IDStrategy)
+	}
+}
+
+func TestDeleteArticleEndpoint(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "f1", 2)
+	articles := s.ListArticles("", 0)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/articles/"+articles[0].ID+"?tombstone=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	if saved := s.SaveArticles(articles); saved != 0 {
+		t.Fatalf("expected tombstoned article not to be re-added, saved %d", saved)
+	}
+	if got := s.ListArticles("", 0); len(got) != 1 {
+		t.Fatalf("expected 1 article left, got %d", len(got))
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/articles/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestBlocklistEndpoints(t *testing.T) {
+	srv, s := setup()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/blocklist", strings.NewReader(`{"pattern": "https://spam.test/x"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	if !s.IsBlocked("https://www.spam.test/y") {
+		t.Fatal("expected spam.test to be blocked")
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/blocklist", strings.NewReader(`{"pattern": ""}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty pattern, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/blocklist/spam.test", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if len(s.Blocklist()) != 0 {
+		t.Fatalf("expected empty block list, got %v", s.Blocklist())
+	}
+}
+
+func TestFolderEndpoints(t *testing.T) {
+	srv, s := setup()
+
+	body := `{"name": "Tech", "url": "https://tech.example/rss", "folder": "news"}`
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	var tech models.Feed
+	json.NewDecoder(rec.Body).Decode(&tech)
+	other := s.AddFeed("Other", "https://other.example/rss")
+
+	saveArticles(s, tech.ID, 2)
+	saveArticles(s, other.ID, 3)
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/folders", nil))
+	var folders []models.FolderCount
+	json.NewDecoder(rec.Body).Decode(&folders)
+	if len(folders) != 2 || folders[0].Name != "news" || folders[1].Name != "uncategorized" {
+		t.Fatalf("unexpected folders: %+v", folders)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?folder=news", nil))
+	var articles []models.Article
+	json.NewDecoder(rec.Body).Decode(&articles)
+	if len(articles) != 2 {
+		t.Fatalf("expected 2 articles in news, got %d", len(articles))
+	}
+
+	// Moving the feed out of its folder makes it uncategorized.
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/feeds/"+tech.ID, strings.NewReader(`{"folder": ""}`)))
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?folder=uncategorized", nil))
+	json.NewDecoder(rec.Body).Decode(&articles)
+	if len(articles) != 5 {
+		t.Fatalf("expected 5 uncategorized articles, got %d", len(articles))
+	}
+}
+
+func TestMoveArticleEndpoint(t *testing.T) {
+	srv, s := setup()
+	from := s.AddFeed("From", "https://example.com/from")
+	to := s.AddFeed("To", "https://example.com/to")
+	saveArticles(s, from.ID, 1)
+	id := from.ID + "-0"
+
+	patch := func(id, body string) int {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/articles/"+id, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	if code := patch(id, `{"feed_id": "feed_missing"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 moving to a missing feed, got %d", code)
+	}
+	if code := patch("missing", `{"feed_id": "`+to.ID+`"}`); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing article, got %d", code)
+	}
+	if code := patch(id, `{"feed_id": "`+to.ID+`"}`); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if got := s.ListArticles(to.ID, 0); len(got) != 1 || got[0].ID != id || got[0].FeedName != "To" {
+		t.Fatalf("expected the article under To, got %+v", got)
+	}
+}
+
+func TestClearArticlesEndpoint(t *testing.T) {
+	srv, s := setup()
+	feed := s.AddFeed("Feed", "https://example.com/rss")
+	saveArticles(s, feed.ID, 3)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/articles", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without confirm, got %d", rec.Code)
+	}
+	if len(s.ListArticles("", 0)) != 3 {
+		t.Fatal("articles removed without confirmation")
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/articles?confirm=true", nil))
+	var body map[string]int
+	json.NewDecoder(rec.Body).Decode(&body)
+	if rec.Code != http.StatusOK || body["removed"] != 3 {
+		t.Fatalf("expected 200 removing 3, got %d %v", rec.Code, body)
+	}
+	if len(s.ListArticles("", 0)) != 0 || len(s.ListFeeds()) != 1 {
+		t.Fatal("expected articles gone and the feed kept")
+	}
+}
+
+func TestSaveLaterEndpoints(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "f1", 3)
+
+	for _, id := range []string{"f1-2", "f1-0"} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/articles/"+id+"/save-later", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?saved=true&sort=saved_desc", nil))
+	var articles []models.Article
+	json.NewDecoder(rec.Body).Decode(&articles)
+	if len(articles) != 2 || articles[0].ID != "f1-0" || articles[1].ID != "f1-2" {
+		t.Fatalf("expected f1-0 then f1-2, got %+v", articles)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/articles/f1-0/save-later", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if _, total := s.QueryArticles(store.ArticleQuery{Saved: true}); total != 1 {
+		t.Fatalf("expected 1 queued article, got %d", total)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/articles/missing/save-later", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	srv, _ := setup()
+
+	cases := []struct {
+		method, path, allow string
+	}{
+		{http.MethodPost, "/api/articles", "DELETE, GET, HEAD"},
+		{http.MethodPut, "/api/feeds", "GET, HEAD, POST"},
+		{http.MethodGet, "/api/feeds/feed_1", "DELETE, PATCH"},
+		{http.MethodDelete, "/api/health", "GET, HEAD"},
+		{http.MethodGet, "/api/feeds/validate", "POST"},
+	}
+
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("%s %s: expected 405, got %d", tc.method, tc.path, rec.Code)
+		}
+		if got := rec.Header().Get("Allow"); got != tc.allow {
+			t.Fatalf("%s %s: expected Allow %q, got %q", tc.method, tc.path, tc.allow, got)
+		}
+		if rec.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("%s %s: expected a JSON error body", tc.method, tc.path)
+		}
+	}
+
+	// Supported methods still reach their handlers.
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/api/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected HEAD on a GET route to succeed, got %d", rec.Code)
+	}
+}
+
+func TestFeedArticlesEndpoint(t *testing.T) {
+	srv, s := setup()
+	f := s.AddFeed("Blog", "https://example.com/rss")
+	saveArticles(s, f.ID, 5)
+	saveArticles(s, "other", 3)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feeds/"+f.ID+"/articles?limit=2&offset=1&sort=published_asc", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var articles []models.Article
+	json.NewDecoder(rec.Body).Decode(&articles)
+
+	// Oldest first: Article 4, 3, 2, ... — offset 1 starts at Article 3.
+	if len(articles) != 2 || articles[0].Title != "Article 3" || articles[1].Title != "Article 2" {
+		t.Fatalf("unexpected page: %+v", articles)
+	}
+	for _, a := range articles {
+		if a.FeedID != f.ID {
+			t.Fatalf("article from another feed leaked: %+v", a)
+		}
+	}
+}
+
+func TestFeedArticlesEndpointMissingFeed(t *testing.T) {
+	srv, _ := setup()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feeds/missing/articles", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestAddFeedRejectsInvalidURL(t *testing.T) {
+	srv, _ := setup()
+
+	body, _ := json.Marshal(models.AddFeedRequest{Name: "Bad", URL: "ftp://example.com/rss"})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestAddFeedRejectsInvalidFilter(t *testing.T) {
+	srv, _ := setup()
+
+	body, _ := json.Marshal(models.AddFeedRequest{
+		Name:    "Filtered",
+		URL:     "https://example.com/rss",
+		Filters: []models.Filter{{Type: models.FilterDropRegex, Value: "("}},
+	})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestAddFileFeedRequiresOptIn(t *testing.T) {
+	body, _ := json.Marshal(models.AddFeedRequest{Name: "Local", URL: "file:///var/feeds/local.xml"})
+
+	srv, _ := setup()
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", bytes.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 with file feeds disabled, got %d", rec.Code)
+	}
+
+	s := store.New()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	srv = api.New(s, fetcher.New(s, time.Minute, logger, fetcher.WithFileFeeds()), logger)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", bytes.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 with file feeds enabled, got %d", rec.Code)
+	}
+}
+
+func TestBatchAddFeedsEndpoint(t *testing.T) {
+	srv, s := setup()
+	s.AddFeed("Existing", "https://example.com/existing")
+
+	body, _ := json.Marshal(models.BatchAddFeedsRequest{Feeds: []models.AddFeedRequest{
+		{Name: "New", URL: "https://example.com/new"},
+		{Name: "Bad", URL: "not-a-url"},
+		{Name: "Dup", URL: "https://example.com/existing/"},
+		{Name: "", URL: "https://example.com/nameless"},
+		{Name: "New again", URL: "https://example.com/new"},
+	}})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds/batch", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var results []models.BatchAddResult
+	json.NewDecoder(rec.Body).Decode(&results)
+
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results, got %d", len(results))
+	}
+	if results[0].Feed == nil || results[0].Feed.Name != "New" {
+		t.Fatalf("expected first entry to be created, got %+v", results[0])
+	}
+	for i, want := range map[int]string{
+		1: models.ReasonInvalid,
+		2: models.ReasonDuplicate,
+		3: models.ReasonInvalid,
+		4: models.ReasonDuplicate,
+	} {
+		if results[i].Feed != nil || results[i].Reason != want {
+			t.Fatalf("entry %d: expected reason %q, got %+v", i, want, results[i])
+		}
+	}
+
+	if len(s.ListFeeds()) != 2 {
+		t.Fatalf("expected 2 feeds after partial success, got %d", len(s.ListFeeds()))
+	}
+}
+
+func TestBatchDeleteFeedsEndpoint(t *testing.T) {
+	srv, s := setup()
+	a := s.AddFeed("A", "https://example.com/a")
+	b := s.AddFeed("B", "https://example.com/b")
+	keep := s.AddFeed("Keep", "https://example.com/keep")
+	saveArticles(s, a.ID, 3)
+	saveArticles(s, keep.ID, 1)
+
+	body, _ := json.Marshal(models.BatchDeleteFeedsRequest{IDs: []string{a.ID, "missing", b.ID, a.ID}})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds/batch-delete", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var results []models.BatchDeleteResult
+	json.NewDecoder(rec.Body).Decode(&results)
+	want := []models.BatchDeleteResult{
+		{ID: a.ID, Result: models.ResultRemoved, ArticlesRemoved: 3},
+		{ID: "missing", Result: models.ResultNotFound},
+		{ID: b.ID, Result: models.ResultRemoved},
+		{ID: a.ID, Result: models.ResultNotFound},
+	}
+	if !slices.Equal(results, want) {
+		t.Fatalf("expected %+v, got %+v", want, results)
+	}
+	if feeds := s.ListFeeds(); len(feeds) != 1 || feeds[0].ID != keep.ID {
+		t.Fatalf("expected only the kept feed to remain, got %+v", feeds)
+	}
+	if n := s.ArticleCount(); n != 1 {
+		t.Fatalf("expected the kept feed's article to remain, got %d articles", n)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds/batch-delete", strings.NewReader(`{"ids": []}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for no ids, got %d", rec.Code)
+	}
+}
+
+func TestMergeFeedsEndpoint(t *testing.T) {
+	srv, s := setup()
+	primary := s.AddFeed("Primary", "https://example.com/feed")
+	dup := s.AddFeed("Duplicate", "https://example.com/feed.xml")
+	saveArticles(s, dup.ID, 2)
+
+	merge := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds/merge", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := merge(fmt.Sprintf(`{"primary_id": %q, "duplicate_id": %q}`, primary.ID, dup.ID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		ArticlesMoved int `json:"articles_moved"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.ArticlesMoved != 2 {
+		t.Fatalf("expected 2 articles moved, got %d", resp.ArticlesMoved)
+	}
+	if n := s.FeedArticleCount(primary.ID); n != 2 {
+		t.Fatalf("expected the articles under the primary feed, got %d", n)
+	}
+	if _, ok := s.GetFeed(dup.ID); ok {
+		t.Fatal("expected the duplicate feed to be removed")
+	}
+
+	for body, want := range map[string]int{
+		`{"primary_id": "x"}`: http.StatusBadRequest,
+		fmt.Sprintf(`{"primary_id": %q, "duplicate_id": %q}`, primary.ID, primary.ID): http.StatusBadRequest,
+		fmt.Sprintf(`{"primary_id": %q, "duplicate_id": %q}`, primary.ID, dup.ID):     http.StatusNotFound,
+		fmt.Sprintf(`{"primary_id": "missing", "duplicate_id": %q}`, primary.ID):      http.StatusNotFound,
+	} {
+		if rec := merge(body); rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d", body, want, rec.Code)
+		}
+	}
+	if _, ok := s.GetFeed(primary.ID); !ok {
+		t.Fatal("a failed merge must not remove the feed")
+	}
+}
+
+func TestLastCycleEndpoint(t *testing.T) {
+	srv, s := setup()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/fetch/last", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 before any cycle, got %d", rec.Code)
+	}
+
+	s.SetLastCycle(models.CycleSummary{Total: 3, Succeeded: 2, Failed: 1, NewArticles: 7})
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/fetch/last", nil))
+
+	var summary models.CycleSummary
+	json.NewDecoder(rec.Body).Decode(&summary)
+	if summary.Failed != 1 || summary.NewArticles != 7 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestListArticlesAfterSeq(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "f1", 3)
+	saveArticles(s, "f2", 2)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?after_seq=2&limit=2", nil))
+
+	var articles []models.Article
+	json.NewDecoder(rec.Body).Decode(&articles)
+	if len(articles) != 2 || articles[0].Seq != 3 || articles[1].Seq != 4 {
+		t.Fatalf("expected seqs 3 and 4 in save order, got %+v", articles)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?after_seq=-1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a negative cursor, got %d", rec.Code)
+	}
+}
+
+func TestListArticlesMultipleFeeds(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "f1", 2)
+	saveArticles(s, "f2", 3)
+	saveArticles(s, "f3", 4)
+
+	for _, query := range []string{"feed_id=f1,f3", "feed_id=f1&feed_id=f3"} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?"+query, nil))
+
+		var articles []models.Article
+		json.NewDecoder(rec.Body).Decode(&articles)
+
+		if len(articles) != 6 {
+			t.Fatalf("%s: expected 6 articles, got %d", query, len(articles))
+		}
+		for _, a := range articles {
+			if a.FeedID == "f2" {
+				t.Fatalf("%s: article from unrequested feed f2", query)
+			}
+		}
+	}
+}
+
+func TestTrendingEndpoint(t *testing.T) {
+	srv, s := setup(api.WithTrendingWindow(time.Hour))
+	now := time.Now()
+	s.SaveArticles([]models.Article{
+		{ID: "recent", FeedID: "f1", PublishedAt: now.Add(-10 * time.Minute)},
+		{ID: "older", FeedID: "f1", PublishedAt: now.Add(-3 * time.Hour)},
+	})
+
+	for query, want := range map[string]int{"": 1, "?window=6h": 2, "?window=6h&limit=1": 1} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles/trending"+query, nil))
+		var articles []models.Article
+		json.NewDecoder(rec.Body).Decode(&articles)
+		if rec.Code != http.StatusOK || len(articles) != want || articles[0].ID != "recent" {
+			t.Fatalf("%q: expected %d articles led by recent, got %d %+v", query, want, rec.Code, articles)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles/trending?window=soon", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad window, got %d", rec.Code)
+	}
+}
+
+func TestListArticlesGivesUpWhenRequestIsCancelled(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "f1", 3000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles", nil).WithContext(ctx))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for a cancelled request, got %d", rec.Code)
+	}
+}
+
+func TestOversizedBodiesAreRejected(t *testing.T) {
+	srv, s := setup(api.WithMaxBodyBytes(1024))
+
+	padding := strings.Repeat("x", 2048)
+	cases := []struct{ path, body string }{
+		{"/api/feeds", `{"name": "Big", "url": "https://example.com/rss", "folder": "` + padding + `"}`},
+		{"/api/feeds/batch", `{"feeds": [{"name": "` + padding + `", "url": "https://example.com/rss"}]}`},
+		{"/api/feeds/import", `<opml version="2.0"><body><outline text="` + padding + `" xmlUrl="https://example.com/rss"/></body></opml>`},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(c.body)))
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("%s: expected 413, got %d %s", c.path, rec.Code, rec.Body)
+		}
+	}
+	if n := len(s.ListFeeds()); n != 0 {
+		t.Fatalf("expected no feeds added, got %d", n)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", strings.NewReader(`{"name": "Small", "url": "https://example.com/rss"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected a small body to be accepted, got %d", rec.Code)
+	}
+}
+
+func TestUnknownJSONFieldsAreRejected(t *testing.T) {
+	srv, s := setup()
+
+	cases := []struct{ method, path, body, field string }{
+		{http.MethodPost, "/api/feeds", `{"nme": "Typo", "url": "https://example.com/rss"}`, `"nme"`},
+		{http.MethodPost, "/api/feeds/batch", `{"feeds": [{"name": "A", "url": "https://example.com/a", "folde": "x"}]}`, `"folde"`},
+		{http.MethodPost, "/api/feeds/validate", `{"url": "https://example.com/rss", "timeout": 5}`, `"timeout"`},
+		{http.MethodPost, "/api/blocklist", `{"domain": "spam.test"}`, `"domain"`},
+		{http.MethodPatch, "/api/config/fetch-interval", `{"every": "5m"}`, `"every"`},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+		var resp map[string]string
+		json.NewDecoder(rec.Body).Decode(&resp)
+		if rec.Code != http.StatusBadRequest || resp["error"] != "unknown field "+c.field {
+			t.Fatalf("%s %s: expected 400 naming %s, got %d %v", c.method, c.path, c.field, rec.Code, resp)
+		}
+	}
+
+	feed := s.AddFeed("Existing", "https://example.com/existing")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/feeds/"+feed.ID, strings.NewReader(`{"titel": "Renamed"}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `titel`) {
+		t.Fatalf("expected 400 naming titel, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestClientIPHonoursTrustedProxies(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		want       string
+	}{
+		{"untrusted peer ignores header", "198.51.100.9:4000", []string{"203.0.113.7"}, "198.51.100.9"},
+		{"trusted peer uses header", "10.1.2.3:4000", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"rightmost untrusted hop wins", "10.1.2.3:4000", []string{"192.0.2.1, 203.0.113.7, 10.9.9.9"}, "203.0.113.7"},
+		{"repeated headers are joined", "10.1.2.3:4000", []string{"192.0.2.1", "203.0.113.7"}, "203.0.113.7"},
+		{"spoofed leftmost entry is skipped", "10.1.2.3:4000", []string{"1.1.1.1, 203.0.113.7"}, "203.0.113.7"},
+		{"trusted peer without header", "10.1.2.3:4000", nil, "10.1.2.3"},
+		{"malformed hop stops the walk", "10.1.2.3:4000", []string{"203.0.113.7, not-an-ip"}, "10.1.2.3"},
+		{"ipv6 trusted peer", "[fd00::1]:4000", []string{"2001:db8::5"}, "2001:db8::5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			srv, _ := setup(api.WithTrustedProxies(trusted), api.WithAuditLogger(audit.New(&buf)))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/feeds", strings.NewReader(`{"name": "X", "url": "https://example.com/rss"}`))
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			srv.ServeHTTP(httptest.NewRecorder(), req)
+
+			var entry map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("no audit entry: %v", err)
+			}
+			if entry["client_ip"] != tt.want {
+				t.Fatalf("expected client_ip %s, got %v", tt.want, entry["client_ip"])
+			}
+		})
+	}
+}
+
+func TestListEndpointsNegotiateContentType(t *testing.T) {
+	srv, s := setup()
+	feed := s.AddFeed("Go Blog", "https://go.dev/blog/feed.atom")
+	saveArticles(s, feed.ID, 2)
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, accept := range []string{"", "application/json", "*/*", "application/xml;q=0.5, application/json"} {
+		rec := get("/api/feeds", accept)
+		var feeds []models.Feed
+		if err := json.NewDecoder(rec.Body).Decode(&feeds); err != nil || rec.Header().Get("Content-Type") != "application/json" || len(feeds) != 1 {
+			t.Fatalf("Accept %q: expected a JSON list, got %s (%v)", accept, rec.Header().Get("Content-Type"), err)
+		}
+	}
+
+	rec := get("/api/feeds", "application/xml")
+	var feeds struct {
+		Feeds []models.Feed `xml:"Feed"`
+	}
+	if err := xml.NewDecoder(rec.Body).Decode(&feeds); err != nil || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/xml") {
+		t.Fatalf("expected XML feeds, got %s (%v)", rec.Header().Get("Content-Type"), err)
+	}
+	if len(feeds.Feeds) != 1 || feeds.Feeds[0].ID != feed.ID {
+		t.Fatalf("expected the feed in XML, got %+v", feeds)
+	}
+
+	rec = get("/api/articles", "text/xml, application/json;q=0.1")
+	var articles struct {
+		Articles []models.Article `xml:"Article"`
+	}
+	if err := xml.NewDecoder(rec.Body).Decode(&articles); err != nil || len(articles.Articles) != 2 {
+		t.Fatalf("expected 2 XML articles, got %+v (%v)", articles, err)
+	}
+
+	rec = get("/api/articles?envelope=true", "application/xml")
+	var page models.ArticlePage
+	if err := xml.NewDecoder(rec.Body).Decode(&page); err != nil || page.Total != 2 || len(page.Data) != 2 {
+		t.Fatalf("expected an XML page of 2, got %+v (%v)", page, err)
+	}
+
+	if rec = get("/api/articles", "text/html"); rec.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406 for text/html, got %d", rec.Code)
+	}
+}
+
+func BenchmarkListArticles(b *testing.B) {
+	srv, s := setup(api.WithArticleLimits(500, 500))
+	saveArticles(s, "f1", 100_000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/articles", nil))
+	}
+}
+
+func TestArticleCache(t *testing.T) {
+	srv, s := setup(api.WithArticleCacheTTL(time.Minute))
+	saveArticles(s, "f1", 3)
+
+	list := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles"+query, nil))
+		return rec
+	}
+
+	first := list("?limit=2")
+	if got := first.Header().Get("X-Cache"); got != "MISS" {
+		t.Fatalf("expected a miss on the first request, got %q", got)
+	}
+	second := list("?limit=2")
+	if got := second.Header().Get("X-Cache"); got != "HIT" {
+		t.Fatalf("expected an identical request to hit the cache, got %q", got)
+	}
+	if second.Body.String() != first.Body.String() || second.Header().Get("Link") != first.Header().Get("Link") {
+		t.Fatal("a cached response must match the original")
+	}
+	if got := list("?limit=3").Header().Get("X-Cache"); got != "MISS" {
+		t.Fatalf("expected different parameters to miss, got %q", got)
+	}
+
+	s.SaveArticles([]models.Article{{ID: "new", FeedID: "f1", Title: "New", PublishedAt: time.Now().Add(time.Minute)}})
+	rec := list("?limit=2")
+	if got := rec.Header().Get("X-Cache"); got != "MISS" {
+		t.Fatalf("expected a save to invalidate the cache, got %q", got)
+	}
+	var articles []models.Article
+	json.NewDecoder(rec.Body).Decode(&articles)
+	if len(articles) == 0 || articles[0].ID != "new" {
+		t.Fatalf("expected the new article first, got %+v", articles)
+	}
+}
+
+func TestArticleCacheOffByDefault(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "f1", 1)
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles", nil))
+		if got := rec.Header().Get("X-Cache"); got != "" {
+			t.Fatalf("expected no caching without a TTL, got X-Cache %q", got)
+		}
+	}
+}
+
+func TestDiscoverFeedEndpoint(t *testing.T) {
+	srv, s := setup()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>Home</body></html>"))
+		case "/index.xml":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			w.Write([]byte(rssFixture))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer site.Close()
+	bare := serveFixture(t, "text/html", "<html><body>No feeds here</body></html>")
+
+	discover := func(url string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.DiscoverFeedRequest{URL: url})
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds/discover", bytes.NewReader(body)))
+		return rec
+	}
+
+	rec := discover(site.URL)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var found models.FeedDiscovery
+	json.NewDecoder(rec.Body).Decode(&found)
+	if found.FeedURL != site.URL+"/index.xml" || found.Via != models.DiscoveredProbe || found.Title != "Fixture Feed" {
+		t.Fatalf("unexpected discovery: %+v", found)
+	}
+	if len(s.ListFeeds()) != 0 {
+		t.Fatal("discovery must not store the feed")
+	}
+
+	if rec := discover(bare.URL); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a site without a feed, got %d", rec.Code)
+	}
+	if rec := discover("ftp://example.com"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a non-http URL, got %d", rec.Code)
+	}
+}
+
+func TestFeedRawEndpoint(t *testing.T) {
+	srv, s := setup()
+
+	body, _ := json.Marshal(models.AddFeedRequest{Name: "Kept", URL: "https://example.com/kept.xml", KeepRaw: true})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feeds", bytes.NewReader(body)))
+	var kept models.Feed
+	json.NewDecoder(rec.Body).Decode(&kept)
+	if !kept.KeepRaw {
+		t.Fatalf("expected keep_raw to be set, got %+v", kept)
+	}
+	plain := s.AddFeed("Plain", "https://example.com/plain.xml")
+
+	get := func(id string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feeds/"+id+"/raw", nil))
+		return rec
+	}
+
+	if rec := get(kept.ID); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 before any fetch, got %d", rec.Code)
+	}
+
+	s.SetRawResponse(kept.ID, models.RawResponse{Body: []byte("<rss>odd</rss>"), ContentType: "text/xml", FetchedAt: time.Now()})
+	s.SetRawResponse(plain.ID, models.RawResponse{Body: []byte("<rss/>"), ContentType: "text/xml", FetchedAt: time.Now()})
+
+	rec = get(kept.ID)
+	if rec.Code != http.StatusOK || rec.Body.String() != "<rss>odd</rss>" || rec.Header().Get("X-Original-Content-Type") != "text/xml" {
+		t.Fatalf("unexpected raw response: %d %q %q", rec.Code, rec.Header().Get("X-Original-Content-Type"), rec.Body.String())
+	}
+
+	// A feed answering with HTML must not get it rendered on our origin.
+	s.SetRawResponse(kept.ID, models.RawResponse{Body: []byte("<script>alert(1)</script>"), ContentType: "text/html", FetchedAt: time.Now()})
+	rec = get(kept.ID)
+	for header, want := range map[string]string{
+		"Content-Type":            "text/plain; charset=utf-8",
+		"X-Original-Content-Type": "text/html",
+		"X-Content-Type-Options":  "nosniff",
+		"Content-Security-Policy": "sandbox",
+		"Content-Disposition":     "attachment",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Fatalf("expected %s %q for an HTML body, got %q", header, want, got)
+		}
+	}
+	if rec := get(plain.ID); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a feed without keep_raw, got %d", rec.Code)
+	}
+
+	off := false
+	body, _ = json.Marshal(models.UpdateFeedRequest{KeepRaw: &off})
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/feeds/"+kept.ID, bytes.NewReader(body)))
+	if rec := get(kept.ID); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected turning keep_raw off to drop the response, got %d", rec.Code)
+	}
+}
+
+func TestListArticlesPerFeedLimit(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "busy", 60)
+	s.SaveArticles([]models.Article{{ID: "quiet-0", FeedID: "quiet", Title: "Quiet", PublishedAt: time.Now().Add(-2 * time.Hour)}})
+
+	list := func(query string) (*httptest.ResponseRecorder, []models.Article) {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles"+query, nil))
+		var articles []models.Article
+		json.NewDecoder(rec.Body).Decode(&articles)
+		return rec, articles
+	}
+
+	_, articles := list("")
+	if slices.ContainsFunc(articles, func(a models.Article) bool { return a.FeedID == "quiet" }) {
+		t.Fatal("expected the busy feed to fill the default page")
+	}
+
+	rec, articles := list("?per_feed_limit=10")
+	if rec.Code != http.StatusOK || len(articles) != 11 {
+		t.Fatalf("expected 11 articles, got %d: %d", rec.Code, len(articles))
+	}
+	if articles[10].ID != "quiet-0" {
+		t.Fatalf("expected the quiet feed to appear after the busy feed's 10, got %+v", articles[10])
+	}
+
+	for _, query := range []string{"?per_feed_limit=0", "?per_feed_limit=x", "?per_feed_limit=5&before="} {
+		if rec, _ := list(query); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestListArticlesBalancedMix(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "f1", 3)
+	now := time.Now()
+	s.SaveArticles([]models.Article{
+		{ID: "f2-0", FeedID: "f2", PublishedAt: now.Add(-time.Hour)},
+		{ID: "f2-1", FeedID: "f2", PublishedAt: now.Add(-2 * time.Hour)},
+	})
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?mix=balanced&limit=4", nil))
+	var articles []models.Article
+	json.NewDecoder(rec.Body).Decode(&articles)
+
+	var feeds []string
+	for _, a := range articles {
+		feeds = append(feeds, a.FeedID)
+	}
+	if want := []string{"f1", "f2", "f1", "f2"}; !slices.Equal(feeds, want) {
+		t.Fatalf("expected feeds to alternate as %v, got %v", want, feeds)
+	}
+
+	for _, query := range []string{"?mix=random", "?mix=balanced&before="} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestListArticlesJSONP(t *testing.T) {
+	srv, s := setup()
+	saveArticles(s, "f1", 2)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?callback=widget.onArticles", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/javascript") {
+		t.Fatalf("expected a JavaScript response, got %q", ct)
+	}
+	body := strings.TrimSpace(rec.Body.String())
+	inner, ok := strings.CutPrefix(body, "/**/widget.onArticles(")
+	if !ok || !strings.HasSuffix(inner, ");") {
+		t.Fatalf("expected the JSON wrapped in the callback, got %q", body)
+	}
+	var articles []models.Article
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(inner, ");")), &articles); err != nil || len(articles) != 2 {
+		t.Fatalf("expected 2 articles in the callback, got %d (err %v)", len(articles), err)
+	}
+
+	for _, callback := range []string{"alert(1);cb", "1abc", "cb.", "a b", strings.Repeat("a", 65)} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/articles?callback="+url.QueryEscape(callback), nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%q: expected 400, got %d", callback, rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "alert") {
+			t.Fatalf("%q: expected the callback not to be echoed, got %q", callback, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// articleCacheSize bounds how many distinct article queries are cached; the
+// least recently used is dropped beyond it.
+const articleCacheSize = 128
+
+// WithArticleCacheTTL caches /api/articles responses for up to d, keyed by
+// the query parameters and response format. Any change to the stored
+// articles invalidates every entry at once. 0 disables the cache.
+func WithArticleCacheTTL(d time.Duration) Option {
+	return func(s *Server) {
+		if d > 0 {
+			s.articleCache.ttl = d
+		}
+	}
+}
+
+// articleCache is an LRU of serialized article responses. An entry is only
+// served while the store generation it was built from is current.
+type articleCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	order   *list.List // of *cachedResponse, most recently used first
+	entries map[string]*list.Element
+}
+
+type cachedResponse struct {
+	key        string
+	generation uint64
+	expires    time.Time
+
+	contentType string
+	link        string
+	body        []byte
+}
+
+// get returns the entry for key if it is neither expired nor built from an
+// older generation, dropping it if it is.
+func (c *articleCache) get(key string, generation uint64, now time.Time) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cachedResponse)
+	if e.generation != generation || now.After(e.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e, true
+}
+
+// put stores e, evicting the least recently used entry when full.
+func (c *articleCache) put(e *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[e.key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[e.key] = c.order.PushFront(e)
+	if c.order.Len() > articleCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// cachedArticles wraps an article listing handler with the article cache.
+// Responses carry X-Cache: HIT or MISS; only 200s are stored.
+func (s *Server) cachedArticles(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.articleCache.ttl == 0 {
+			h(w, r)
+			return
+		}
+
+		format, _ := negotiate(r.Header.Get("Accept"))
+		key := format + "\n" + r.URL.Query().Encode()
+		// Read before the query runs, so a save landing mid-query leaves
+		// the entry already stale rather than caching old data as new.
+		generation := s.store.Generation()
+
+		if e, ok := s.articleCache.get(key, generation, time.Now()); ok {
+			w.Header().Set("Content-Type", e.contentType)
+			if e.link != "" {
+				w.Header().Set("Link", e.link)
+			}
+			w.Header().Set("X-Cache", "HIT")
+			w.WriteHeader(http.StatusOK)
+			w.Write(e.body)
+			return
+		}
+
+		w.Header().Set("X-Cache", "MISS")
+		rec := &responseRecorder{ResponseWriter: w}
+		h(rec, r)
+		if rec.status != http.StatusOK {
+			return
+		}
+		s.articleCache.put(&cachedResponse{
+			key:         key,
+			generation:  generation,
+			expires:     time.Now().Add(s.articleCache.ttl),
+			contentType: rec.Header().Get("Content-Type"),
+			link:        rec.Header().Get("Link"),
+			body:        rec.body.Bytes(),
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// WithTrustedProxies lists the networks of load balancers and reverse
+// proxies in front of the server. X-Forwarded-For is only believed on
+// requests arriving directly from one of them.
+func WithTrustedProxies(prefixes []netip.Prefix) Option {
+	return func(s *Server) {
+		s.trustedProxies = prefixes
+	}
+}
+
+type clientIPKey struct{}
+
+// clientIPFrom returns the client IP stored in ctx by ServeHTTP.
+func clientIPFrom(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// clientIP returns the address of the client behind r. When the direct
+// peer is a trusted proxy, X-Forwarded-For is read from the right and the
+// first hop that is not itself a trusted proxy is the client; otherwise,
+// or if the header is missing or malformed, the peer address is used.
+func (s *Server) clientIP(r *http.Request) string {
+	peer, ok := parseIP(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !s.trusted(peer) {
+		return peer.String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseIP(strings.TrimSpace(hops[i]))
+		if !ok {
+			break
+		}
+		client = hop
+		if !s.trusted(hop) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// trusted reports whether ip belongs to a trusted proxy.
+func (s *Server) trusted(ip netip.Addr) bool {
+	for _, p := range s.trustedProxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIP reads an IP address with or without a port, unmapping IPv4
+// addresses carried in IPv6 form.
+func parseIP(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithIdempotencyTTL sets how long a response stored under an
+// Idempotency-Key is replayed to retries of the same request.
+func WithIdempotencyTTL(d time.Duration) Option {
+	return func(s *Server) {
+		if d > 0 {
+			s.idempotency.ttl = d
+		}
+	}
+}
+
+// idempotencySweepEvery bounds how often begin walks the whole cache for
+// expired entries.
+const idempotencySweepEvery = time.Minute
+
+// idempotencyCache remembers recent responses by Idempotency-Key.
+type idempotencyCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]*idempotentResponse
+	nextSweep time.Time // expired entries are next dropped after this
+}
+
+// idempotentResponse is a stored response. done is false while the first
+// request with the key is still being handled.
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	done        bool
+	expires     time.Time
+
+	status      int
+	contentType string
+	body        []byte
+}
+
+// begin claims key for a request with the given fingerprint. If the key is
+// already known and not expired it returns the existing entry and false;
+// otherwise it returns a new pending entry and true. Other expired entries
+// are dropped at most once per idempotencySweepEvery.
+func (c *idempotencyCache) begin(key string, fingerprint [sha256.Size]byte, now time.Time) (*idempotentResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.After(c.nextSweep) {
+		for k, e := range c.entries {
+			if e.expired(now) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextSweep = now.Add(idempotencySweepEvery)
+	}
+	if e, ok := c.entries[key]; ok && !e.expired(now) {
+		copied := *e
+		return &copied, false
+	}
+	e := &idempotentResponse{fingerprint: fingerprint}
+	c.entries[key] = e
+	return e, true
+}
+
+// expired reports whether a stored response is past its TTL. Pending
+// entries never expire; abandon removes those of failed requests.
+func (e *idempotentResponse) expired(now time.Time) bool {
+	return e.done && now.After(e.expires)
+}
+
+// abandon forgets key, claimed by a request whose handler panicked, so that
+// retries are handled afresh rather than refused as in progress.
+func (c *idempotencyCache) abandon(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+// finish stores the response for key, or forgets the key when the response
+// should not be replayed.
+func (c *idempotencyCache) finish(key string, rec *responseRecorder, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if rec.status >= http.StatusInternalServerError {
+		delete(c.entries, key)
+		return
+	}
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	e := c.entries[key]
+	e.done = true
+	e.expires = now.Add(c.ttl)
+	e.status = rec.status
+	e.contentType = rec.Header().Get("Content-Type")
+	e.body = rec.body.Bytes()
+}
+
+// responseRecorder passes a response through while keeping a copy.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// idempotent wraps h so that requests carrying an Idempotency-Key header
+// are handled once: retries with the same key and body within the TTL get
+// the first response replayed. Reusing a key with a different body is
+// rejected with 422, and a retry arriving while the first request is still
+// running gets 409. Server errors and panics are not stored, so they can
+// be retried.
+func (s *Server) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			h(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeBodyError(w, err, "cannot read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+
+		e, fresh := s.idempotency.begin(key, fingerprint, time.Now())
+		switch {
+		case fresh:
+			rec := &responseRecorder{ResponseWriter: w}
+			finished := false
+			defer func() {
+				if !finished {
+					s.idempotency.abandon(key)
+				}
+			}()
+			h(rec, r)
+			s.idempotency.finish(key, rec, time.Now())
+			finished = true
+		case e.fingerprint != fingerprint:
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Idempotency-Key was already used for a different request"})
+		case !e.done:
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "a request with this Idempotency-Key is still in progress"})
+		default:
+			w.Header().Set("Content-Type", e.contentType)
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(e.status)
+			w.Write(e.body)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeyIsReleasedAfterPanic(t *testing.T) {
+	s := &Server{idempotency: idempotencyCache{ttl: time.Hour, entries: make(map[string]*idempotentResponse)}}
+
+	calls := 0
+	h := s.idempotent(func(w http.ResponseWriter, _ *http.Request) {
+		if calls++; calls == 1 {
+			panic(http.ErrAbortHandler)
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	post := func() (code int, panicked bool) {
+		defer func() {
+			if recover() != nil {
+				panicked = true
+			}
+		}()
+		req := httptest.NewRequest(http.MethodPost, "/api/feeds", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "k1")
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec.Code, false
+	}
+
+	if _, panicked := post(); !panicked {
+		t.Fatal("expected the first request to panic")
+	}
+	if code, _ := post(); code != http.StatusCreated {
+		t.Fatalf("expected the retry to be handled afresh, got %d", code)
+	}
+	if calls != 2 {
+		t.Fatalf("expected the handler to run twice, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyCacheSweepsAtMostOncePerInterval(t *testing.T) {
+	c := idempotencyCache{ttl: time.Second, entries: make(map[string]*idempotentResponse)}
+	save := func(key string, at time.Time) {
+		c.begin(key, [32]byte{}, at)
+		c.finish(key, &responseRecorder{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}, at)
+	}
+	now := time.Now()
+	save("old", now)
+
+	// Expired entries are never replayed, even before a sweep drops them.
+	later := now.Add(2 * time.Second)
+	if _, fresh := c.begin("old", [32]byte{1}, later); !fresh {
+		t.Fatal("expected an expired key to be claimable again")
+	}
+
+	save("other", later)
+	c.begin("third", [32]byte{}, later.Add(2*time.Second))
+	if _, ok := c.entries["other"]; !ok {
+		t.Fatal("expected no sweep within the sweep interval")
+	}
+
+	c.begin("fourth", [32]byte{}, later.Add(idempotencySweepEvery+time.Second))
+	if _, ok := c.entries["other"]; ok {
+		t.Fatal("expected the expired entry swept once the interval passed")
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+// maxCallbackLength bounds a JSONP callback name.
+const maxCallbackLength = 64
+
+// callbackName matches a JavaScript identifier or a dotted path of them,
+// such as "render" or "widget.onArticles". Anything else could smuggle
+// script into the response, so it is refused rather than escaped.
+var callbackName = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+func validCallback(name string) bool {
+	return len(name) <= maxCallbackLength && callbackName.MatchString(name)
+}
+
+// writeJSONP writes data as JSON wrapped in a call to callback, which must
+// already have passed validCallback. The leading empty comment keeps the
+// body from starting with attacker-chosen bytes, and nosniff stops it
+// being read as anything but script. encoding/json escapes U+2028 and
+// U+2029, so the JSON is also valid JavaScript.
+func writeJSONP(w http.ResponseWriter, status int, callback string, data any) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "cannot encode response"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	io.WriteString(w, "/**/"+callback+"(")
+	w.Write(body)
+	io.WriteString(w, ");\n")
+}
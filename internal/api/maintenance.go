@@ -0,0 +1,35 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// WithMaintenanceToken enables the /api/maintenance endpoints for requests
+// sending token as "Authorization: Bearer <token>". Without a token they
+// are disabled and answer 403.
+func WithMaintenanceToken(token string) Option {
+	return func(s *Server) {
+		s.maintenanceToken = token
+	}
+}
+
+// maintenance guards h, which can change or delete data in bulk, with the
+// maintenance token: 403 when none is configured, 401 when the request
+// does not carry it.
+func (s *Server) maintenance(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.maintenanceToken == "" {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "maintenance endpoints are disabled"})
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.maintenanceToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="maintenance"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid maintenance token"})
+			return
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+)
+
+// Response formats a client can ask for with Accept.
+const (
+	formatJSON = "application/json"
+	formatXML  = "application/xml"
+)
+
+// negotiate picks the response format for an Accept header, preferring
+// JSON when the client accepts either. It returns false if the client
+// accepts neither.
+func negotiate(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return formatJSON, true
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		var format string
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			format = formatJSON
+		case "application/xml", "text/xml":
+			format = formatXML
+		default:
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best, best != ""
+}
+
+// xmlFeeds and xmlArticles give lists a single root element in XML.
+type xmlFeeds struct {
+	XMLName xml.Name      `xml:"Feeds"`
+	Feeds   []models.Feed `xml:"Feed"`
+}
+
+type xmlArticles struct {
+	XMLName  xml.Name         `xml:"Articles"`
+	Articles []models.Article `xml:"Article"`
+}
+
+// writeResponse writes data as JSON or XML, whichever the request's Accept
+// header prefers, or 406 if it accepts neither.
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, data any) {
+	format, ok := negotiate(r.Header.Get("Accept"))
+	if !ok {
+		writeJSON(w, http.StatusNotAcceptable, map[string]string{"error": "supported types are application/json and application/xml"})
+		return
+	}
+	if format == formatJSON {
+		writeJSON(w, status, data)
+		return
+	}
+
+	switch v := data.(type) {
+	case []models.Feed:
+		data = xmlFeeds{Feeds: v}
+	case []models.Article:
+		data = xmlArticles{Articles: v}
+	}
+	body, err := xml.Marshal(data)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "cannot encode response as XML"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
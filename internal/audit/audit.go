@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+)
+
+// Event names recorded in the audit trail.
+const (
+	FeedAdded   = "feed_added"
+	FeedRemoved = "feed_removed"
+	FeedUpdated = "feed_updated"
+)
+
+// Logger writes an append-only trail of feed mutations as JSON lines,
+// kept separate from the application log. Every line carries
+// "log":"audit", so the trail can still be picked out when it shares a
+// stream with other logs.
+type Logger struct {
+	logger *slog.Logger
+}
+
+// New returns a Logger that writes one JSON object per event to w.
+func New(w io.Writer) *Logger {
+	return &Logger{logger: slog.New(slog.NewJSONHandler(w, nil)).With(slog.String("log", "audit"))}
+}
+
+// Open resolves an audit destination: "stdout" and "stderr", or "" for
+// stderr, map to the standard streams; anything else is a file opened for
+// appending. The application log goes to stdout, so the default keeps the
+// two apart. The returned closer must be closed on shutdown.
+func Open(dest string) (*Logger, io.Closer, error) {
+	switch dest {
+	case "stdout":
+		return New(os.Stdout), nopCloser{}, nil
+	case "", "stderr":
+		return New(os.Stderr), nopCloser{}, nil
+	}
+
+	f, err := os.OpenFile(dest, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return New(f), f, nil
+}
+
+// nopCloser stands in for the standard streams, which must stay open.
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// Record appends a single event for feed, made by the client at clientIP.
+func (l *Logger) Record(ctx context.Context, event string, feed models.Feed, requestID, clientIP string) {
+	l.logger.LogAttrs(ctx, slog.LevelInfo, "audit",
+		slog.String("event", event),
+		slog.String("feed_id", feed.ID),
+		slog.String("url", feed.URL),
+		slog.Time("timestamp", time.Now().UTC()),
+		slog.String("request_id", requestID),
+		slog.String("client_ip", clientIP),
+	)
+}
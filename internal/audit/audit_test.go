@@ -0,0 +1,40 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/audit"
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+)
+
+func TestRecordWritesStructuredEvent(t *testing.T) {
+	var buf bytes.Buffer
+	a := audit.New(&buf)
+
+	a.Record(context.Background(), audit.FeedAdded, models.Feed{ID: "f1", URL: "https://example.com/rss"}, "req-1", "203.0.113.7")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("audit entry is not JSON: %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"log":        "audit",
+		"event":      audit.FeedAdded,
+		"feed_id":    "f1",
+		"url":        "https://example.com/rss",
+		"request_id": "req-1",
+		"client_ip":  "203.0.113.7",
+	} {
+		if entry[key] != want {
+			t.Fatalf("expected %s=%q, got %v", key, want, entry[key])
+		}
+	}
+
+	if _, ok := entry["timestamp"]; !ok {
+		t.Fatal("expected a timestamp")
+	}
+}
@@ -0,0 +1,316 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the runtime settings read from the environment.
+type Config struct {
+	Port                string
+	MaxBodyBytes        int // request body size limit
+	MaxFeedBytes        int // fetched feed size limit, after decompression
+	FetchInterval       time.Duration
+	DefaultArticleLimit int
+	MaxArticleLimit     int
+	FetchHistorySize    int
+	ShutdownTimeout     time.Duration
+	StartupSpread       time.Duration
+	MaxArticlesPerFetch int           // 0 means unlimited
+	MaxArticlesPerFeed  int           // oldest evicted beyond this; 0 means unlimited
+	MaxFeeds            int           // feeds that can be added; 0 means unlimited
+	AuditLog            string        // "stdout", "stderr", or a file path
+	MaintenanceToken    string        // bearer token for /api/maintenance; "" disables it
+	FetchProxy          *url.URL      // overrides HTTP_PROXY/HTTPS_PROXY when set
+	TitleDedupWindow    time.Duration // 0 disables fuzzy title dedup
+	SlowFetchThreshold  time.Duration // slower fetches are logged; 0 disables
+	AllowFileFeeds      bool          // permit file:// feed URLs
+	FollowFeedMoves     bool          // rewrite feed URLs on permanent redirects
+	UpdateArticles      bool          // refresh the content of re-fetched articles
+	BatchSaves          bool          // save each cycle's articles in one store call
+
+	PruneBatchSize int // articles a prune deletes per lock acquisition; 0 keeps the store's default
+
+	TrustedProxies []netip.Prefix // peers whose X-Forwarded-For is believed
+
+	// Longest item title and description kept, in runes; 0 means unlimited.
+	MaxTitleLength       int
+	MaxDescriptionLength int
+
+	LogLevel slog.Level // debug also logs every new article
+
+	FetchConcurrency int           // feeds fetched at once; 0 means unlimited
+	TrendingWindow   time.Duration // default look-back of /api/articles/trending
+	IdempotencyTTL   time.Duration // how long Idempotency-Key responses are replayed
+	CompactInterval  time.Duration // period of store index compaction
+
+	ArticleCacheTTL time.Duration // how long /api/articles responses are cached; 0 disables
+
+	DiscoveryPaths []string // probed on sites that declare no feed; nil keeps the fetcher's defaults
+
+	// Feeds with no new article for StaleFeedAfter are polled once per
+	// StaleFeedInterval; 0 disables the backoff.
+	StaleFeedAfter    time.Duration
+	StaleFeedInterval time.Duration
+
+	// StartupCheck fetches every feed once before serving and aborts
+	// startup when more than StartupCheckMaxFailed of them (0 to 1) fail.
+	StartupCheck          bool
+	StartupCheckMaxFailed float64
+
+	// Fetcher connection pool; zero values keep the fetcher's defaults.
+	FetchMaxIdleConnsPerHost int
+	FetchMaxConnsPerHost     int
+	FetchIdleConnTimeout     time.Duration
+}
+
+// Load reads configuration from environment variables, falling back to
+// defaults for anything unset.
+func Load() (Config, error) {
+	cfg := Config{
+		Port:                envOrDefault("PORT", "8080"),
+		FetchInterval:       5 * time.Minute,
+		DefaultArticleLimit: 50,
+		MaxArticleLimit:     500,
+		FetchHistorySize:    50,
+		ShutdownTimeout:     10 * time.Second,
+		StartupSpread:       10 * time.Second,
+		AuditLog:            envOrDefault("AUDIT_LOG", "stderr"),
+		MaintenanceToken:    os.Getenv("MAINTENANCE_TOKEN"),
+		TrendingWindow:      6 * time.Hour,
+		MaxBodyBytes:        1 << 20,
+		MaxFeedBytes:        10 << 20,
+		StaleFeedInterval:   6 * time.Hour,
+		IdempotencyTTL:      24 * time.Hour,
+		CompactInterval:     time.Hour,
+		ArticleCacheTTL:     5 * time.Second,
+	}
+
+	var err error
+	if cfg.DefaultArticleLimit, err = envInt("DEFAULT_ARTICLE_LIMIT", cfg.DefaultArticleLimit); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxArticleLimit, err = envInt("MAX_ARTICLE_LIMIT", cfg.MaxArticleLimit); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.MaxBodyBytes, err = envInt("MAX_BODY_BYTES", cfg.MaxBodyBytes); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxFeedBytes, err = envInt("MAX_FEED_BYTES", cfg.MaxFeedBytes); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.FetchHistorySize, err = envInt("FETCH_HISTORY_SIZE", cfg.FetchHistorySize); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.ShutdownTimeout, err = envDuration("SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("STARTUP_SPREAD"); v != "" {
+		// Unlike other durations, zero is allowed and disables the spread.
+		if cfg.StartupSpread, err = time.ParseDuration(v); err != nil || cfg.StartupSpread < 0 {
+			return Config{}, fmt.Errorf("STARTUP_SPREAD must be a non-negative duration, got %q", v)
+		}
+	}
+	if cfg.MaxArticlesPerFetch, err = envNonNegInt("MAX_ARTICLES_PER_FETCH", cfg.MaxArticlesPerFetch); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxTitleLength, err = envNonNegInt("MAX_TITLE_LENGTH", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxDescriptionLength, err = envNonNegInt("MAX_DESCRIPTION_LENGTH", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxArticlesPerFeed, err = envNonNegInt("MAX_ARTICLES_PER_FEED", cfg.MaxArticlesPerFeed); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxFeeds, err = envNonNegInt("MAX_FEEDS", 0); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.TrendingWindow, err = envDuration("TRENDING_WINDOW", cfg.TrendingWindow); err != nil {
+		return Config{}, err
+	}
+	if cfg.IdempotencyTTL, err = envDuration("IDEMPOTENCY_TTL", cfg.IdempotencyTTL); err != nil {
+		return Config{}, err
+	}
+	if cfg.CompactInterval, err = envDuration("INDEX_COMPACT_INTERVAL", cfg.CompactInterval); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("ARTICLE_CACHE_TTL"); v != "" {
+		// Zero is allowed and disables the cache.
+		if cfg.ArticleCacheTTL, err = time.ParseDuration(v); err != nil || cfg.ArticleCacheTTL < 0 {
+			return Config{}, fmt.Errorf("ARTICLE_CACHE_TTL must be a non-negative duration, got %q", v)
+		}
+	}
+
+	if cfg.StaleFeedAfter, err = envDuration("STALE_FEED_AFTER", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.StaleFeedInterval, err = envDuration("STALE_FEED_INTERVAL", cfg.StaleFeedInterval); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.TitleDedupWindow, err = envDuration("TITLE_DEDUP_WINDOW", cfg.TitleDedupWindow); err != nil {
+		return Config{}, err
+	}
+	if cfg.SlowFetchThreshold, err = envDuration("SLOW_FETCH_THRESHOLD", 0); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.AllowFileFeeds, err = envBool("ALLOW_FILE_FEEDS", false); err != nil {
+		return Config{}, err
+	}
+	if cfg.FollowFeedMoves, err = envBool("FOLLOW_FEED_MOVES", false); err != nil {
+		return Config{}, err
+	}
+	if cfg.UpdateArticles, err = envBool("UPDATE_ARTICLES", false); err != nil {
+		return Config{}, err
+	}
+	if cfg.BatchSaves, err = envBool("BATCH_SAVES", false); err != nil {
+		return Config{}, err
+	}
+	if cfg.PruneBatchSize, err = envNonNegInt("PRUNE_BATCH_SIZE", 0); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.StartupCheck, err = envBool("STARTUP_CHECK", false); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("STARTUP_CHECK_MAX_FAILED"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			return Config{}, fmt.Errorf("STARTUP_CHECK_MAX_FAILED must be a fraction between 0 and 1, got %q", v)
+		}
+		cfg.StartupCheckMaxFailed = f
+	}
+
+	if cfg.FetchConcurrency, err = envNonNegInt("FETCH_CONCURRENCY", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.FetchMaxIdleConnsPerHost, err = envNonNegInt("FETCH_MAX_IDLE_CONNS_PER_HOST", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.FetchMaxConnsPerHost, err = envNonNegInt("FETCH_MAX_CONNS_PER_HOST", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.FetchIdleConnTimeout, err = envDuration("FETCH_IDLE_CONN_TIMEOUT", 0); err != nil {
+		return Config{}, err
+	}
+
+	if v := os.Getenv("FETCH_PROXY"); v != "" {
+		u, err := url.Parse(v)
+		if err != nil || u.Host == "" {
+			return Config{}, fmt.Errorf("FETCH_PROXY must be an absolute URL, got %q", v)
+		}
+		cfg.FetchProxy = u
+	}
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := cfg.LogLevel.UnmarshalText([]byte(v)); err != nil {
+			return Config{}, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", v)
+		}
+	}
+
+	if v := os.Getenv("DISCOVERY_PATHS"); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			p = strings.TrimSpace(p)
+			if !strings.HasPrefix(p, "/") {
+				return Config{}, fmt.Errorf("DISCOVERY_PATHS must be a comma-separated list of paths starting with /, got %q", p)
+			}
+			cfg.DiscoveryPaths = append(cfg.DiscoveryPaths, p)
+		}
+	}
+
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			entry = strings.TrimSpace(entry)
+			p, err := netip.ParsePrefix(entry)
+			if err != nil {
+				// A bare address trusts just that host.
+				ip, ipErr := netip.ParseAddr(entry)
+				if ipErr != nil {
+					return Config{}, fmt.Errorf("TRUSTED_PROXIES must be a comma-separated list of CIDRs or IPs, got %q", entry)
+				}
+				p = netip.PrefixFrom(ip, ip.BitLen())
+			}
+			cfg.TrustedProxies = append(cfg.TrustedProxies, p.Masked())
+		}
+	}
+
+	if cfg.DefaultArticleLimit > cfg.MaxArticleLimit {
+		return Config{}, fmt.Errorf("DEFAULT_ARTICLE_LIMIT (%d) exceeds MAX_ARTICLE_LIMIT (%d)",
+			cfg.DefaultArticleLimit, cfg.MaxArticleLimit)
+	}
+	return cfg, nil
+}
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// envInt parses a positive integer variable, returning fallback when unset.
+func envInt(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", key, v)
+	}
+	return n, nil
+}
+
+// envNonNegInt parses a variable where 0 is meaningful (usually "unlimited"),
+// returning fallback when unset.
+func envNonNegInt(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", key, v)
+	}
+	return n, nil
+}
+
+// envBool parses a boolean variable such as "true" or "0", returning
+// fallback when unset.
+func envBool(key string, fallback bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean, got %q", key, v)
+	}
+	return b, nil
+}
+
+// envDuration parses a positive Go duration string such as "30s",
+// returning fallback when unset.
+func envDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration, got %q", key, v)
+	}
+	return d, nil
+}
@@ -0,0 +1,230 @@
+package config_test
+
+import (
+	"log/slog"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/config"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Port != "8080" || cfg.DefaultArticleLimit != 50 || cfg.MaxArticleLimit != 500 || cfg.AuditLog != "stderr" {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoadArticleLimits(t *testing.T) {
+	t.Setenv("DEFAULT_ARTICLE_LIMIT", "20")
+	t.Setenv("MAX_ARTICLE_LIMIT", "100")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.DefaultArticleLimit != 20 || cfg.MaxArticleLimit != 100 {
+		t.Fatalf("limits not read from env: %+v", cfg)
+	}
+}
+
+func TestLoadRejectsInvalidLimits(t *testing.T) {
+	t.Setenv("DEFAULT_ARTICLE_LIMIT", "abc")
+	if _, err := config.Load(); err == nil {
+		t.Fatal("expected error for non-numeric limit")
+	}
+
+	t.Setenv("DEFAULT_ARTICLE_LIMIT", "200")
+	t.Setenv("MAX_ARTICLE_LIMIT", "100")
+	if _, err := config.Load(); err == nil {
+		t.Fatal("expected error when default exceeds max")
+	}
+}
+
+func TestLoadFetchProxy(t *testing.T) {
+	t.Setenv("FETCH_PROXY", "http://proxy.internal:3128")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.FetchProxy == nil || cfg.FetchProxy.Host != "proxy.internal:3128" {
+		t.Fatalf("unexpected proxy: %v", cfg.FetchProxy)
+	}
+
+	t.Setenv("FETCH_PROXY", "not a url")
+	if _, err := config.Load(); err == nil {
+		t.Fatal("expected error for relative proxy URL")
+	}
+}
+
+func TestLoadShutdownTimeout(t *testing.T) {
+	cfg, _ := config.Load()
+	if cfg.ShutdownTimeout != 10*time.Second {
+		t.Fatalf("expected 10s default, got %v", cfg.ShutdownTimeout)
+	}
+
+	t.Setenv("SHUTDOWN_TIMEOUT", "250ms")
+	cfg, err := config.Load()
+	if err != nil || cfg.ShutdownTimeout != 250*time.Millisecond {
+		t.Fatalf("expected 250ms, got %v (err %v)", cfg.ShutdownTimeout, err)
+	}
+
+	t.Setenv("SHUTDOWN_TIMEOUT", "soon")
+	if _, err := config.Load(); err == nil {
+		t.Fatal("expected error for invalid duration")
+	}
+}
+
+func TestLoadTitleDedupWindow(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil || cfg.TitleDedupWindow != 0 {
+		t.Fatalf("expected title dedup off by default, got %v (err %v)", cfg.TitleDedupWindow, err)
+	}
+
+	t.Setenv("TITLE_DEDUP_WINDOW", "24h")
+	if cfg, err = config.Load(); err != nil || cfg.TitleDedupWindow != 24*time.Hour {
+		t.Fatalf("expected 24h window, got %v (err %v)", cfg.TitleDedupWindow, err)
+	}
+
+	t.Setenv("TITLE_DEDUP_WINDOW", "0s")
+	if _, err := config.Load(); err == nil {
+		t.Fatal("expected error for zero window")
+	}
+}
+
+func TestLoadAllowFileFeeds(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil || cfg.AllowFileFeeds {
+		t.Fatalf("expected file feeds disabled by default, got %v (err %v)", cfg.AllowFileFeeds, err)
+	}
+
+	t.Setenv("ALLOW_FILE_FEEDS", "true")
+	if cfg, err = config.Load(); err != nil || !cfg.AllowFileFeeds {
+		t.Fatalf("expected file feeds enabled, got %v (err %v)", cfg.AllowFileFeeds, err)
+	}
+
+	t.Setenv("ALLOW_FILE_FEEDS", "maybe")
+	if _, err := config.Load(); err == nil {
+		t.Fatal("expected error for non-boolean value")
+	}
+}
+
+func TestLoadFetchTransportSettings(t *testing.T) {
+	t.Setenv("FETCH_MAX_IDLE_CONNS_PER_HOST", "32")
+	t.Setenv("FETCH_MAX_CONNS_PER_HOST", "64")
+	t.Setenv("FETCH_IDLE_CONN_TIMEOUT", "15m")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.FetchMaxIdleConnsPerHost != 32 || cfg.FetchMaxConnsPerHost != 64 || cfg.FetchIdleConnTimeout != 15*time.Minute {
+		t.Fatalf("transport settings not read from env: %+v", cfg)
+	}
+
+	t.Setenv("FETCH_MAX_CONNS_PER_HOST", "-1")
+	if _, err := config.Load(); err == nil {
+		t.Fatal("expected error for a negative connection limit")
+	}
+}
+
+func TestLoadStartupCheck(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil || cfg.StartupCheck || cfg.StartupCheckMaxFailed != 0 {
+		t.Fatalf("expected startup check off by default, got %+v (err %v)", cfg, err)
+	}
+
+	t.Setenv("STARTUP_CHECK", "1")
+	t.Setenv("STARTUP_CHECK_MAX_FAILED", "0.25")
+	if cfg, err = config.Load(); err != nil || !cfg.StartupCheck || cfg.StartupCheckMaxFailed != 0.25 {
+		t.Fatalf("startup check not read from env: %+v (err %v)", cfg, err)
+	}
+
+	for _, v := range []string{"-0.1", "1.5", "half"} {
+		t.Setenv("STARTUP_CHECK_MAX_FAILED", v)
+		if _, err := config.Load(); err == nil {
+			t.Fatalf("expected error for STARTUP_CHECK_MAX_FAILED=%q", v)
+		}
+	}
+}
+
+func TestLoadLogLevel(t *testing.T) {
+	if cfg, err := config.Load(); err != nil || cfg.LogLevel != slog.LevelInfo {
+		t.Fatalf("expected info by default, got %v (err %v)", cfg.LogLevel, err)
+	}
+
+	t.Setenv("LOG_LEVEL", "DEBUG")
+	if cfg, err := config.Load(); err != nil || cfg.LogLevel != slog.LevelDebug {
+		t.Fatalf("expected debug, got %v (err %v)", cfg.LogLevel, err)
+	}
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	if _, err := config.Load(); err == nil {
+		t.Fatal("expected error for LOG_LEVEL=verbose")
+	}
+}
+
+func TestLoadArticleCacheTTL(t *testing.T) {
+	if cfg, err := config.Load(); err != nil || cfg.ArticleCacheTTL != 5*time.Second {
+		t.Fatalf("expected 5s by default, got %v (err %v)", cfg.ArticleCacheTTL, err)
+	}
+
+	t.Setenv("ARTICLE_CACHE_TTL", "0")
+	if cfg, err := config.Load(); err != nil || cfg.ArticleCacheTTL != 0 {
+		t.Fatalf("expected 0 to disable the cache, got %v (err %v)", cfg.ArticleCacheTTL, err)
+	}
+
+	t.Setenv("ARTICLE_CACHE_TTL", "-1s")
+	if _, err := config.Load(); err == nil {
+		t.Fatal("expected error for a negative ARTICLE_CACHE_TTL")
+	}
+}
+
+func TestLoadDiscoveryPaths(t *testing.T) {
+	if cfg, err := config.Load(); err != nil || cfg.DiscoveryPaths != nil {
+		t.Fatalf("expected no paths by default, got %v (err %v)", cfg.DiscoveryPaths, err)
+	}
+
+	t.Setenv("DISCOVERY_PATHS", "/feed, /blog/rss.xml")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"/feed", "/blog/rss.xml"}; !slices.Equal(cfg.DiscoveryPaths, want) {
+		t.Fatalf("expected %v, got %v", want, cfg.DiscoveryPaths)
+	}
+
+	t.Setenv("DISCOVERY_PATHS", "/feed,rss")
+	if _, err := config.Load(); err == nil {
+		t.Fatal("expected error for a path without a leading /")
+	}
+}
+
+func TestLoadTrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.5 ,fd00::/8")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.5/32"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+	if !slices.Equal(cfg.TrustedProxies, want) {
+		t.Fatalf("expected %v, got %v", want, cfg.TrustedProxies)
+	}
+
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8,lb.internal")
+	if _, err := config.Load(); err == nil {
+		t.Fatal("expected error for a hostname")
+	}
+}
@@ -0,0 +1,22 @@
+package fetcher
+
+import (
+	"regexp"
+	"time"
+)
+
+// zonedDate matches a date string whose time of day is followed by a zone:
+// "Z", a numeric offset or an abbreviation such as GMT.
+var zonedDate = regexp.MustCompile(`(?i)\d{2}:\d{2}(:\d{2}(\.\d+)?)?\s*(z|[+-]\d{2}:?\d{2}|[a-z]{1,5})\s*$`)
+
+// inZone reinterprets t, parsed from raw, in loc when raw carried no zone.
+// gofeed reads such dates as UTC, so their wall clock is kept and only the
+// zone changes. The result is in UTC.
+func inZone(t time.Time, raw string, loc *time.Location) time.Time {
+	if loc == nil || zonedDate.MatchString(raw) {
+		return t
+	}
+	y, mo, d := t.Date()
+	h, mi, s := t.Clock()
+	return time.Date(y, mo, d, h, mi, s, t.Nanosecond(), loc).UTC()
+}
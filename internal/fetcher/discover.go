@@ -0,0 +1,129 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+)
+
+// MaxDiscoveryPaths bounds how many common paths, and how many declared
+// feed links, Discover tries for one site.
+const MaxDiscoveryPaths = 10
+
+// defaultDiscoveryPaths are probed, in order, when a site declares no feed.
+var defaultDiscoveryPaths = []string{"/feed", "/rss", "/atom.xml", "/index.xml", "/feed.xml"}
+
+// feedTypes are the link types that declare a feed.
+var feedTypes = []string{"application/rss+xml", "application/atom+xml", "application/feed+json", "application/json"}
+
+// WithDiscoveryPaths replaces the paths Discover probes when a site declares
+// no feed. Paths are resolved against the site's root; only the first
+// MaxDiscoveryPaths are kept, and an empty list keeps the defaults.
+func WithDiscoveryPaths(paths []string) Option {
+	return func(f *Fetcher) {
+		if len(paths) > 0 {
+			f.discoveryPaths = slices.Clone(paths[:min(len(paths), MaxDiscoveryPaths)])
+		}
+	}
+}
+
+// Discover finds the feed of a site. siteURL is returned as is if it is a
+// feed itself; otherwise the feeds its page declares with
+// <link rel="alternate"> are tried, then the common feed paths, and the
+// first that parses wins. It returns ErrNoFeedFound if none does, or the
+// error fetching siteURL if the site could not be reached at all.
+func (f *Fetcher) Discover(ctx context.Context, siteURL string) (models.FeedDiscovery, error) {
+	base, err := url.Parse(siteURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return models.FeedDiscovery{}, fmt.Errorf("discover %s: not an http or https URL", siteURL)
+	}
+
+	var links []string
+	body, err := f.download(ctx, models.Feed{URL: siteURL})
+	var httpErr gofeed.HTTPError
+	switch {
+	case err == nil:
+		if parsed, err := f.parseBody(body, false); err == nil {
+			return discovered(siteURL, parsed, models.DiscoveredDirect), nil
+		}
+		links = alternateLinks(body, base)
+	case !errors.As(err, &httpErr):
+		// The page itself may be missing while the feed is not, but an
+		// unreachable host will not answer the probes either.
+		return models.FeedDiscovery{}, err
+	}
+
+	for _, link := range links {
+		if parsed, err := f.parse(ctx, models.Feed{URL: link}); err == nil {
+			return discovered(link, parsed, models.DiscoveredLink), nil
+		}
+	}
+	for _, p := range f.discoveryPaths {
+		candidate := base.ResolveReference(&url.URL{Path: "/" + strings.TrimPrefix(p, "/")}).String()
+		if parsed, err := f.parse(ctx, models.Feed{URL: candidate}); err == nil {
+			return discovered(candidate, parsed, models.DiscoveredProbe), nil
+		}
+		if ctx.Err() != nil {
+			return models.FeedDiscovery{}, ctx.Err()
+		}
+	}
+	return models.FeedDiscovery{}, fmt.Errorf("discover %s: %w", siteURL, ErrNoFeedFound)
+}
+
+func discovered(feedURL string, parsed *gofeed.Feed, via string) models.FeedDiscovery {
+	return models.FeedDiscovery{
+		FeedURL:   feedURL,
+		Title:     parsed.Title,
+		FeedType:  parsed.FeedType,
+		ItemCount: len(parsed.Items),
+		Via:       via,
+	}
+}
+
+// alternateLinks returns the feed URLs an HTML page declares with
+// <link rel="alternate">, resolved against base, in document order and at
+// most MaxDiscoveryPaths of them.
+func alternateLinks(page []byte, base *url.URL) []string {
+	z := html.NewTokenizer(bytes.NewReader(page))
+
+	var links []string
+	for len(links) < MaxDiscoveryPaths {
+		switch z.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if name, hasAttr := z.TagName(); string(name) != "link" || !hasAttr {
+				continue
+			}
+			var rel, typ, href string
+			for more := true; more; {
+				var key, val []byte
+				key, val, more = z.TagAttr()
+				switch string(key) {
+				case "rel":
+					rel = strings.ToLower(string(val))
+				case "type":
+					typ = strings.ToLower(strings.TrimSpace(string(val)))
+				case "href":
+					href = strings.TrimSpace(string(val))
+				}
+			}
+			if href == "" || !slices.Contains(strings.Fields(rel), "alternate") || !slices.Contains(feedTypes, typ) {
+				continue
+			}
+			if u, err := base.Parse(href); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+				links = append(links, u.String())
+			}
+		}
+	}
+	return links
+}
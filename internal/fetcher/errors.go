@@ -0,0 +1,144 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Reasons reported by Classify.
+const (
+	ReasonTimeout     = "timeout"
+	ReasonUnreachable = "unreachable"
+	ReasonHTTPStatus  = "http_status"
+	ReasonRateLimited = "rate_limited"
+	ReasonParse       = "parse_error"
+	ReasonChallenge   = "challenge"
+	ReasonTooLarge    = "too_large"
+)
+
+// ErrEmptyBody reports a feed response with nothing but whitespace in it.
+// A document that parses but has no items is reported with ErrNoItems
+// instead.
+var ErrEmptyBody = errors.New("empty response body")
+
+// ErrNoItems is returned by fetchFeed, along with the feed's details, for
+// a document that parses but has no items. It is not a failure: fetchAll
+// records the fetch as a success and only logs it differently, and
+// Classify reports no reason for it.
+var ErrNoItems = errors.New("feed has no items")
+
+// ErrNoFeedFound reports that Discover found no feed for a site.
+var ErrNoFeedFound = errors.New("no feed found")
+
+// ErrChallenge reports a feed host answering with an anti-bot challenge
+// page, such as Cloudflare's, instead of the feed. Retrying will not help
+// until the host lets the fetcher through.
+var ErrChallenge = errors.New("feed blocked by challenge")
+
+// challengeMarkers are found, lowercased, in the challenge pages of common
+// CDNs and bot filters.
+var challengeMarkers = []string{
+	"cf-browser-verification",
+	"/cdn-cgi/challenge-platform/",
+	"cf_chl_",
+	"<title>just a moment...</title>",
+	"attention required! | cloudflare",
+	"ddos-guard",
+	"_incapsula_resource",
+	"captcha",
+}
+
+// maxChallengeBytes bounds how much of an error response is read to look
+// for challenge markers.
+const maxChallengeBytes = 64 << 10
+
+// isChallenge reports whether a 403 or 503 response with the given
+// Content-Type and (start of its) body is a challenge page.
+func isChallenge(status int, contentType string, body []byte) bool {
+	if status != http.StatusForbidden && status != http.StatusServiceUnavailable {
+		return false
+	}
+	page := strings.ToLower(string(body))
+	if !strings.Contains(strings.ToLower(contentType), "html") && !strings.Contains(page, "<html") {
+		return false
+	}
+	for _, marker := range challengeMarkers {
+		if strings.Contains(page, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrFeedTooLarge reports a feed body that, once decompressed, is larger
+// than the WithMaxFeedBytes limit. A small gzipped body can expand to many
+// times its size, so the limit applies to what comes out of the
+// decompressor as well as to what is read off the wire.
+var ErrFeedTooLarge = errors.New("feed too large")
+
+// RetryAfterError reports that a feed host answered 429 or 503 and asked
+// us not to come back before RetryAt.
+type RetryAfterError struct {
+	StatusCode int
+	RetryAt    time.Time
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("http error: %d %s, retry after %s",
+		e.StatusCode, http.StatusText(e.StatusCode), e.RetryAt.Format(time.RFC3339))
+}
+
+// parseRetryAfter interprets a Retry-After header, which is either a number
+// of seconds or an HTTP-date, relative to now.
+func parseRetryAfter(v string, now time.Time) (time.Time, bool) {
+	if v == "" {
+		return time.Time{}, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return now.Add(time.Duration(secs) * time.Second), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// Classify maps a fetch error onto a short, stable reason that clients can
+// switch on without parsing error strings. It returns "" for a nil error.
+func Classify(err error) string {
+	if err == nil || errors.Is(err, ErrNoItems) {
+		return ""
+	}
+
+	var httpErr gofeed.HTTPError
+	var retryErr *RetryAfterError
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ReasonTimeout
+	case errors.Is(err, ErrChallenge):
+		return ReasonChallenge
+	case errors.Is(err, ErrFeedTooLarge):
+		return ReasonTooLarge
+	case errors.As(err, &retryErr):
+		return ReasonRateLimited
+	case errors.As(err, &httpErr):
+		return ReasonHTTPStatus
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return ReasonTimeout
+		}
+		return ReasonUnreachable
+	default:
+		return ReasonParse
+	}
+}
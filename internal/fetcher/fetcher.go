@@ -1,12 +1,24 @@
 package fetcher
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/mmcdole/gofeed"
 
@@ -17,67 +29,455 @@ import (
 // Fetcher periodically pulls every registered feed using concurrent workers
 // and pushes parsed articles into the store.
 type Fetcher struct {
-	store    *store.Store
-	parser   *gofeed.Parser
-	interval time.Duration
-	logger   *slog.Logger
+	store     *store.Store
+	parser    *gofeed.Parser
+	client    *http.Client
+	transport *http.Transport
+	logger    *slog.Logger
+
+	mu         sync.Mutex // guards interval
+	interval   time.Duration
+	intervalCh chan time.Duration // wakes Start when the interval changes
+	cycles     atomic.Int64       // fetch cycles completed
+
+	statusMu sync.Mutex
+	status   models.FetchStatus // progress of the cycle in flight
+
+	lastNewMu sync.Mutex
+	lastNew   []models.Article // stored by the last completed cycle
+
+	idLength      int           // hash bytes kept in article IDs
+	maxPerFetch   int           // 0 means unlimited
+	startupSpread time.Duration // window the first cycle is spread across
+	fileFeeds     bool          // whether file:// URLs may be read
+	followMoves   bool          // rewrite feed URLs on permanent redirects
+	concurrency   int           // feeds fetched at once; 0 means unlimited
+	staleAfter    time.Duration // quiet period before a feed counts as stale; 0 disables
+	staleInterval time.Duration // polling period for stale feeds
+	slowFetch     time.Duration // fetches slower than this are logged; 0 disables
+	batchSaves    bool          // save a cycle's articles in one store call
+	maxFeedBytes  int64         // largest feed body accepted, after decompression
+
+	// Longest title and description kept, in runes; 0 means unlimited.
+	maxTitleLen       int
+	maxDescriptionLen int
+
+	discoveryPaths []string // probed by Discover when a site declares no feed
+
+	compactInterval time.Duration // how often Start compacts the store's indexes
+
+	slowFetches atomic.Int64 // fetches that took longer than slowFetch
+}
+
+// DefaultMaxFeedBytes is the largest feed body accepted when no
+// WithMaxFeedBytes option is given.
+const DefaultMaxFeedBytes = 10 << 20
+
+// DefaultCompactInterval is how often the store's indexes are compacted
+// when no WithCompactInterval option is given.
+const DefaultCompactInterval = time.Hour
+
+// DefaultStartupSpread is the window the first fetch cycle is spread across
+// when no WithStartupSpread option is given.
+const DefaultStartupSpread = 10 * time.Second
+
+// Connection pool defaults, tuned so every poll of a host can reuse the
+// connections of the previous cycle instead of paying for a new TCP and
+// TLS handshake: more idle connections per host than net/http's 2, kept
+// open longer than the default 5 minute poll interval.
+const (
+	DefaultMaxIdleConnsPerHost = 16
+	DefaultIdleConnTimeout     = 10 * time.Minute
+)
+
+// TransportSettings tunes the connection pool shared by all feed requests.
+// Zero fields keep the defaults.
+type TransportSettings struct {
+	MaxIdleConnsPerHost int           // idle connections kept per host
+	MaxConnsPerHost     int           // total connections per host; 0 is unlimited
+	IdleConnTimeout     time.Duration // how long an idle connection is kept
+}
+
+// Option configures optional Fetcher behaviour.
+type Option func(*Fetcher)
+
+// WithTransportSettings overrides the connection pool defaults. Keep
+// IdleConnTimeout above the poll interval, or connections are closed
+// between cycles and nothing is reused.
+func WithTransportSettings(ts TransportSettings) Option {
+	return func(f *Fetcher) {
+		if ts.MaxIdleConnsPerHost > 0 {
+			f.transport.MaxIdleConnsPerHost = ts.MaxIdleConnsPerHost
+		}
+		if ts.MaxConnsPerHost > 0 {
+			f.transport.MaxConnsPerHost = ts.MaxConnsPerHost
+		}
+		if ts.IdleConnTimeout > 0 {
+			f.transport.IdleConnTimeout = ts.IdleConnTimeout
+		}
+	}
+}
+
+// WithProxy routes every feed request through proxyURL, overriding the
+// HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables.
+func WithProxy(proxyURL *url.URL) Option {
+	return func(f *Fetcher) {
+		f.transport.Proxy = http.ProxyURL(proxyURL)
+	}
+}
+
+// WithMaxArticlesPerFetch keeps only the n newest items of each feed per
+// cycle, so a feed with a huge backlog doesn't flood the store. n <= 0
+// means unlimited.
+func WithMaxArticlesPerFetch(n int) Option {
+	return func(f *Fetcher) {
+		f.maxPerFetch = n
+	}
+}
+
+// WithMaxTextLengths bounds, in runes, the titles and descriptions kept
+// from feed items. Longer titles are cut at a word boundary with an
+// ellipsis; longer descriptions are reduced to plain text first, since
+// cutting HTML could leave it unbalanced. 0 leaves either unlimited.
+func WithMaxTextLengths(title, description int) Option {
+	return func(f *Fetcher) {
+		f.maxTitleLen = max(title, 0)
+		f.maxDescriptionLen = max(description, 0)
+	}
+}
+
+// WithIDLength sets how many bytes of the SHA-256 hash make up an article
+// ID. Shorter IDs are more compact but, at scale, risk birthday collisions
+// that silently merge distinct articles. Values outside 1..32 are ignored.
+func WithIDLength(n int) Option {
+	return func(f *Fetcher) {
+		if n >= 1 && n <= sha256.Size {
+			f.idLength = n
+		}
+	}
+}
+
+// WithStartupSpread delays each feed's first fetch by a random amount within
+// window so that a restart doesn't hit every feed host at the same instant.
+// Zero disables the spread.
+func WithStartupSpread(window time.Duration) Option {
+	return func(f *Fetcher) {
+		f.startupSpread = window
+	}
+}
+
+// WithConcurrency caps how many feeds are fetched at once. Feeds are then
+// started in Priority order, highest first. n <= 0 means unlimited.
+func WithConcurrency(n int) Option {
+	return func(f *Fetcher) {
+		f.concurrency = n
+	}
+}
+
+// WithFileFeeds allows feeds with file:// URLs, read from the local
+// filesystem. It is off by default because any such feed lets API clients
+// read files the server can access.
+func WithFileFeeds() Option {
+	return func(f *Fetcher) {
+		f.fileFeeds = true
+	}
+}
+
+// WithPermanentRedirectUpdates rewrites a feed's stored URL when every
+// redirect on the way to it was permanent (301 or 308), so later fetches
+// skip the hop. Redirects are followed either way.
+func WithPermanentRedirectUpdates() Option {
+	return func(f *Fetcher) {
+		f.followMoves = true
+	}
+}
+
+// WithStaleFeedBackoff polls feeds that have not produced a new article for
+// after only once per interval, instead of every cycle. A feed that has
+// never produced one is not considered stale.
+func WithStaleFeedBackoff(after, interval time.Duration) Option {
+	return func(f *Fetcher) {
+		f.staleAfter = after
+		f.staleInterval = interval
+	}
+}
+
+// WithSlowFetchWarning logs a warning, and counts it in SlowFetches, for
+// every feed fetch that takes longer than threshold, failed or not.
+// Zero disables the warning.
+func WithSlowFetchWarning(threshold time.Duration) Option {
+	return func(f *Fetcher) {
+		f.slowFetch = threshold
+	}
+}
+
+// WithBatchedSaves holds each cycle's fetched articles until every feed has
+// been fetched and saves them in a single store call, which takes the
+// store's locks once instead of once per feed. Articles then appear only
+// at the end of the cycle rather than as each feed completes.
+func WithBatchedSaves() Option {
+	return func(f *Fetcher) {
+		f.batchSaves = true
+	}
+}
+
+// WithMaxFeedBytes sets the largest feed body a fetch accepts, both as read
+// and once decompressed; larger feeds fail with ErrFeedTooLarge. Values
+// below 1 are ignored.
+func WithMaxFeedBytes(n int64) Option {
+	return func(f *Fetcher) {
+		if n > 0 {
+			f.maxFeedBytes = n
+		}
+	}
+}
+
+// WithCompactInterval sets how often Start drops index entries of deleted
+// articles from the store; see store.CompactIndexes. Values below 1 are
+// ignored.
+func WithCompactInterval(d time.Duration) Option {
+	return func(f *Fetcher) {
+		if d > 0 {
+			f.compactInterval = d
+		}
+	}
 }
 
 // New returns a Fetcher that polls feeds every interval.
-func New(s *store.Store, interval time.Duration, logger *slog.Logger) *Fetcher {
-	return &Fetcher{
-		store:    s,
-		parser:   gofeed.NewParser(),
-		interval: interval,
-		logger:   logger,
+// By default outbound requests honour the standard proxy environment variables.
+func New(s *store.Store, interval time.Duration, logger *slog.Logger, opts ...Option) *Fetcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	transport.IdleConnTimeout = DefaultIdleConnTimeout
+
+	// The parser is shared by concurrent fetches; gofeed creates its
+	// translators lazily and unsynchronized, so set them up front.
+	parser := gofeed.NewParser()
+	parser.RSSTranslator = &gofeed.DefaultRSSTranslator{}
+	parser.AtomTranslator = &gofeed.DefaultAtomTranslator{}
+	parser.JSONTranslator = &gofeed.DefaultJSONTranslator{}
+
+	f := &Fetcher{
+		store:     s,
+		parser:    parser,
+		client:    &http.Client{Transport: transport, CheckRedirect: checkRedirect},
+		transport: transport,
+		logger:    logger,
+
+		interval:   interval,
+		intervalCh: make(chan time.Duration, 1),
+
+		idLength:      sha256.Size,
+		startupSpread: DefaultStartupSpread,
+		maxFeedBytes:  DefaultMaxFeedBytes,
+
+		compactInterval: DefaultCompactInterval,
+
+		discoveryPaths: defaultDiscoveryPaths,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Interval returns the current polling period.
+func (f *Fetcher) Interval() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.interval
+}
+
+// Cycles returns the number of fetch cycles completed. Cycles with no feeds
+// due are not counted.
+func (f *Fetcher) Cycles() int64 {
+	return f.cycles.Load()
+}
+
+// Status reports how far the fetch cycle in flight has got.
+func (f *Fetcher) Status() models.FetchStatus {
+	f.statusMu.Lock()
+	defer f.statusMu.Unlock()
+
+	st := f.status
+	st.Pending = st.Total - st.Done
+	return st
+}
+
+// setStatus updates the published progress of the cycle in flight.
+func (f *Fetcher) setStatus(update func(st *models.FetchStatus)) {
+	f.statusMu.Lock()
+	defer f.statusMu.Unlock()
+
+	update(&f.status)
+}
+
+// maxLastCycleArticles bounds how many of a cycle's new articles are kept
+// for LastCycleArticles.
+const maxLastCycleArticles = 1000
+
+// LastCycleArticles returns the articles stored by the most recently
+// completed fetch cycle, newest first, up to maxLastCycleArticles. It is a
+// snapshot: articles deleted since are still included.
+func (f *Fetcher) LastCycleArticles() []models.Article {
+	f.lastNewMu.Lock()
+	defer f.lastNewMu.Unlock()
+
+	return append(make([]models.Article, 0, len(f.lastNew)), f.lastNew...)
+}
+
+// SlowFetches returns the number of fetches that took longer than the
+// WithSlowFetchWarning threshold.
+func (f *Fetcher) SlowFetches() int64 {
+	return f.slowFetches.Load()
+}
+
+// SetInterval changes the polling period of a running fetcher. A cycle in
+// progress is left to finish; the next one starts d after the change.
+func (f *Fetcher) SetInterval(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", d)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.interval = d
+	// Keep only the latest pending change.
+	select {
+	case <-f.intervalCh:
+	default:
 	}
+	f.intervalCh <- d
+	return nil
 }
 
 // Start begins the background polling loop. It blocks until ctx is cancelled.
 func (f *Fetcher) Start(ctx context.Context) {
-	f.logger.Info("fetcher started", "interval", f.interval)
+	interval := f.Interval()
+	f.logger.Info("fetcher started", "interval", interval)
 
-	// Run immediately on startup, then on every tick.
-	f.fetchAll(ctx)
+	// Run immediately on startup (spread out), then on every tick.
+	f.fetchAll(ctx, f.startupSpread)
 
-	ticker := time.NewTicker(f.interval)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	// Compaction walks every index entry under the store's index locks, so
+	// it runs on its own, slower schedule rather than after every cycle.
+	compactTicker := time.NewTicker(f.compactInterval)
+	defer compactTicker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			f.logger.Info("fetcher stopped")
 			return
+		case d := <-f.intervalCh:
+			ticker.Reset(d)
+			f.logger.Info("fetch interval changed", "interval", d)
 		case <-ticker.C:
-			f.fetchAll(ctx)
+			f.fetchAll(ctx, 0)
+		case <-compactTicker.C:
+			if removed := f.store.CompactIndexes(); removed > 0 {
+				f.logger.Debug("store indexes compacted", "removed", removed)
+			}
 		}
 	}
 }
 
 // fetchAll fans-out one goroutine per feed, collects results through a channel,
 // and persists them. This is the core concurrency pattern.
-func (f *Fetcher) fetchAll(ctx context.Context) {
-	feeds := f.store.ListFeeds()
+// Disabled feeds, and feeds whose host asked us to back off, are skipped.
+// Feeds start highest Priority first, at most WithConcurrency at a time.
+// When spread is positive each feed waits a
+// random delay within it before fetching.
+func (f *Fetcher) fetchAll(ctx context.Context, spread time.Duration) {
+	now := time.Now()
+	var feeds []models.Feed
+	for _, feed := range f.store.ListFeeds() {
+		if feed.Enabled && !now.Before(feed.NextFetchAt) {
+			feeds = append(feeds, feed)
+		}
+	}
 	if len(feeds) == 0 {
 		return
 	}
 
 	f.logger.Info("fetch cycle starting", "feeds", len(feeds))
 
+	sort.SliceStable(feeds, func(i, j int) bool {
+		return feeds[i].Priority > feeds[j].Priority
+	})
+
+	summary := models.CycleSummary{StartedAt: time.Now(), Total: len(feeds)}
+	cycleNew := make([]models.Article, 0)
+	started := summary.StartedAt
+	f.setStatus(func(st *models.FetchStatus) {
+		*st = models.FetchStatus{Running: true, StartedAt: &started, Total: len(feeds)}
+	})
+	defer f.setStatus(func(st *models.FetchStatus) { *st = models.FetchStatus{} })
 	results := make(chan models.FetchResult, len(feeds))
 
+	// slots bounds concurrent fetches when a limit is set.
+	var slots chan struct{}
+	if f.concurrency > 0 {
+		slots = make(chan struct{}, f.concurrency)
+	}
+	acquire := func() error {
+		if slots == nil {
+			return nil
+		}
+		select {
+		case slots <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	release := func() {
+		if slots != nil {
+			<-slots
+		}
+	}
+
 	var wg sync.WaitGroup
 	for _, feed := range feeds {
 		wg.Add(1)
-		go func(feed models.Feed) {
+		// Without a spread, slots are taken here so that feeds start in
+		// priority order. With one, each feed takes a slot after its delay.
+		var slotErr error
+		if spread == 0 {
+			slotErr = acquire()
+		}
+		go func(feed models.Feed, slotErr error) {
 			defer wg.Done()
-			articles, err := f.fetchFeed(ctx, feed)
+			if slotErr == nil && spread > 0 {
+				select {
+				case <-time.After(rand.N(spread)):
+					slotErr = acquire()
+				case <-ctx.Done():
+					slotErr = ctx.Err()
+				}
+			}
+			if slotErr != nil {
+				results <- models.FetchResult{FeedID: feed.ID, Err: slotErr}
+				return
+			}
+			defer release()
+
+			start := time.Now()
+			articles, meta, err := f.fetchFeed(ctx, feed)
 			results <- models.FetchResult{
 				FeedID:   feed.ID,
 				Articles: articles,
+				Meta:     meta,
 				Err:      err,
+				Started:  start,
+				Duration: time.Since(start),
 			}
-		}(feed)
+		}(feed, slotErr)
 	}
 
 	// Close the channel once every goroutine finishes.
@@ -86,59 +486,473 @@ func (f *Fetcher) fetchAll(ctx context.Context) {
 		close(results)
 	}()
 
-	// Collect and persist results as they arrive.
-	var totalSaved int
+	// succeeded records a fetched feed once its articles are stored.
+	succeeded := func(res models.FetchResult, stored []models.Article) {
+		event := models.FetchEvent{
+			Timestamp:  res.Started,
+			DurationMS: res.Duration.Milliseconds(),
+		}
+		saved := len(stored)
+		f.store.UpdateFeedMeta(res.FeedID, res.Meta)
+		if res.Meta.MovedTo != "" {
+			f.moveFeed(res.FeedID, res.Meta.MovedTo)
+		}
+		fetchedAt := time.Now()
+		f.store.UpdateLastFetched(res.FeedID, fetchedAt)
+		if saved > 0 {
+			f.store.UpdateLastNewArticle(res.FeedID, fetchedAt)
+		}
+		f.backOffIfStale(res.FeedID, fetchedAt)
+		event.NewArticles = saved
+		f.store.RecordFetch(res.FeedID, event)
+		summary.Succeeded++
+		summary.NewArticles += saved
+		if room := maxLastCycleArticles - len(cycleNew); room > 0 {
+			cycleNew = append(cycleNew, stored[:min(room, len(stored))]...)
+		}
+		if errors.Is(res.Err, ErrNoItems) {
+			f.logger.Info("feed fetched with no items", "feed_id", res.FeedID)
+		} else {
+			f.logger.Info("feed fetched",
+				"feed_id", res.FeedID,
+				"articles", len(res.Articles),
+				"new", saved,
+			)
+		}
+		if f.logger.Enabled(ctx, slog.LevelDebug) {
+			for _, a := range stored {
+				f.logger.Debug("new article", "feed_id", res.FeedID, "title", a.Title, "link", a.Link)
+			}
+		}
+	}
+
+	// Collect and persist results as they arrive, or with batched saves
+	// once they all have.
+	var pending []models.FetchResult
 	for res := range results {
-		if res.Err != nil {
-			f.logger.Error("feed fetch failed", "feed_id", res.FeedID, "error", res.Err)
+		f.warnIfSlow(res)
+		f.setStatus(func(st *models.FetchStatus) { st.Done++ })
+
+		if res.Err != nil && !errors.Is(res.Err, ErrNoItems) {
+			var retryErr *RetryAfterError
+			if errors.As(res.Err, &retryErr) {
+				f.store.SetNextFetch(res.FeedID, retryErr.RetryAt)
+			}
+			f.logger.Error("feed fetch failed", "feed_id", res.FeedID, "reason", Classify(res.Err), "error", res.Err)
+			f.store.RecordFetch(res.FeedID, models.FetchEvent{
+				Timestamp:  res.Started,
+				DurationMS: res.Duration.Milliseconds(),
+				Error:      res.Err.Error(),
+			})
+			summary.Failed++
+			continue
+		}
+		if f.batchSaves {
+			pending = append(pending, res)
 			continue
 		}
-		saved := f.store.SaveArticles(res.Articles)
-		f.store.UpdateLastFetched(res.FeedID, time.Now())
-		totalSaved += saved
-		f.logger.Info("feed fetched",
-			"feed_id", res.FeedID,
-			"articles", len(res.Articles),
-			"new", saved,
-		)
+		succeeded(res, f.store.SaveNewArticles(res.Articles))
+	}
+	if len(pending) > 0 {
+		batch := make(map[string][]models.Article, len(pending))
+		for _, res := range pending {
+			batch[res.FeedID] = res.Articles
+		}
+		stored := f.store.SaveNewArticlesBatch(batch)
+		for _, res := range pending {
+			succeeded(res, stored[res.FeedID])
+		}
 	}
 
-	f.logger.Info("fetch cycle complete", "new_articles", totalSaved)
+	summary.DurationMS = time.Since(summary.StartedAt).Milliseconds()
+	f.store.SetLastCycle(summary)
+	slices.SortStableFunc(cycleNew, func(a, b models.Article) int {
+		return b.PublishedAt.Compare(a.PublishedAt)
+	})
+	f.lastNewMu.Lock()
+	f.lastNew = cycleNew
+	f.lastNewMu.Unlock()
+	f.cycles.Add(1)
+
+	f.logger.Info("fetch cycle complete",
+		"new_articles", summary.NewArticles,
+		"succeeded", summary.Succeeded,
+		"failed", summary.Failed,
+	)
 }
 
-// fetchFeed downloads and parses a single feed, returning article models.
-func (f *Fetcher) fetchFeed(ctx context.Context, feed models.Feed) ([]models.Article, error) {
-	parsedCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
-	defer cancel()
+// warnIfSlow logs and counts a fetch that took longer than the
+// WithSlowFetchWarning threshold.
+func (f *Fetcher) warnIfSlow(res models.FetchResult) {
+	if f.slowFetch <= 0 || res.Duration <= f.slowFetch {
+		return
+	}
+	f.slowFetches.Add(1)
+	f.logger.Warn("slow feed fetch",
+		"feed_id", res.FeedID,
+		"duration", res.Duration,
+		"threshold", f.slowFetch,
+	)
+}
+
+// backOffIfStale defers a feed's next fetch by staleInterval when it has
+// gone staleAfter without a new article.
+func (f *Fetcher) backOffIfStale(feedID string, now time.Time) {
+	if f.staleAfter <= 0 {
+		return
+	}
+	feed, ok := f.store.GetFeed(feedID)
+	if !ok || feed.LastNewArticleAt.IsZero() || now.Sub(feed.LastNewArticleAt) < f.staleAfter {
+		return
+	}
+	f.store.SetNextFetch(feedID, now.Add(f.staleInterval))
+	f.logger.Info("feed is stale, polling less often",
+		"feed_id", feedID,
+		"last_new_article_at", feed.LastNewArticleAt,
+		"next_fetch_at", now.Add(f.staleInterval),
+	)
+}
+
+// moveFeed points a permanently redirected feed at its new URL. If another
+// feed already uses that URL the feed is left as is.
+func (f *Fetcher) moveFeed(feedID, newURL string) {
+	if _, err := f.store.UpdateFeed(feedID, models.UpdateFeedRequest{URL: &newURL}); err != nil {
+		f.logger.Warn("feed moved permanently, URL not updated", "feed_id", feedID, "url", newURL, "error", err)
+		return
+	}
+	f.logger.Info("feed moved permanently, URL updated", "feed_id", feedID, "url", newURL)
+}
+
+// Validate fetches and parses feedURL without storing anything, reporting
+// what was found or a classified error. It backs the dry-run endpoint.
+func (f *Fetcher) Validate(ctx context.Context, feedURL string) models.FeedValidation {
+	return f.ValidateFeed(ctx, models.Feed{URL: feedURL})
+}
 
-	parsed, err := f.parser.ParseURLWithContext(feed.URL, parsedCtx)
+// ValidateFeed is Validate for a feed that is not stored yet, fetched with
+// its headers and cookie and parsed in its strict mode.
+func (f *Fetcher) ValidateFeed(ctx context.Context, feed models.Feed) models.FeedValidation {
+	parsed, err := f.parse(ctx, feed)
 	if err != nil {
-		return nil, fmt.Errorf("parse %s: %w", feed.URL, err)
+		return models.FeedValidation{
+			Valid:  false,
+			Error:  err.Error(),
+			Reason: Classify(err),
+		}
+	}
+
+	return models.FeedValidation{
+		Valid:     true,
+		Title:     parsed.Title,
+		FeedType:  parsed.FeedType,
+		ItemCount: len(parsed.Items),
+	}
+}
+
+// SelfCheck fetches every enabled feed once, logging whether each could be
+// reached and parsed, and reports how many did. Nothing is stored. err is
+// non-nil only if ctx ends before every feed was checked.
+func (f *Fetcher) SelfCheck(ctx context.Context) (ok, failed int, err error) {
+	var feeds []models.Feed
+	for _, feed := range f.store.ListFeeds() {
+		if feed.Enabled {
+			feeds = append(feeds, feed)
+		}
+	}
+
+	limit := f.concurrency
+	if limit <= 0 {
+		limit = len(feeds)
+	}
+	slots := make(chan struct{}, max(limit, 1))
+	errs := make(chan error, len(feeds))
+
+	for _, feed := range feeds {
+		go func() {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+			defer func() { <-slots }()
+
+			_, _, err := f.fetchFeed(ctx, feed)
+			if errors.Is(err, ErrNoItems) {
+				err = nil
+			}
+			if err != nil {
+				f.logger.Warn("self-check: feed failed", "feed_id", feed.ID, "url", feed.URL, "reason", Classify(err), "error", err)
+			} else {
+				f.logger.Info("self-check: feed ok", "feed_id", feed.ID, "url", feed.URL)
+			}
+			errs <- err
+		}()
+	}
+
+	for range feeds {
+		if <-errs == nil {
+			ok++
+		} else {
+			failed++
+		}
+	}
+	return ok, failed, ctx.Err()
+}
+
+// fetchFeed downloads and parses a single feed, returning article models
+// and the feed-level details found along the way.
+func (f *Fetcher) fetchFeed(ctx context.Context, feed models.Feed) ([]models.Article, models.FeedMeta, error) {
+	var trace *redirectTrace
+	if f.followMoves {
+		ctx, trace = withRedirectTrace(ctx)
+	}
+
+	parsed, err := f.parse(ctx, feed)
+	if err != nil {
+		return nil, models.FeedMeta{}, err
+	}
+	meta := models.FeedMeta{Format: parsed.FeedType, Language: parsed.Language}
+	if parsed.Image != nil {
+		meta.ImageURL = parsed.Image.URL
+	}
+	if trace != nil {
+		meta.MovedTo = trace.movedTo()
+	}
+	if len(parsed.Items) == 0 {
+		return nil, meta, ErrNoItems
+	}
+
+	var loc *time.Location
+	if feed.Timezone != "" {
+		if loc, err = time.LoadLocation(feed.Timezone); err != nil {
+			return nil, models.FeedMeta{}, fmt.Errorf("feed %s: %w", feed.ID, err)
+		}
 	}
 
 	articles := make([]models.Article, 0, len(parsed.Items))
 	for _, item := range parsed.Items {
+		if f.store.IsBlocked(item.Link) {
+			continue
+		}
+
 		pub := time.Now()
 		if item.PublishedParsed != nil {
-			pub = *item.PublishedParsed
+			pub = inZone(*item.PublishedParsed, item.Published, loc)
+		}
+
+		content := item.Content
+		if content == "" {
+			content = item.Description
 		}
+		text := stripHTML(content)
+		words := len(strings.Fields(text))
 
 		articles = append(articles, models.Article{
-			ID:          generateID(feed.ID, item.Link),
+			ID:          generateID(feed.ID, articleKey(item, feed.IDStrategy), f.idLength),
 			FeedID:      feed.ID,
 			FeedName:    feed.Name,
-			Title:       item.Title,
-			Description: item.Description,
+			Title:       truncateWords(item.Title, f.maxTitleLen),
+			Description: f.limitDescription(item.Description),
+			Excerpt:     truncateWords(text, excerptLength),
 			Link:        item.Link,
 			PublishedAt: pub,
+			GUID:        item.GUID,
+			ImageURL:    articleImage(item, content),
+
+			WordCount:          words,
+			ReadingTimeSeconds: readingTime(words),
+
+			Enclosures: enclosures(item),
+			Podcast:    podcast(item),
+		})
+	}
+
+	articles, err = transform(articles, feed)
+	if err != nil {
+		return nil, models.FeedMeta{}, fmt.Errorf("feed %s: %w", feed.ID, err)
+	}
+
+	if f.maxPerFetch > 0 && len(articles) > f.maxPerFetch {
+		sort.Slice(articles, func(i, j int) bool {
+			return articles[i].PublishedAt.After(articles[j].PublishedAt)
+		})
+		articles = articles[:f.maxPerFetch]
+	}
+	return articles, meta, nil
+}
+
+// limitDescription applies the description length limit, if any.
+func (f *Fetcher) limitDescription(desc string) string {
+	if f.maxDescriptionLen == 0 || utf8.RuneCountInString(desc) <= f.maxDescriptionLen {
+		return desc
+	}
+	return truncateWords(stripHTML(desc), f.maxDescriptionLen)
+}
+
+// AllowsScheme reports whether feeds with the given URL scheme can be
+// fetched: http and https always, file only with WithFileFeeds.
+func (f *Fetcher) AllowsScheme(scheme string) bool {
+	switch strings.ToLower(scheme) {
+	case "http", "https":
+		return true
+	case "file":
+		return f.fileFeeds
+	}
+	return false
+}
+
+// parse downloads and parses a feed, bounded by a per-feed timeout.
+func (f *Fetcher) parse(ctx context.Context, feed models.Feed) (*gofeed.Feed, error) {
+	if u, err := url.Parse(feed.URL); err == nil && strings.EqualFold(u.Scheme, "file") {
+		return f.parseFile(u, feed.Strict)
+	}
+
+	body, err := f.download(ctx, feed)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := f.parseBody(body, feed.Strict)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", feed.URL, err)
+	}
+	return parsed, nil
+}
+
+// download fetches a document over HTTP, bounded by a per-feed timeout, and
+// returns its body decompressed and converted to UTF-8. The request is
+// built here rather than by gofeed so per-feed headers apply.
+func (f *Fetcher) download(ctx context.Context, feed models.Feed) ([]byte, error) {
+	parsedCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(parsedCtx, http.MethodGet, feed.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", feed.URL, err)
+	}
+	req.Header.Set("User-Agent", f.parser.UserAgent)
+	// Asking explicitly turns off the transport's transparent gzip, so
+	// decompress handles every coding the same way.
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	for k, v := range feed.Headers {
+		req.Header.Set(k, v)
+	}
+	if feed.Cookie != "" {
+		req.Header.Set("Cookie", feed.Cookie)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", feed.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusServiceUnavailable {
+		page, _ := io.ReadAll(io.LimitReader(resp.Body, maxChallengeBytes))
+		if page, err = decompress(page, resp.Header.Get("Content-Encoding"), f.maxFeedBytes); err == nil &&
+			isChallenge(resp.StatusCode, resp.Header.Get("Content-Type"), page) {
+			return nil, fmt.Errorf("parse %s: %w (%s)", feed.URL, ErrChallenge, resp.Status)
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if retryAt, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return nil, fmt.Errorf("parse %s: %w", feed.URL, &RetryAfterError{
+				StatusCode: resp.StatusCode,
+				RetryAt:    retryAt,
+			})
+		}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("parse %s: %w", feed.URL, gofeed.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
 		})
 	}
-	return articles, nil
+
+	body, err := readLimited(resp.Body, f.maxFeedBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", feed.URL, err)
+	}
+	if body, err = decompress(body, resp.Header.Get("Content-Encoding"), f.maxFeedBytes); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", feed.URL, err)
+	}
+	if feed.KeepRaw && feed.ID != "" {
+		f.keepRaw(feed.ID, body, resp.Header.Get("Content-Type"))
+	}
+	if body, err = toUTF8(body, resp.Header.Get("Content-Type")); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", feed.URL, err)
+	}
+	return body, nil
+}
+
+// maxRawBytes bounds the response body kept for a KeepRaw feed.
+const maxRawBytes = 1 << 20
+
+// keepRaw stores a copy of a feed's response body, cut to maxRawBytes, for
+// GET /api/feeds/{id}/raw.
+func (f *Fetcher) keepRaw(feedID string, body []byte, contentType string) {
+	n := min(len(body), maxRawBytes)
+	f.store.SetRawResponse(feedID, models.RawResponse{
+		Body:        bytes.Clone(body[:n]),
+		ContentType: contentType,
+		FetchedAt:   time.Now(),
+		Truncated:   n < len(body),
+	})
+}
+
+// parseFile reads and parses a feed from the local filesystem. Only
+// regular files are read, and no more of them than WithMaxFeedBytes
+// allows, so that paths such as /dev/zero or a FIFO cannot exhaust memory
+// or block the fetch.
+func (f *Fetcher) parseFile(u *url.URL, strict bool) (*gofeed.Feed, error) {
+	if !f.fileFeeds {
+		return nil, fmt.Errorf("parse %s: file feeds are disabled", u)
+	}
+
+	// Checked before opening, which would block on a FIFO.
+	if info, err := os.Stat(u.Path); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", u, err)
+	} else if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("parse %s: not a regular file", u)
+	}
+	file, err := os.Open(u.Path)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", u, err)
+	}
+	defer file.Close()
+
+	body, err := readLimited(file, f.maxFeedBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", u, err)
+	}
+
+	parsed, err := f.parseBody(body, strict)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", u, err)
+	}
+	return parsed, nil
+}
+
+// articleKey returns what an item's ID is derived from: its GUID or its
+// link, whichever strategy prefers and the item has, and otherwise its
+// title and publish date as written in the feed.
+func articleKey(item *gofeed.Item, strategy string) string {
+	keys := []string{item.GUID, item.Link}
+	if strategy == models.IDStrategyLink {
+		keys[0], keys[1] = keys[1], keys[0]
+	}
+	for _, k := range keys {
+		if k != "" {
+			return k
+		}
+	}
+	return item.Title + "|" + item.Published
 }
 
 // generateID creates a deterministic ID so re-fetching the same article
-// does not create duplicates.
-func generateID(feedID, link string) string {
+// does not create duplicates. n is the number of hash bytes kept.
+func generateID(feedID, link string, n int) string {
 	h := sha256.Sum256([]byte(feedID + "|" + link))
-	return fmt.Sprintf("%x", h[:8])
+	return fmt.Sprintf("%x", h[:n])
 }
@@ -0,0 +1,1772 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/store"
+)
+
+const rssFixture = `<?xml version="1.0"?>
+<rss version="2.0">
+  <channel>
+    <title>Fixture Feed</title>
+    <link>https://example.com</link>
+    <item><title>One</title><link>https://example.com/1</link></item>
+    <item><title>Two</title><link>https://example.com/2</link></item>
+  </channel>
+</rss>`
+
+// largeFeed builds an RSS document with n items, item i published i hours
+// before a fixed base time. Items are listed oldest first.
+func largeFeed(n int) string {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?><rss version="2.0"><channel><title>Large</title>`)
+	for i := n - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, `<item><title>Item %d</title><link>https://example.com/%d</link><pubDate>%s</pubDate></item>`,
+			i, i, base.Add(-time.Duration(i)*time.Hour).Format(time.RFC1123Z))
+	}
+	b.WriteString(`</channel></rss>`)
+	return b.String()
+}
+
+const atomFixture = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom Fixture</title>
+  <id>urn:example:feed</id>
+  <updated>2024-01-01T00:00:00Z</updated>
+  <entry>
+    <title>Entry</title>
+    <id>urn:example:1</id>
+    <link href="https://example.com/entry"/>
+    <updated>2024-01-01T00:00:00Z</updated>
+  </entry>
+</feed>`
+
+func newTestFetcher(s *store.Store, opts ...Option) *Fetcher {
+	return New(s, time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)), opts...)
+}
+
+// countingServer serves body and counts the requests it receives.
+func countingServer(t *testing.T, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/rss+xml")
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &hits
+}
+
+func TestFetchAllSkipsDisabledFeeds(t *testing.T) {
+	s := store.New()
+	f := newTestFetcher(s)
+
+	ts, hits := countingServer(t, rssFixture)
+	feed := s.AddFeed("Paused", ts.URL)
+	s.SetFeedEnabled(feed.ID, false)
+
+	f.fetchAll(context.Background(), 0)
+	if hits.Load() != 0 {
+		t.Fatalf("disabled feed was fetched %d times", hits.Load())
+	}
+
+	s.SetFeedEnabled(feed.ID, true)
+	f.fetchAll(context.Background(), 0)
+	if hits.Load() != 1 {
+		t.Fatalf("expected re-enabled feed to be fetched once, got %d", hits.Load())
+	}
+	if len(s.ListArticles(feed.ID, 0)) != 2 {
+		t.Fatal("expected articles from the re-enabled feed")
+	}
+}
+
+func TestStaleFeedsArePolledLessOften(t *testing.T) {
+	s := store.New()
+	f := newTestFetcher(s, WithStaleFeedBackoff(24*time.Hour, 6*time.Hour))
+
+	ts, hits := countingServer(t, rssFixture)
+	feed := s.AddFeed("Dormant", ts.URL)
+
+	// The first fetch stores articles, so the feed is fresh.
+	f.fetchAll(context.Background(), 0)
+	got, _ := s.GetFeed(feed.ID)
+	if got.LastNewArticleAt.IsZero() || !got.NextFetchAt.IsZero() {
+		t.Fatalf("expected a fresh feed on the normal schedule, got %+v", got)
+	}
+
+	// Nothing new for two days: the next fetch finds it stale.
+	s.UpdateLastNewArticle(feed.ID, time.Now().Add(-48*time.Hour))
+	f.fetchAll(context.Background(), 0)
+	got, _ = s.GetFeed(feed.ID)
+	if wait := time.Until(got.NextFetchAt); wait < 5*time.Hour || wait > 6*time.Hour {
+		t.Fatalf("expected the next fetch about 6h out, got %v", wait)
+	}
+
+	f.fetchAll(context.Background(), 0)
+	if n := hits.Load(); n != 2 {
+		t.Fatalf("expected the stale feed to be skipped, got %d fetches", n)
+	}
+
+	// Re-enabling restarts the clock and lifts the backoff.
+	s.SetFeedEnabled(feed.ID, false)
+	s.SetFeedEnabled(feed.ID, true)
+	f.fetchAll(context.Background(), 0)
+	got, _ = s.GetFeed(feed.ID)
+	if n := hits.Load(); n != 3 || !got.NextFetchAt.IsZero() {
+		t.Fatalf("expected a re-enabled feed to be fetched and stay on schedule, got %d fetches, next %v", n, got.NextFetchAt)
+	}
+}
+
+func TestFetchAllDropsBlockedDomains(t *testing.T) {
+	const feedXML = `<?xml version="1.0"?><rss version="2.0"><channel><title>Mixed</title>
+<item><title>Kept</title><link>https://example.com/1</link></item>
+<item><title>Spam</title><link>https://ads.spam.test/buy</link></item>
+<item><title>More spam</title><link>http://SPAM.test/now</link></item>
+</channel></rss>`
+	s := store.New()
+	f := newTestFetcher(s)
+	ts, _ := countingServer(t, feedXML)
+	feed := s.AddFeed("Mixed", ts.URL)
+
+	s.BlockDomain("spam.test")
+	f.fetchAll(context.Background(), 0)
+	f.fetchAll(context.Background(), 0)
+
+	articles := s.ListArticles(feed.ID, 0)
+	if len(articles) != 1 || articles[0].Title != "Kept" {
+		t.Fatalf("expected only the unblocked item to be saved, got %+v", articles)
+	}
+}
+
+func TestPermanentRedirectUpdatesFeedURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/new", func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, rssFixture)
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/temp", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/old", http.StatusFound)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	s := store.New()
+	moved := s.AddFeed("Moved", ts.URL+"/old")
+	temp := s.AddFeed("Temporary", ts.URL+"/temp")
+
+	newTestFetcher(s).fetchAll(context.Background(), 0)
+	if got, _ := s.GetFeed(moved.ID); got.URL != ts.URL+"/old" {
+		t.Fatalf("expected URL unchanged without the option, got %s", got.URL)
+	}
+
+	newTestFetcher(s, WithPermanentRedirectUpdates()).fetchAll(context.Background(), 0)
+	if got, _ := s.GetFeed(moved.ID); got.URL != ts.URL+"/new" {
+		t.Fatalf("expected URL updated to the 301 target, got %s", got.URL)
+	}
+	if got, _ := s.GetFeed(temp.ID); got.URL != ts.URL+"/temp" {
+		t.Fatalf("expected URL kept when a hop was temporary, got %s", got.URL)
+	}
+	if n := len(s.ListArticles(moved.ID, 0)); n != 2 {
+		t.Fatalf("expected redirected feed's articles to be saved, got %d", n)
+	}
+}
+
+// tlsFeedServer serves rssFixture over TLS and counts the connections
+// opened to it, each of which costs a TLS handshake.
+func tlsFeedServer(tb testing.TB) (*httptest.Server, *atomic.Int32) {
+	tb.Helper()
+	var conns atomic.Int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, rssFixture)
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.StartTLS()
+	tb.Cleanup(ts.Close)
+	return ts, &conns
+}
+
+// trustServer makes f accept the test server's certificate.
+func trustServer(f *Fetcher, ts *httptest.Server) {
+	f.transport.TLSClientConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+}
+
+func TestFetchAllReusesConnectionsAcrossCycles(t *testing.T) {
+	ts, conns := tlsFeedServer(t)
+
+	s := store.New()
+	for i := 0; i < 4; i++ {
+		s.AddFeed(fmt.Sprintf("Feed %d", i), fmt.Sprintf("%s/%d", ts.URL, i))
+	}
+	f := newTestFetcher(s)
+	trustServer(f, ts)
+
+	f.fetchAll(context.Background(), 0)
+	first := conns.Load()
+	if first == 0 {
+		t.Fatal("expected the first cycle to open connections")
+	}
+
+	f.fetchAll(context.Background(), 0)
+	if opened := conns.Load() - first; opened != 0 {
+		t.Fatalf("expected the second cycle to reuse all %d connections, it opened %d more", first, opened)
+	}
+}
+
+func TestWithTransportSettings(t *testing.T) {
+	f := newTestFetcher(store.New(), WithTransportSettings(TransportSettings{
+		MaxIdleConnsPerHost: 4,
+		MaxConnsPerHost:     8,
+	}))
+
+	if f.transport.MaxIdleConnsPerHost != 4 || f.transport.MaxConnsPerHost != 8 {
+		t.Fatalf("settings not applied: idle=%d max=%d", f.transport.MaxIdleConnsPerHost, f.transport.MaxConnsPerHost)
+	}
+	if f.transport.IdleConnTimeout != DefaultIdleConnTimeout {
+		t.Fatalf("expected zero IdleConnTimeout to keep the default, got %v", f.transport.IdleConnTimeout)
+	}
+}
+
+// BenchmarkFetchCycleTLS measures a cycle of 8 feeds on one TLS host with
+// pooled connections against one that handshakes for every request.
+func BenchmarkFetchCycleTLS(b *testing.B) {
+	for _, reuse := range []bool{true, false} {
+		b.Run(fmt.Sprintf("reuse=%v", reuse), func(b *testing.B) {
+			ts, conns := tlsFeedServer(b)
+			s := store.New()
+			for i := 0; i < 8; i++ {
+				s.AddFeed(fmt.Sprintf("Feed %d", i), fmt.Sprintf("%s/%d", ts.URL, i))
+			}
+			f := newTestFetcher(s)
+			trustServer(f, ts)
+			f.transport.DisableKeepAlives = !reuse
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				f.fetchAll(context.Background(), 0)
+			}
+			b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+		})
+	}
+}
+
+func TestFetchAllDispatchesByPriority(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		order = append(order, strings.TrimPrefix(r.URL.Path, "/"))
+		mu.Unlock()
+		io.WriteString(w, rssFixture)
+	}))
+	defer ts.Close()
+
+	s := store.New()
+	for _, p := range []int{1, 5, 0, 3, 10} {
+		s.CreateFeed(models.Feed{Name: "Feed", URL: fmt.Sprintf("%s/p%d", ts.URL, p), Priority: p})
+	}
+
+	newTestFetcher(s, WithConcurrency(1)).fetchAll(context.Background(), 0)
+
+	want := []string{"p10", "p5", "p3", "p1", "p0"}
+	if !slices.Equal(order, want) {
+		t.Fatalf("expected fetch order %v, got %v", want, order)
+	}
+}
+
+func TestFetchAllHonoursConcurrencyLimit(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		io.WriteString(w, rssFixture)
+	}))
+	defer ts.Close()
+
+	s := store.New()
+	for i := 0; i < 8; i++ {
+		s.AddFeed(fmt.Sprintf("Feed %d", i), fmt.Sprintf("%s/%d", ts.URL, i))
+	}
+
+	newTestFetcher(s, WithConcurrency(2)).fetchAll(context.Background(), 0)
+
+	if p := peak.Load(); p > 2 {
+		t.Fatalf("expected at most 2 concurrent fetches, saw %d", p)
+	}
+	if last, ok := s.LastCycle(); !ok || last.Succeeded != 8 {
+		t.Fatalf("expected all 8 feeds fetched, got %+v", last)
+	}
+}
+
+func TestFetchThroughProxy(t *testing.T) {
+	var proxiedHost atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute target URL.
+		proxiedHost.Store(r.URL.Host)
+		io.WriteString(w, rssFixture)
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	s := store.New()
+	f := newTestFetcher(s, WithProxy(proxyURL))
+
+	feed := s.AddFeed("Behind proxy", "http://feeds.example.invalid/rss")
+	articles, _, err := f.fetchFeed(context.Background(), feed)
+	if err != nil {
+		t.Fatalf("fetch through proxy failed: %v", err)
+	}
+
+	if proxiedHost.Load() != "feeds.example.invalid" {
+		t.Fatalf("request did not go through the proxy, saw host %v", proxiedHost.Load())
+	}
+	if len(articles) != 2 {
+		t.Fatalf("expected 2 articles, got %d", len(articles))
+	}
+}
+
+func TestFetchAllRecordsHistory(t *testing.T) {
+	s := store.New(store.WithHistorySize(2))
+	f := newTestFetcher(s)
+
+	ts, _ := countingServer(t, rssFixture)
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	good := s.AddFeed("Good", ts.URL)
+	bad := s.AddFeed("Bad", missing.URL)
+
+	for i := 0; i < 3; i++ {
+		f.fetchAll(context.Background(), 0)
+	}
+
+	history, _ := s.FetchHistory(good.ID)
+	if len(history) != 2 {
+		t.Fatalf("expected 2 capped entries, got %d", len(history))
+	}
+	// The first cycle (2 new articles) has been evicted; later cycles
+	// found nothing new.
+	if history[0].NewArticles != 0 || history[1].NewArticles != 0 {
+		t.Fatalf("expected only the two most recent cycles, got %+v", history)
+	}
+	if !history[0].Timestamp.After(history[1].Timestamp) {
+		t.Fatal("expected newest entry first")
+	}
+
+	history, _ = s.FetchHistory(bad.ID)
+	if len(history) != 2 || history[0].Error == "" {
+		t.Fatalf("expected failed attempts with errors, got %+v", history)
+	}
+}
+
+func TestFetchFeedCapsArticlesPerFetch(t *testing.T) {
+	s := store.New()
+	f := newTestFetcher(s, WithMaxArticlesPerFetch(10))
+
+	ts, _ := countingServer(t, largeFeed(500))
+
+	articles, _, err := f.fetchFeed(context.Background(), models.Feed{ID: "f1", URL: ts.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(articles) != 10 {
+		t.Fatalf("expected 10 articles, got %d", len(articles))
+	}
+	for i, a := range articles {
+		if want := fmt.Sprintf("Item %d", i); a.Title != want {
+			t.Fatalf("expected newest items first, position %d is %q", i, a.Title)
+		}
+	}
+}
+
+func TestFetchFeedSendsCustomHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token xyz" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, rssFixture)
+	}))
+	defer ts.Close()
+
+	f := newTestFetcher(store.New())
+
+	if _, _, err := f.fetchFeed(context.Background(), models.Feed{URL: ts.URL}); Classify(err) != ReasonHTTPStatus {
+		t.Fatalf("expected 401 without the header, got %v", err)
+	}
+
+	feed := models.Feed{URL: ts.URL, Headers: map[string]string{"Authorization": "token xyz"}}
+	articles, _, err := f.fetchFeed(context.Background(), feed)
+	if err != nil {
+		t.Fatalf("expected header to authorize the fetch: %v", err)
+	}
+	if len(articles) != 2 {
+		t.Fatalf("expected 2 articles, got %d", len(articles))
+	}
+}
+
+func TestFetchFeedKeepsRawResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		io.WriteString(w, rssFixture)
+	}))
+	defer ts.Close()
+
+	s := store.New()
+	f := newTestFetcher(s)
+	kept, _ := s.CreateFeed(models.Feed{Name: "Kept", URL: ts.URL, KeepRaw: true})
+	plain, _ := s.CreateFeed(models.Feed{Name: "Plain", URL: ts.URL + "/plain"})
+
+	for _, feed := range []models.Feed{kept, plain} {
+		if _, _, err := f.fetchFeed(context.Background(), feed); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	raw, ok := s.RawResponse(kept.ID)
+	if !ok {
+		t.Fatal("expected the raw response to be kept")
+	}
+	if string(raw.Body) != rssFixture || raw.ContentType != "application/rss+xml; charset=utf-8" || raw.Truncated || raw.FetchedAt.IsZero() {
+		t.Fatalf("unexpected raw response: %+v", raw)
+	}
+	if _, ok := s.RawResponse(plain.ID); ok {
+		t.Fatal("expected nothing kept for a feed without keep_raw")
+	}
+}
+
+func TestFetchFeedSendsCookie(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "abc123" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, rssFixture)
+	}))
+	defer ts.Close()
+
+	f := newTestFetcher(store.New())
+
+	if _, _, err := f.fetchFeed(context.Background(), models.Feed{URL: ts.URL}); Classify(err) != ReasonHTTPStatus {
+		t.Fatalf("expected 403 without the cookie, got %v", err)
+	}
+
+	feed := models.Feed{URL: ts.URL, Cookie: "session=abc123; theme=dark"}
+	articles, _, err := f.fetchFeed(context.Background(), feed)
+	if err != nil {
+		t.Fatalf("expected cookie to unlock the feed: %v", err)
+	}
+	if len(articles) != 2 {
+		t.Fatalf("expected 2 articles, got %d", len(articles))
+	}
+}
+
+func TestFetchFeedDropFilter(t *testing.T) {
+	ts, _ := countingServer(t, rssFixture)
+	f := newTestFetcher(store.New())
+
+	feed := models.Feed{ID: "f1", URL: ts.URL, Filters: []models.Filter{
+		{Type: models.FilterDropRegex, Value: "^On"},
+	}}
+	articles, _, err := f.fetchFeed(context.Background(), feed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(articles) != 1 || articles[0].Title != "Two" {
+		t.Fatalf("expected only %q to survive the filter, got %+v", "Two", articles)
+	}
+}
+
+func TestFetchFeedRewriteFilter(t *testing.T) {
+	ts, _ := countingServer(t, rssFixture)
+	f := newTestFetcher(store.New())
+
+	feed := models.Feed{ID: "f1", Name: "Fixture", URL: ts.URL, Filters: []models.Filter{
+		{Type: models.FilterTitlePrefix},
+		{Type: models.FilterTitlePrefix, Value: ">> "},
+	}}
+	articles, _, err := f.fetchFeed(context.Background(), feed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(articles) != 2 {
+		t.Fatalf("expected 2 articles, got %d", len(articles))
+	}
+	if want := ">> [Fixture] One"; articles[0].Title != want {
+		t.Fatalf("expected title %q, got %q", want, articles[0].Title)
+	}
+}
+
+func TestFetchFeedKeywordRules(t *testing.T) {
+	const feedXML = `<?xml version="1.0"?><rss version="2.0"><channel><title>Noisy</title>
+<item><title>Go 1.23 released</title><link>https://example.com/1</link></item>
+<item><title>SPONSORED: Go hosting</title><link>https://example.com/2</link></item>
+<item><title>Rust news</title><link>https://example.com/3</link><description>Sponsored post</description></item>
+<item><title>Weather</title><link>https://example.com/4</link></item>
+</channel></rss>`
+	ts, _ := countingServer(t, feedXML)
+	f := newTestFetcher(store.New())
+
+	tests := []struct {
+		name             string
+		include, exclude []string
+		want             []string
+	}{
+		{"include only", []string{"go", "RUST"}, nil, []string{"Go 1.23 released", "SPONSORED: Go hosting", "Rust news"}},
+		{"exclude only", nil, []string{"sponsored"}, []string{"Go 1.23 released", "Weather"}},
+		{"combined", []string{"go"}, []string{"sponsored"}, []string{"Go 1.23 released"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := models.Feed{ID: "f1", URL: ts.URL, IncludeKeywords: tt.include, ExcludeKeywords: tt.exclude}
+			articles, _, err := f.fetchFeed(context.Background(), feed)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, a := range articles {
+				got = append(got, a.Title)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewTransformerRejectsBadFilters(t *testing.T) {
+	for _, f := range []models.Filter{
+		{Type: "uppercase"},
+		{Type: models.FilterDropRegex, Value: "("},
+	} {
+		if _, err := NewTransformer(f); err == nil {
+			t.Errorf("expected error for %+v", f)
+		}
+	}
+}
+
+func TestFetchFeedFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.xml")
+	if err := os.WriteFile(path, []byte(rssFixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	feed := models.Feed{ID: "f1", URL: (&url.URL{Scheme: "file", Path: path}).String()}
+
+	if _, _, err := newTestFetcher(store.New()).fetchFeed(context.Background(), feed); err == nil {
+		t.Fatal("expected file feeds to be rejected by default")
+	}
+
+	f := newTestFetcher(store.New(), WithFileFeeds())
+	articles, meta, err := f.fetchFeed(context.Background(), feed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(articles) != 2 || meta.Format != "rss" {
+		t.Fatalf("expected 2 rss articles, got %d (%q)", len(articles), meta.Format)
+	}
+
+	missing := models.Feed{URL: "file://" + filepath.Join(t.TempDir(), "missing.xml")}
+	if _, _, err := f.fetchFeed(context.Background(), missing); err == nil {
+		t.Fatal("expected error for a missing file")
+	}
+
+	dir := models.Feed{URL: (&url.URL{Scheme: "file", Path: t.TempDir()}).String()}
+	if _, _, err := f.fetchFeed(context.Background(), dir); err == nil || !strings.Contains(err.Error(), "not a regular file") {
+		t.Fatalf("expected a directory to be refused, got %v", err)
+	}
+}
+
+func TestFetchFeedFromFileRejectsOversizedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "huge.xml")
+	if err := os.WriteFile(path, []byte(rssFixture+strings.Repeat(" ", 4<<10)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	feed := models.Feed{URL: (&url.URL{Scheme: "file", Path: path}).String()}
+
+	f := newTestFetcher(store.New(), WithFileFeeds(), WithMaxFeedBytes(int64(len(rssFixture))))
+	_, _, err := f.fetchFeed(context.Background(), feed)
+	if !errors.Is(err, ErrFeedTooLarge) || Classify(err) != ReasonTooLarge {
+		t.Fatalf("expected ErrFeedTooLarge classified as %s, got %v", ReasonTooLarge, err)
+	}
+}
+
+func TestStripHTML(t *testing.T) {
+	in := `<p>Hello &amp; <b>welcome</b></p>
+<script>alert("x")</script><p>to   the   blog</p>`
+
+	if got, want := stripHTML(in), "Hello & welcome to the blog"; got != want {
+		t.Fatalf("stripHTML = %q, want %q", got, want)
+	}
+}
+
+func TestTruncateWords(t *testing.T) {
+	cases := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"short text", 280, "short text"},
+		{"the quick brown fox jumps", 12, "the quick…"},
+		{"one, two, three", 11, "one, two…"},
+		{"ação única ótima", 10, "ação…"},
+		{"unbreakableword", 6, "unbre…"},
+	}
+
+	for _, tc := range cases {
+		got := truncateWords(tc.in, tc.max)
+		if got != tc.want {
+			t.Errorf("truncateWords(%q, %d) = %q, want %q", tc.in, tc.max, got, tc.want)
+		}
+		if n := len([]rune(got)); n > tc.max {
+			t.Errorf("truncateWords(%q, %d) returned %d runes", tc.in, tc.max, n)
+		}
+	}
+}
+
+func TestFetchFeedBuildsExcerpt(t *testing.T) {
+	long := strings.Repeat("word ", 100)
+	doc := `<?xml version="1.0"?><rss version="2.0"><channel><title>T</title>
+<item><title>Long</title><link>https://example.com/l</link><description><![CDATA[<p>` + long + `</p>]]></description></item>
+<item><title>Short</title><link>https://example.com/s</link><description><![CDATA[<em>Brief</em> note]]></description></item>
+</channel></rss>`
+	ts, _ := countingServer(t, doc)
+
+	articles, _, err := newTestFetcher(store.New()).fetchFeed(context.Background(), models.Feed{URL: ts.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ex := articles[0].Excerpt; len([]rune(ex)) > excerptLength || !strings.HasSuffix(ex, "word…") {
+		t.Fatalf("unexpected long excerpt: %q", ex)
+	}
+	if articles[1].Excerpt != "Brief note" {
+		t.Fatalf("expected short content untouched, got %q", articles[1].Excerpt)
+	}
+}
+
+func TestFetchFeedTruncatesLongText(t *testing.T) {
+	doc := `<?xml version="1.0"?><rss version="2.0"><channel><title>T</title>
+<item><title>` + strings.Repeat("ünïcode ", 20) + `</title><link>https://example.com/l</link><description><![CDATA[<p>A <b>very</b> long description indeed</p>]]></description></item>
+<item><title>Short title</title><link>https://example.com/s</link><description><![CDATA[<p>Short</p>]]></description></item>
+</channel></rss>`
+	ts, _ := countingServer(t, doc)
+
+	f := newTestFetcher(store.New(), WithMaxTextLengths(20, 25))
+	articles, _, err := f.fetchFeed(context.Background(), models.Feed{URL: ts.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := articles[0].Title, "ünïcode ünïcode…"; got != want {
+		t.Errorf("expected title %q, got %q", want, got)
+	}
+	if got, want := articles[0].Description, "A very long description…"; got != want {
+		t.Errorf("expected description %q, got %q", want, got)
+	}
+	if articles[1].Title != "Short title" || articles[1].Description != "<p>Short</p>" {
+		t.Errorf("expected short text untouched, got %q and %q", articles[1].Title, articles[1].Description)
+	}
+
+	// Without limits nothing is cut.
+	articles, _, _ = newTestFetcher(store.New()).fetchFeed(context.Background(), models.Feed{URL: ts.URL})
+	if n := len([]rune(articles[0].Title)); n != 159 {
+		t.Errorf("expected the full title without a limit, got %d runes", n)
+	}
+}
+
+func TestFetchFeedEstimatesReadingTime(t *testing.T) {
+	doc := `<?xml version="1.0"?><rss version="2.0"><channel><title>T</title>
+<item><title>Long</title><link>https://example.com/l</link><description><![CDATA[<p>` + strings.Repeat("word ", 1000) + `</p><script>var hidden = 1;</script>]]></description></item>
+<item><title>Short</title><link>https://example.com/s</link><description><![CDATA[<em>Brief</em> note]]></description></item>
+<item><title>Empty</title><link>https://example.com/e</link></item>
+</channel></rss>`
+	ts, _ := countingServer(t, doc)
+
+	articles, _, err := newTestFetcher(store.New()).fetchFeed(context.Background(), models.Feed{URL: ts.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		words, seconds int
+	}{
+		{1000, 300}, // five minutes at 200 words per minute
+		{2, 1},      // rounded up to a second
+		{0, 0},
+	}
+	for i, want := range cases {
+		if a := articles[i]; a.WordCount != want.words || a.ReadingTimeSeconds != want.seconds {
+			t.Errorf("%s: expected %d words and %ds, got %d words and %ds",
+				a.Title, want.words, want.seconds, a.WordCount, a.ReadingTimeSeconds)
+		}
+	}
+}
+
+const podcastFixture = `<?xml version="1.0"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel><title>Podcast</title>
+<item>
+  <title>Episode 2</title><link>https://example.com/ep2</link>
+  <enclosure url="https://cdn.example.com/ep2.mp3" type="audio/mpeg" length="12345678"/>
+  <enclosure url="https://cdn.example.com/ep2.ogg" type="audio/ogg" length="unknown"/>
+  <itunes:duration>01:02:03</itunes:duration>
+  <itunes:image href="https://cdn.example.com/ep2.jpg"/>
+  <itunes:episode>2</itunes:episode>
+</item>
+<item>
+  <title>Show notes</title><link>https://example.com/notes</link>
+</item>
+</channel></rss>`
+
+func TestFetchFeedReadsPodcastEnclosures(t *testing.T) {
+	ts, _ := countingServer(t, podcastFixture)
+
+	articles, _, err := newTestFetcher(store.New()).fetchFeed(context.Background(), models.Feed{URL: ts.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(articles) != 2 {
+		t.Fatalf("expected 2 articles, got %d", len(articles))
+	}
+
+	episode, notes := articles[0], articles[1]
+	want := []models.Enclosure{
+		{URL: "https://cdn.example.com/ep2.mp3", Type: "audio/mpeg", Length: 12345678},
+		{URL: "https://cdn.example.com/ep2.ogg", Type: "audio/ogg"},
+	}
+	if !slices.Equal(episode.Enclosures, want) {
+		t.Fatalf("expected enclosures %+v, got %+v", want, episode.Enclosures)
+	}
+	if p := episode.Podcast; p == nil || p.Duration != "01:02:03" || p.Image != "https://cdn.example.com/ep2.jpg" || p.Episode != "2" {
+		t.Fatalf("unexpected podcast details: %+v", p)
+	}
+
+	if notes.Enclosures != nil || notes.Podcast != nil {
+		t.Fatalf("expected no enclosures or podcast details, got %+v %+v", notes.Enclosures, notes.Podcast)
+	}
+	body, _ := json.Marshal(notes)
+	if strings.Contains(string(body), "enclosures") || strings.Contains(string(body), "podcast") {
+		t.Fatalf("expected both fields left out of JSON, got %s", body)
+	}
+}
+
+func TestFetchFeedExtractsImage(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/"><channel><title>T</title>
+<item><title>Media</title><link>https://example.com/media</link>
+  <media:thumbnail url="https://cdn.example.com/thumb.jpg"/>
+  <description><![CDATA[<img src="https://cdn.example.com/inline.jpg">]]></description></item>
+<item><title>Media content</title><link>https://example.com/media-content</link>
+  <media:content url="https://cdn.example.com/clip.mp4" type="video/mp4"/>
+  <media:content url="https://cdn.example.com/still.jpg" medium="image"/></item>
+<item><title>Enclosure</title><link>https://example.com/enclosure</link>
+  <enclosure url="https://cdn.example.com/ep.mp3" type="audio/mpeg" length="1"/>
+  <enclosure url="https://cdn.example.com/cover.png" type="image/png" length="1"/></item>
+<item><title>Inline</title><link>https://example.com/posts/inline</link>
+  <description><![CDATA[<p>Intro</p><img alt="no source"><img src="/img/chart.png"><img src="https://cdn.example.com/second.png">]]></description></item>
+<item><title>Data</title><link>https://example.com/data</link>
+  <description><![CDATA[<img src="data:image/png;base64,AAAA">]]></description></item>
+<item><title>None</title><link>https://example.com/none</link><description>Just text</description></item>
+</channel></rss>`
+	ts, _ := countingServer(t, doc)
+
+	articles, _, err := newTestFetcher(store.New()).fetchFeed(context.Background(), models.Feed{URL: ts.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]string{
+		"Media":         "https://cdn.example.com/thumb.jpg",
+		"Media content": "https://cdn.example.com/still.jpg",
+		"Enclosure":     "https://cdn.example.com/cover.png",
+		"Inline":        "https://example.com/img/chart.png",
+		"Data":          "",
+		"None":          "",
+	}
+	if len(articles) != len(want) {
+		t.Fatalf("expected %d articles, got %d", len(want), len(articles))
+	}
+	for _, a := range articles {
+		if a.ImageURL != want[a.Title] {
+			t.Errorf("%s: expected image %q, got %q", a.Title, want[a.Title], a.ImageURL)
+		}
+	}
+}
+
+func TestArticleIDsFollowFeedIDStrategy(t *testing.T) {
+	// Each fetch tags the links with a new tracking parameter.
+	var fetches atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := fetches.Add(1)
+		fmt.Fprintf(w, `<rss version="2.0"><channel><title>T</title>
+<item><title>One</title><guid isPermaLink="false">story-1</guid><link>https://example.com/1?utm=%[1]d</link></item>
+<item><title>Two</title><guid isPermaLink="false">story-2</guid><link>https://example.com/2?utm=%[1]d</link></item>
+<item><title>Bare</title><pubDate>Tue, 02 Jan 2024 10:00:00 GMT</pubDate></item>
+<item><title>Bare too</title><pubDate>Tue, 02 Jan 2024 10:00:00 GMT</pubDate></item>
+</channel></rss>`, n)
+	}))
+	t.Cleanup(ts.Close)
+
+	ids := func(strategy string) []string {
+		t.Helper()
+		articles, _, err := newTestFetcher(store.New()).fetchFeed(context.Background(), models.Feed{ID: "f1", URL: ts.URL, IDStrategy: strategy})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var ids []string
+		for _, a := range articles {
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
+
+	first, second := ids(""), ids(models.IDStrategyGUID)
+	if !slices.Equal(first, second) {
+		t.Fatalf("expected GUID-based IDs to survive link changes, got %v then %v", first, second)
+	}
+	if first[2] == first[3] {
+		t.Fatal("expected items without GUID or link to get distinct IDs from their titles")
+	}
+	if byLink := ids(models.IDStrategyLink); byLink[0] == first[0] || byLink[2] != first[2] {
+		t.Fatalf("expected link-based IDs for linked items only, got %v vs %v", byLink, first)
+	}
+
+	// Re-fetching stores nothing new and is not mistaken for ID collisions.
+	s := store.New()
+	s.AddFeed("GUIDs", ts.URL)
+	f := newTestFetcher(s)
+	f.fetchAll(context.Background(), 0)
+	f.fetchAll(context.Background(), 0)
+	if stats := s.DedupStats(); stats.Stored != 4 || stats.DuplicateID != 4 || stats.IDCollisions != 0 {
+		t.Fatalf("unexpected dedup stats after re-fetch: %+v", stats)
+	}
+}
+
+func TestFetchAllTreatsItemlessFeedAsSuccess(t *testing.T) {
+	itemless, _ := countingServer(t, `<rss version="2.0"><channel><title>Quiet</title></channel></rss>`)
+	empty, _ := countingServer(t, " \n")
+
+	s := store.New()
+	quiet := s.AddFeed("Quiet", itemless.URL)
+	blank := s.AddFeed("Blank", empty.URL)
+
+	var logs bytes.Buffer
+	f := New(s, time.Minute, slog.New(slog.NewTextHandler(&logs, nil)))
+	f.fetchAll(context.Background(), 0)
+
+	if _, meta, err := f.fetchFeed(context.Background(), quiet); !errors.Is(err, ErrNoItems) || meta.Format != "rss" {
+		t.Fatalf("expected ErrNoItems with the feed's details, got %v, %+v", err, meta)
+	}
+	if reason := Classify(ErrNoItems); reason != "" {
+		t.Fatalf("expected no failure reason for ErrNoItems, got %q", reason)
+	}
+	if history, _ := s.FetchHistory(quiet.ID); len(history) != 1 || history[0].Error != "" {
+		t.Fatalf("expected a successful fetch for the itemless feed, got %+v", history)
+	}
+	if !strings.Contains(logs.String(), `msg="feed fetched with no items" feed_id=`+quiet.ID) {
+		t.Fatalf("expected the itemless feed to be logged distinctly, got:\n%s", logs.String())
+	}
+
+	if history, _ := s.FetchHistory(blank.ID); len(history) != 1 || history[0].Error == "" {
+		t.Fatalf("expected a failed fetch for the empty body, got %+v", history)
+	}
+	if _, _, err := f.fetchFeed(context.Background(), blank); !errors.Is(err, ErrEmptyBody) {
+		t.Fatalf("expected ErrEmptyBody, got %v", err)
+	}
+}
+
+func TestFetchAllRecordsFeedFormat(t *testing.T) {
+	s := store.New()
+	f := newTestFetcher(s)
+
+	rssServer, _ := countingServer(t, rssFixture)
+	atomServer, _ := countingServer(t, atomFixture)
+	rss := s.AddFeed("RSS", rssServer.URL)
+	atom := s.AddFeed("Atom", atomServer.URL)
+
+	if got, _ := s.GetFeed(rss.ID); got.Format != "" {
+		t.Fatalf("expected no format before the first fetch, got %q", got.Format)
+	}
+
+	f.fetchAll(context.Background(), 0)
+
+	if got, _ := s.GetFeed(rss.ID); got.Format != "rss" {
+		t.Fatalf("expected rss, got %q", got.Format)
+	}
+	if got, _ := s.GetFeed(atom.ID); got.Format != "atom" {
+		t.Fatalf("expected atom, got %q", got.Format)
+	}
+}
+
+func TestFetchAllRecordsLanguageAndImage(t *testing.T) {
+	doc := `<?xml version="1.0"?><rss version="2.0"><channel><title>T</title>
+<language>pt-BR</language>
+<image><url>https://example.com/logo.png</url><title>T</title><link>https://example.com</link></image>
+<item><title>One</title><link>https://example.com/1</link></item>
+</channel></rss>`
+	s := store.New()
+	ts, _ := countingServer(t, doc)
+	plain, _ := countingServer(t, rssFixture)
+	feed := s.AddFeed("Branded", ts.URL)
+	other := s.AddFeed("Plain", plain.URL)
+
+	newTestFetcher(s).fetchAll(context.Background(), 0)
+
+	got, _ := s.GetFeed(feed.ID)
+	if got.Language != "pt-BR" || got.ImageURL != "https://example.com/logo.png" {
+		t.Fatalf("expected language and image from the feed, got %q and %q", got.Language, got.ImageURL)
+	}
+	if got, _ := s.GetFeed(other.ID); got.Language != "" || got.ImageURL != "" {
+		t.Fatalf("expected no language or image, got %q and %q", got.Language, got.ImageURL)
+	}
+}
+
+func TestFetchAllWarnsOnSlowFetch(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, rssFixture)
+	}))
+	t.Cleanup(slow.Close)
+	fast, _ := countingServer(t, rssFixture)
+
+	s := store.New()
+	slowFeed := s.AddFeed("Slow", slow.URL)
+	s.AddFeed("Fast", fast.URL)
+
+	var logs bytes.Buffer
+	f := New(s, time.Minute, slog.New(slog.NewTextHandler(&logs, nil)), WithSlowFetchWarning(50*time.Millisecond))
+	f.fetchAll(context.Background(), 0)
+
+	if n := f.SlowFetches(); n != 1 {
+		t.Fatalf("expected 1 slow fetch, got %d", n)
+	}
+	if !strings.Contains(logs.String(), `level=WARN msg="slow feed fetch" feed_id=`+slowFeed.ID) {
+		t.Fatalf("expected a warning for the slow feed, got:\n%s", logs.String())
+	}
+
+	// Without a threshold nothing is counted.
+	f = newTestFetcher(s)
+	f.fetchAll(context.Background(), 0)
+	if n := f.SlowFetches(); n != 0 {
+		t.Fatalf("expected no slow fetches without a threshold, got %d", n)
+	}
+}
+
+func TestFetchFeedLenientAndStrict(t *testing.T) {
+	const doc = `<?xml version="1.0"?><rss version="2.0"><channel><title>T</title>
+<item><title>One</title><link>https://example.com/1</link></item>
+</channel></rss>`
+	cases := []struct {
+		name     string
+		body     string
+		strictOK bool
+	}{
+		{"clean", doc, true},
+		{"bom", "\ufeff" + doc, true},
+		{"bom and leading whitespace", "\ufeff\n\n  " + doc, false},
+		{"text before the document", "Warning: session_start() failed\n" + doc, false},
+		{"undefined entity", strings.Replace(doc, "One", "One&nbsp;Two", 1), false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts, _ := countingServer(t, tc.body)
+			f := newTestFetcher(store.New())
+
+			articles, _, err := f.fetchFeed(context.Background(), models.Feed{URL: ts.URL})
+			if err != nil || len(articles) != 1 {
+				t.Fatalf("lenient: expected 1 article, got %d (%v)", len(articles), err)
+			}
+
+			_, _, err = f.fetchFeed(context.Background(), models.Feed{URL: ts.URL, Strict: true})
+			if tc.strictOK && err != nil {
+				t.Fatalf("strict: unexpected error: %v", err)
+			}
+			if !tc.strictOK && err == nil {
+				t.Fatal("strict: expected the document to be rejected")
+			}
+		})
+	}
+}
+
+func TestFetchFeedTranscodesToUTF8(t *testing.T) {
+	// "Café ä la carte" in ISO-8859-1.
+	latin1 := "Caf\xe9 \xe4 la carte"
+	doc := func(decl string) string {
+		return decl + `<rss version="2.0"><channel><title>T</title>
+<item><title>` + latin1 + `</title><link>https://example.com/1</link></item>
+</channel></rss>`
+	}
+	cases := []struct {
+		name, contentType, body string
+	}{
+		{"header only", "application/rss+xml; charset=ISO-8859-1", doc(`<?xml version="1.0"?>`)},
+		{"header and declaration", "text/xml; charset=iso-8859-1", doc(`<?xml version="1.0" encoding="ISO-8859-1"?>`)},
+		{"declaration only", "application/rss+xml", doc(`<?xml version="1.0" encoding='ISO-8859-1'?>`)},
+		{"header overrides declaration", "text/xml; charset=windows-1252", doc(`<?xml version="1.0" encoding="UTF-8"?>`)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				io.WriteString(w, tc.body)
+			}))
+			t.Cleanup(ts.Close)
+
+			for _, strict := range []bool{false, true} {
+				articles, _, err := newTestFetcher(store.New()).fetchFeed(context.Background(), models.Feed{URL: ts.URL, Strict: strict})
+				if err != nil {
+					t.Fatalf("strict=%v: unexpected error: %v", strict, err)
+				}
+				if got := articles[0].Title; got != "Café ä la carte" {
+					t.Fatalf("strict=%v: expected UTF-8 title, got %q", strict, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchFeedReadsZonelessDatesInFeedTimezone(t *testing.T) {
+	ts, _ := countingServer(t, `<rss version="2.0"><channel><title>T</title>
+<item><title>Naive</title><link>https://example.com/1</link><pubDate>Tue, 02 Jan 2024 10:00:00</pubDate></item>
+<item><title>Zoned</title><link>https://example.com/2</link><pubDate>Tue, 02 Jan 2024 10:00:00 +0100</pubDate></item>
+<item><title>ISO</title><link>https://example.com/3</link><pubDate>2024-07-02T10:00:00</pubDate></item>
+</channel></rss>`)
+
+	published := func(timezone string) map[string]time.Time {
+		t.Helper()
+		articles, _, err := newTestFetcher(store.New()).fetchFeed(context.Background(), models.Feed{URL: ts.URL, Timezone: timezone})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got := make(map[string]time.Time)
+		for _, a := range articles {
+			got[a.Title] = a.PublishedAt
+		}
+		return got
+	}
+
+	got := published("")
+	if want := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC); !got["Naive"].Equal(want) {
+		t.Fatalf("without a timezone expected %v, got %v", want, got["Naive"])
+	}
+
+	got = published("America/New_York")
+	want := map[string]time.Time{
+		"Naive": time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC), // EST
+		"Zoned": time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC),
+		"ISO":   time.Date(2024, 7, 2, 14, 0, 0, 0, time.UTC), // EDT
+	}
+	for title, w := range want {
+		if !got[title].Equal(w) || got[title].Location() != time.UTC {
+			t.Errorf("%s: expected %v, got %v", title, w, got[title])
+		}
+	}
+}
+
+func TestLastCycleArticles(t *testing.T) {
+	ts, _ := countingServer(t, rssFixture)
+	s := store.New()
+	s.AddFeed("Fixture", ts.URL)
+	f := newTestFetcher(s)
+
+	if got := f.LastCycleArticles(); got == nil || len(got) != 0 {
+		t.Fatalf("expected an empty list before any cycle, got %v", got)
+	}
+
+	f.fetchAll(context.Background(), 0)
+	var titles []string
+	for _, a := range f.LastCycleArticles() {
+		titles = append(titles, a.Title)
+	}
+	slices.Sort(titles)
+	if want := []string{"One", "Two"}; !slices.Equal(titles, want) {
+		t.Fatalf("expected %v from the first cycle, got %v", want, titles)
+	}
+
+	// Nothing is new the second time round.
+	f.fetchAll(context.Background(), 0)
+	if got := f.LastCycleArticles(); len(got) != 0 {
+		t.Fatalf("expected no new articles from the second cycle, got %d", len(got))
+	}
+}
+
+func TestFetchAllLogsNewArticlesAtDebug(t *testing.T) {
+	for _, level := range []slog.Level{slog.LevelInfo, slog.LevelDebug} {
+		ts, _ := countingServer(t, rssFixture)
+		s := store.New()
+		s.AddFeed("Fixture", ts.URL)
+
+		var logs bytes.Buffer
+		f := New(s, time.Minute, slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: level})))
+		f.fetchAll(context.Background(), 0)
+		f.fetchAll(context.Background(), 0)
+
+		got := strings.Count(logs.String(), `msg="new article"`)
+		if level == slog.LevelInfo && got != 0 {
+			t.Fatalf("expected no article titles at info level, got:\n%s", logs.String())
+		}
+		// Only the first cycle finds new articles.
+		if level == slog.LevelDebug && (got != 2 || !strings.Contains(logs.String(), "title=One link=https://example.com/1")) {
+			t.Fatalf("expected both titles logged once at debug level, got:\n%s", logs.String())
+		}
+	}
+}
+
+func TestFetchAllWithBatchedSaves(t *testing.T) {
+	ts1, _ := countingServer(t, rssFixture)
+	ts2, _ := countingServer(t, rssFixture)
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	s := store.New()
+	one := s.AddFeed("One", ts1.URL)
+	two := s.AddFeed("Two", ts2.URL)
+	broken := s.AddFeed("Broken", missing.URL)
+
+	f := newTestFetcher(s, WithBatchedSaves())
+	f.fetchAll(context.Background(), 0)
+
+	if got := len(s.ListArticles("", 0)); got != 4 {
+		t.Fatalf("expected 4 articles, got %d", got)
+	}
+	for _, feed := range []models.Feed{one, two} {
+		history, _ := s.FetchHistory(feed.ID)
+		if len(history) != 1 || history[0].NewArticles != 2 || history[0].Error != "" {
+			t.Fatalf("%s: expected one successful fetch with 2 new articles, got %+v", feed.Name, history)
+		}
+	}
+	if history, _ := s.FetchHistory(broken.ID); len(history) != 1 || history[0].Error == "" {
+		t.Fatalf("expected the broken feed's failure recorded, got %+v", history)
+	}
+	summary, _ := s.LastCycle()
+	if summary.Succeeded != 2 || summary.Failed != 1 || summary.NewArticles != 4 {
+		t.Fatalf("unexpected cycle summary: %+v", summary)
+	}
+	if got := len(f.LastCycleArticles()); got != 4 {
+		t.Fatalf("expected 4 articles from the last cycle, got %d", got)
+	}
+
+	f.fetchAll(context.Background(), 0)
+	if summary, _ := s.LastCycle(); summary.NewArticles != 0 {
+		t.Fatalf("expected nothing new on the second cycle, got %+v", summary)
+	}
+}
+
+func TestFetchFeedDecompressesBody(t *testing.T) {
+	compress := func(w io.WriteCloser, buf *bytes.Buffer) string {
+		io.WriteString(w, rssFixture)
+		w.Close()
+		return buf.String()
+	}
+	var gz, zl, raw bytes.Buffer
+	gzipped := compress(gzip.NewWriter(&gz), &gz)
+	zlibbed := compress(zlib.NewWriter(&zl), &zl)
+	fw, _ := flate.NewWriter(&raw, flate.DefaultCompression)
+	deflated := compress(fw, &raw)
+
+	cases := []struct {
+		name, encoding, body string
+	}{
+		{"gzip", "gzip", gzipped},
+		{"deflate", "deflate", zlibbed},
+		{"raw deflate", "deflate", deflated},
+		{"gzip without header", "", gzipped},
+		{"identity", "identity", rssFixture},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+					t.Errorf("expected Accept-Encoding to offer gzip, got %q", r.Header.Get("Accept-Encoding"))
+				}
+				if tc.encoding != "" {
+					w.Header().Set("Content-Encoding", tc.encoding)
+				}
+				io.WriteString(w, tc.body)
+			}))
+			t.Cleanup(ts.Close)
+
+			articles, _, err := newTestFetcher(store.New()).fetchFeed(context.Background(), models.Feed{URL: ts.URL})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(articles) != 2 {
+				t.Fatalf("expected 2 articles, got %d", len(articles))
+			}
+		})
+	}
+}
+
+func TestFetchFeedRejectsOversizedBody(t *testing.T) {
+	const limit = 64 << 10
+
+	// Zeros compress a thousandfold, so this body is small on the wire but
+	// far over the limit once unzipped.
+	var bomb bytes.Buffer
+	zw := gzip.NewWriter(&bomb)
+	zw.Write(make([]byte, 16<<20))
+	zw.Close()
+	if bomb.Len() >= limit {
+		t.Fatalf("expected the compressed body under the limit, got %d bytes", bomb.Len())
+	}
+
+	cases := []struct {
+		name, encoding string
+		body           []byte
+	}{
+		{"gzip bomb", "gzip", bomb.Bytes()},
+		{"plain", "", bytes.Repeat([]byte(" "), limit+1)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tc.encoding != "" {
+					w.Header().Set("Content-Encoding", tc.encoding)
+				}
+				w.Write(tc.body)
+			}))
+			t.Cleanup(ts.Close)
+
+			f := newTestFetcher(store.New(), WithMaxFeedBytes(limit))
+			_, _, err := f.fetchFeed(context.Background(), models.Feed{URL: ts.URL})
+			if !errors.Is(err, ErrFeedTooLarge) || Classify(err) != ReasonTooLarge {
+				t.Fatalf("expected ErrFeedTooLarge classified as %s, got %v", ReasonTooLarge, err)
+			}
+		})
+	}
+}
+
+func TestStatusReportsCycleProgress(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		io.WriteString(w, rssFixture)
+	}))
+	t.Cleanup(slow.Close)
+	fast, _ := countingServer(t, rssFixture)
+
+	s := store.New()
+	s.AddFeed("Fast", fast.URL)
+	s.AddFeed("Slow 1", slow.URL+"/1")
+	s.AddFeed("Slow 2", slow.URL+"/2")
+	f := newTestFetcher(s)
+
+	if st := f.Status(); st.Running {
+		t.Fatalf("expected no cycle running, got %+v", st)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		f.fetchAll(context.Background(), 0)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for f.Status().Done < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the fast feed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	st := f.Status()
+	if !st.Running || st.StartedAt == nil || st.Total != 3 || st.Done != 1 || st.Pending != 2 {
+		t.Fatalf("unexpected mid-cycle status %+v", st)
+	}
+
+	close(release)
+	<-done
+	if st := f.Status(); st.Running || st.Total != 0 {
+		t.Fatalf("expected an idle status after the cycle, got %+v", st)
+	}
+}
+
+func TestStartSpreadsInitialFetches(t *testing.T) {
+	const feeds = 20
+
+	var mu sync.Mutex
+	var hits []time.Time
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		hits = append(hits, time.Now())
+		mu.Unlock()
+		io.WriteString(w, rssFixture)
+	}))
+	defer ts.Close()
+
+	s := store.New()
+	for i := 0; i < feeds; i++ {
+		s.AddFeed(fmt.Sprintf("Feed %d", i), fmt.Sprintf("%s/%d", ts.URL, i))
+	}
+
+	f := New(s, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)), WithStartupSpread(500*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.Start(ctx)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(hits)
+		mu.Unlock()
+		if n == feeds {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("only %d of %d feeds fetched", n, feeds)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+
+	first, last := hits[0], hits[0]
+	for _, h := range hits {
+		if h.Before(first) {
+			first = h
+		}
+		if h.After(last) {
+			last = h
+		}
+	}
+	if last.Sub(first) < 100*time.Millisecond {
+		t.Fatalf("expected startup fetches spread out, all happened within %v", last.Sub(first))
+	}
+}
+
+// lockedBuffer is a bytes.Buffer safe to log to from a running fetcher.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestStartCompactsIndexesOnItsOwnTicker(t *testing.T) {
+	ts, _ := countingServer(t, rssFixture)
+	s := store.New(store.WithTitleDedup(time.Hour))
+	feed := s.AddFeed("Feed", ts.URL)
+	staleIndex := func() {
+		s.SaveArticles([]models.Article{{ID: "gone", FeedID: feed.ID, Title: "Gone"}})
+		s.DeleteArticle("gone")
+	}
+
+	// A fetch cycle alone leaves the stale entry for the compaction ticker.
+	staleIndex()
+	newTestFetcher(s).fetchAll(context.Background(), 0)
+	if removed := s.CompactIndexes(); removed != 1 {
+		t.Fatalf("expected the cycle to leave 1 entry to compact, got %d", removed)
+	}
+
+	staleIndex()
+	var logs lockedBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	f := New(s, time.Hour, logger, WithStartupSpread(0), WithCompactInterval(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for !strings.Contains(logs.String(), "store indexes compacted") {
+		select {
+		case <-deadline:
+			t.Fatal("expected the compaction ticker to compact the indexes")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func TestSetIntervalAppliesToRunningFetcher(t *testing.T) {
+	var mu sync.Mutex
+	var hits []time.Time
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		hits = append(hits, time.Now())
+		mu.Unlock()
+		io.WriteString(w, rssFixture)
+	}))
+	defer ts.Close()
+
+	s := store.New()
+	s.AddFeed("Feed", ts.URL)
+	f := newTestFetcher(s, WithStartupSpread(0)) // polls every minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitForHits := func(n int) []time.Time {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		for {
+			mu.Lock()
+			got := slices.Clone(hits)
+			mu.Unlock()
+			if len(got) >= n {
+				return got
+			}
+			select {
+			case <-deadline:
+				t.Fatalf("only %d of %d fetches happened", len(got), n)
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}
+
+	waitForHits(1) // startup cycle
+
+	if err := f.SetInterval(0); err == nil {
+		t.Fatal("expected error for a zero interval")
+	}
+	if err := f.SetInterval(50 * time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Interval() != 50*time.Millisecond {
+		t.Fatalf("expected interval 50ms, got %v", f.Interval())
+	}
+
+	got := waitForHits(4)
+	for i := 2; i < len(got); i++ {
+		if gap := got[i].Sub(got[i-1]); gap < 40*time.Millisecond {
+			t.Fatalf("cycles %d and %d only %v apart", i-1, i, gap)
+		}
+	}
+}
+
+func TestRetryAfterSkipsFeedUntilWindowPasses(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if hits.Add(1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		io.WriteString(w, rssFixture)
+	}))
+	defer ts.Close()
+
+	s := store.New()
+	f := newTestFetcher(s)
+	feed := s.AddFeed("Busy", ts.URL)
+
+	f.fetchAll(context.Background(), 0)
+
+	got, _ := s.GetFeed(feed.ID)
+	if until := time.Until(got.NextFetchAt); until < 59*time.Minute || until > time.Hour {
+		t.Fatalf("expected backoff of about an hour, got %v", until)
+	}
+	history, _ := s.FetchHistory(feed.ID)
+	if len(history) != 1 || history[0].Error == "" {
+		t.Fatalf("expected a failed attempt in history, got %+v", history)
+	}
+
+	f.fetchAll(context.Background(), 0)
+	if hits.Load() != 1 {
+		t.Fatalf("feed was fetched during its Retry-After window (%d hits)", hits.Load())
+	}
+
+	// Simulate the window elapsing.
+	s.SetNextFetch(feed.ID, time.Now().Add(-time.Second))
+	f.fetchAll(context.Background(), 0)
+	if hits.Load() != 2 {
+		t.Fatalf("expected the feed to be fetched once the window passed, got %d hits", hits.Load())
+	}
+}
+
+func TestFetchFeedDetectsChallengePage(t *testing.T) {
+	challenge := `<!DOCTYPE html><html><head><title>Just a moment...</title></head>
+<body><div id="cf-browser-verification">Checking your browser</div>
+<script src="/cdn-cgi/challenge-platform/h/b/orchestrate/jsch/v1"></script></body></html>`
+
+	cases := []struct {
+		name, contentType, body string
+		status                  int
+		reason                  string
+	}{
+		{"challenge", "text/html; charset=UTF-8", challenge, http.StatusServiceUnavailable, ReasonChallenge},
+		{"forbidden challenge", "text/html", challenge, http.StatusForbidden, ReasonChallenge},
+		{"plain outage", "text/html", "<html><body>Service Unavailable</body></html>", http.StatusServiceUnavailable, ReasonHTTPStatus},
+		{"not html", "text/plain", "captcha", http.StatusForbidden, ReasonHTTPStatus},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.WriteHeader(tc.status)
+				io.WriteString(w, tc.body)
+			}))
+			defer ts.Close()
+
+			_, _, err := newTestFetcher(store.New()).fetchFeed(context.Background(), models.Feed{URL: ts.URL})
+			if got := Classify(err); got != tc.reason {
+				t.Fatalf("expected reason %q, got %q (err %v)", tc.reason, got, err)
+			}
+			if tc.reason == ReasonChallenge && !strings.Contains(err.Error(), "feed blocked by challenge") {
+				t.Fatalf("expected the error to name the challenge, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if got, ok := parseRetryAfter("120", now); !ok || !got.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("seconds form: got %v, %v", got, ok)
+	}
+
+	date := "Mon, 01 Jan 2024 13:00:00 GMT"
+	if got, ok := parseRetryAfter(date, now); !ok || !got.Equal(now.Add(time.Hour)) {
+		t.Fatalf("HTTP-date form: got %v, %v", got, ok)
+	}
+
+	if _, ok := parseRetryAfter("soon", now); ok {
+		t.Fatal("expected garbage to be rejected")
+	}
+}
+
+func TestGenerateIDLength(t *testing.T) {
+	// Find two links whose IDs collide when truncated to one byte. The
+	// same pair must not collide at full length.
+	seen := make(map[string]string)
+	var a, b string
+	for i := 0; a == ""; i++ {
+		link := fmt.Sprintf("https://example.com/%d", i)
+		short := generateID("f1", link, 1)
+		if prev, ok := seen[short]; ok {
+			a, b = prev, link
+		}
+		seen[short] = link
+	}
+
+	if generateID("f1", a, 1) != generateID("f1", b, 1) {
+		t.Fatal("expected a collision at 1 byte")
+	}
+	if generateID("f1", a, 32) == generateID("f1", b, 32) {
+		t.Fatal("full-length IDs must not collide")
+	}
+
+	s := store.New()
+	f := newTestFetcher(s)
+	if f.idLength != 32 {
+		t.Fatalf("expected full-length IDs by default, got %d bytes", f.idLength)
+	}
+	if id := generateID("f1", a, f.idLength); len(id) != 64 {
+		t.Fatalf("expected 64 hex chars, got %d", len(id))
+	}
+}
+
+func TestFetchAllRecordsCycleSummary(t *testing.T) {
+	s := store.New()
+	f := newTestFetcher(s)
+
+	good, _ := countingServer(t, rssFixture)
+	atom, _ := countingServer(t, atomFixture)
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	s.AddFeed("Good", good.URL)
+	s.AddFeed("Atom", atom.URL)
+	s.AddFeed("Missing", missing.URL)
+	s.AddFeed("Bad URL", missing.URL+"/\x7f")
+
+	if _, ok := s.LastCycle(); ok {
+		t.Fatal("expected no summary before the first cycle")
+	}
+
+	f.fetchAll(context.Background(), 0)
+
+	summary, ok := s.LastCycle()
+	if !ok {
+		t.Fatal("expected a cycle summary")
+	}
+	if summary.Total != 4 || summary.Succeeded != 2 || summary.Failed != 2 || summary.NewArticles != 3 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestSelfCheck(t *testing.T) {
+	good, _ := countingServer(t, rssFixture)
+	garbled, _ := countingServer(t, "not a feed")
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	skipped, skippedHits := countingServer(t, rssFixture)
+
+	s := store.New()
+	s.AddFeed("Good", good.URL)
+	s.AddFeed("Garbled", garbled.URL)
+	s.AddFeed("Down", down.URL)
+	disabled := s.AddFeed("Disabled", skipped.URL)
+	s.SetFeedEnabled(disabled.ID, false)
+
+	ok, failed, err := newTestFetcher(s).SelfCheck(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok != 1 || failed != 2 {
+		t.Fatalf("expected 1 ok and 2 failed, got %d and %d", ok, failed)
+	}
+	if n := skippedHits.Load(); n != 0 {
+		t.Fatalf("disabled feed was fetched %d times", n)
+	}
+	if n := len(s.ListArticles("", 0)); n != 0 {
+		t.Fatalf("self-check stored %d articles", n)
+	}
+}
+
+// stubSite serves an HTML home page with the given head, rssFixture at
+// feedPath, a page that is not a feed at /feed and 404 elsewhere. It records
+// the paths requested.
+func stubSite(t *testing.T, head, feedPath string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, "<html><head>"+head+"</head><body>Home</body></html>")
+		case feedPath:
+			w.Header().Set("Content-Type", "application/rss+xml")
+			io.WriteString(w, rssFixture)
+		case "/feed":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, "<html><body>Not a feed</body></html>")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &paths
+}
+
+func TestDiscoverProbesCommonPaths(t *testing.T) {
+	ts, paths := stubSite(t, "<title>Home</title>", "/index.xml")
+	f := newTestFetcher(store.New())
+
+	found, err := f.Discover(context.Background(), ts.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found.FeedURL != ts.URL+"/index.xml" || found.Via != models.DiscoveredProbe || found.ItemCount != 2 {
+		t.Fatalf("unexpected discovery: %+v", found)
+	}
+	if want := []string{"/", "/feed", "/rss", "/atom.xml", "/index.xml"}; !slices.Equal(*paths, want) {
+		t.Fatalf("expected requests %v, got %v", want, *paths)
+	}
+}
+
+func TestDiscoverPrefersDeclaredFeeds(t *testing.T) {
+	ts, paths := stubSite(t, `<link rel="alternate" type="application/rss+xml" href="/index.xml">`, "/index.xml")
+	f := newTestFetcher(store.New())
+
+	found, err := f.Discover(context.Background(), ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found.FeedURL != ts.URL+"/index.xml" || found.Via != models.DiscoveredLink {
+		t.Fatalf("unexpected discovery: %+v", found)
+	}
+	if len(*paths) != 2 {
+		t.Fatalf("expected no probing once a declared feed parses, got requests %v", *paths)
+	}
+
+	found, err = f.Discover(context.Background(), ts.URL+"/index.xml")
+	if err != nil || found.Via != models.DiscoveredDirect {
+		t.Fatalf("expected a feed URL to be returned as is, got %+v (err %v)", found, err)
+	}
+}
+
+func TestDiscoverPathsAreConfigurableAndBounded(t *testing.T) {
+	ts, paths := stubSite(t, "", "/index.xml")
+
+	custom := make([]string, MaxDiscoveryPaths+5)
+	for i := range custom {
+		custom[i] = fmt.Sprintf("/missing-%d", i)
+	}
+	f := newTestFetcher(store.New(), WithDiscoveryPaths(custom))
+
+	_, err := f.Discover(context.Background(), ts.URL)
+	if !errors.Is(err, ErrNoFeedFound) {
+		t.Fatalf("expected ErrNoFeedFound, got %v", err)
+	}
+	if len(*paths) != 1+MaxDiscoveryPaths {
+		t.Fatalf("expected the home page and %d probes, got %v", MaxDiscoveryPaths, *paths)
+	}
+}
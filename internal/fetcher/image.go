@@ -0,0 +1,101 @@
+package fetcher
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html"
+)
+
+// articleImage picks a thumbnail for an item: its media RSS thumbnail or
+// image (or iTunes image), else its first enclosure of an image type, else
+// the first <img> in content. Relative URLs are resolved against the
+// item's link. It returns "" when there is none.
+//
+// gofeed's Item.Image is not used as it already falls back to enclosures
+// and content, in its own order, and skips media thumbnails.
+func articleImage(item *gofeed.Item, content string) string {
+	src := mediaImage(item)
+	if src == "" {
+		src = firstImageEnclosure(item)
+	}
+	if src == "" {
+		src = firstImgSrc(content)
+	}
+	return resolveAgainst(item.Link, strings.TrimSpace(src))
+}
+
+// mediaImage returns an item's media:thumbnail, else its first media:content
+// that is an image, else its iTunes image.
+func mediaImage(item *gofeed.Item) string {
+	media := item.Extensions["media"]
+	for _, e := range media["thumbnail"] {
+		if u := strings.TrimSpace(e.Attrs["url"]); u != "" {
+			return u
+		}
+	}
+	for _, e := range media["content"] {
+		isImage := strings.HasPrefix(e.Attrs["type"], "image/") || e.Attrs["medium"] == "image"
+		if u := strings.TrimSpace(e.Attrs["url"]); isImage && u != "" {
+			return u
+		}
+	}
+	if item.ITunesExt != nil {
+		return strings.TrimSpace(item.ITunesExt.Image)
+	}
+	return ""
+}
+
+// firstImageEnclosure returns the URL of an item's first image enclosure.
+func firstImageEnclosure(item *gofeed.Item) string {
+	for _, e := range item.Enclosures {
+		if e != nil && strings.HasPrefix(strings.ToLower(e.Type), "image/") && strings.TrimSpace(e.URL) != "" {
+			return e.URL
+		}
+	}
+	return ""
+}
+
+// firstImgSrc returns the src of the first <img> with one in an HTML
+// fragment.
+func firstImgSrc(fragment string) string {
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if name, hasAttr := z.TagName(); string(name) != "img" || !hasAttr {
+				continue
+			}
+			for more := true; more; {
+				var key, val []byte
+				key, val, more = z.TagAttr()
+				if string(key) == "src" && strings.TrimSpace(string(val)) != "" {
+					return string(val)
+				}
+			}
+		}
+	}
+}
+
+// resolveAgainst resolves ref against base, if base parses, and returns
+// the result only if it is an http or https URL, so data: and javascript:
+// images are dropped.
+func resolveAgainst(base, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if b, err := url.Parse(base); err == nil {
+		r = b.ResolveReference(r)
+	}
+	if r.Scheme != "http" && r.Scheme != "https" {
+		return ""
+	}
+	return r.String()
+}
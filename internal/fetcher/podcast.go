@@ -0,0 +1,36 @@
+package fetcher
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+)
+
+// enclosures returns an item's media files in feed order, skipping those
+// without a URL. A length that is not a whole number of bytes is left as 0.
+func enclosures(item *gofeed.Item) []models.Enclosure {
+	var out []models.Enclosure
+	for _, e := range item.Enclosures {
+		if e == nil || strings.TrimSpace(e.URL) == "" {
+			continue
+		}
+		length, err := strconv.ParseInt(strings.TrimSpace(e.Length), 10, 64)
+		if err != nil || length < 0 {
+			length = 0
+		}
+		out = append(out, models.Enclosure{URL: strings.TrimSpace(e.URL), Type: e.Type, Length: length})
+	}
+	return out
+}
+
+// podcast returns an item's iTunes details, or nil if it has none.
+func podcast(item *gofeed.Item) *models.Podcast {
+	ext := item.ITunesExt
+	if ext == nil || (ext.Duration == "" && ext.Image == "" && ext.Episode == "") {
+		return nil
+	}
+	return &models.Podcast{Duration: ext.Duration, Image: ext.Image, Episode: ext.Episode}
+}
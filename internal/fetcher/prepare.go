@@ -0,0 +1,161 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html/charset"
+)
+
+// utf8BOM is the byte order mark some servers put before a document.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// parseBody parses a feed document. A leading byte order mark is always
+// dropped. In lenient mode other damage at the start of the document is
+// repaired first; in strict mode a document that is not well-formed XML or
+// JSON is rejected instead, even where gofeed would cope with it.
+func (f *Fetcher) parseBody(body []byte, strict bool) (*gofeed.Feed, error) {
+	body = bytes.TrimPrefix(body, utf8BOM)
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, ErrEmptyBody
+	}
+	if strict {
+		if err := checkWellFormed(body); err != nil {
+			return nil, err
+		}
+	} else {
+		body = repairStart(body)
+	}
+	return f.parser.Parse(bytes.NewReader(body))
+}
+
+// repairStart drops leading whitespace and, when the document does not then
+// begin with markup or a JSON object, anything before the first '<', such
+// as warnings a misconfigured server printed ahead of the feed.
+func repairStart(body []byte) []byte {
+	body = bytes.TrimLeft(body, " \t\r\n")
+	if len(body) == 0 || body[0] == '<' || body[0] == '{' {
+		return body
+	}
+	if i := bytes.IndexByte(body, '<'); i > 0 {
+		return body[i:]
+	}
+	return body
+}
+
+// checkWellFormed reports whether body is well-formed JSON, for a JSON
+// feed, or XML.
+func checkWellFormed(body []byte) error {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if !json.Valid(trimmed) {
+			return errors.New("strict: document is not valid JSON")
+		}
+		return nil
+	}
+	// encoding/xml tolerates this, but the XML spec does not.
+	if len(trimmed) < len(body) && bytes.HasPrefix(trimmed, []byte("<?xml")) {
+		return errors.New("strict: XML declaration is not at the start of the document")
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = charset.NewReaderLabel
+	for {
+		_, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("strict: document is not well-formed XML: %w", err)
+		}
+	}
+}
+
+// xmlEncodingDecl matches the encoding attribute of an XML declaration at
+// the start of a document.
+var xmlEncodingDecl = regexp.MustCompile(`^(\s*<\?xml[^>]*?\sencoding\s*=\s*)(?:"[^"]*"|'[^']*')`)
+
+// toUTF8 transcodes body to UTF-8 from the charset named in contentType.
+// The header takes precedence over the document's XML declaration, whose
+// encoding is rewritten to match so the parser does not convert it again.
+// Bodies without a charset in the header, or with one that is unknown, are
+// returned unchanged; gofeed still honours the XML declaration itself.
+func toUTF8(body []byte, contentType string) ([]byte, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["charset"] == "" {
+		return body, nil
+	}
+	enc, name := charset.Lookup(params["charset"])
+	if enc == nil {
+		return body, nil
+	}
+	if name != "utf-8" {
+		if body, err = enc.NewDecoder().Bytes(body); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", name, err)
+		}
+	}
+	return xmlEncodingDecl.ReplaceAll(body, []byte(`${1}"UTF-8"`)), nil
+}
+
+// gzipMagic starts every gzip stream; no XML or JSON document begins with it.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// readLimited reads all of r, failing with ErrFeedTooLarge as soon as there
+// is more than limit bytes of it.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrFeedTooLarge, limit)
+	}
+	return body, nil
+}
+
+// decompress undoes the codings listed in a Content-Encoding header, last
+// applied first, failing with ErrFeedTooLarge if any stage expands to more
+// than limit bytes. A body with no Content-Encoding that is nonetheless
+// gzipped, as served for .xml.gz files, is unzipped too.
+func decompress(body []byte, contentEncoding string, limit int64) ([]byte, error) {
+	codings := strings.Split(contentEncoding, ",")
+	if strings.TrimSpace(contentEncoding) == "" && bytes.HasPrefix(body, gzipMagic) {
+		codings = []string{"gzip"}
+	}
+
+	for i := len(codings) - 1; i >= 0; i-- {
+		var r io.Reader
+		var err error
+		switch coding := strings.ToLower(strings.TrimSpace(codings[i])); coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(body))
+		case "deflate":
+			// Meant to be zlib-wrapped, but some servers send raw deflate.
+			if r, err = zlib.NewReader(bytes.NewReader(body)); err != nil {
+				r, err = flate.NewReader(bytes.NewReader(body)), nil
+			}
+		default:
+			return nil, fmt.Errorf("unsupported Content-Encoding %q", coding)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decompress %s: %w", codings[i], err)
+		}
+		if body, err = readLimited(r, limit); err != nil {
+			return nil, fmt.Errorf("decompress %s: %w", codings[i], err)
+		}
+	}
+	return body, nil
+}
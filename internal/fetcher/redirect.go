@@ -0,0 +1,48 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// maxRedirects matches net/http's default redirect limit.
+const maxRedirects = 10
+
+// redirectTrace records the redirects followed by one feed request.
+type redirectTrace struct {
+	final     string // URL of the last hop
+	permanent bool   // every hop was a 301 or 308
+}
+
+type redirectTraceKey struct{}
+
+// withRedirectTrace returns a context whose requests record their redirects
+// into the returned trace.
+func withRedirectTrace(ctx context.Context) (context.Context, *redirectTrace) {
+	t := &redirectTrace{permanent: true}
+	return context.WithValue(ctx, redirectTraceKey{}, t), t
+}
+
+// movedTo returns the final URL if the feed was only ever permanently
+// redirected, or "" if there was no redirect or any hop was temporary.
+func (t *redirectTrace) movedTo() string {
+	if t.final == "" || !t.permanent {
+		return ""
+	}
+	return t.final
+}
+
+// checkRedirect is the client's redirect policy: follow up to maxRedirects
+// hops like net/http does, noting each one in the request's trace, if any.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("stopped after 10 redirects")
+	}
+	if t, ok := req.Context().Value(redirectTraceKey{}).(*redirectTrace); ok {
+		code := req.Response.StatusCode
+		t.permanent = t.permanent && (code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect)
+		t.final = req.URL.String()
+	}
+	return nil
+}
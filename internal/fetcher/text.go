@@ -0,0 +1,82 @@
+package fetcher
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// excerptLength is the maximum length, in runes, of an article excerpt.
+const excerptLength = 280
+
+// wordsPerMinute is the reading speed reading times are estimated at.
+const wordsPerMinute = 200
+
+// readingTime estimates, in whole seconds rounded up, how long words take
+// to read.
+func readingTime(words int) int {
+	return (words*60 + wordsPerMinute - 1) / wordsPerMinute
+}
+
+// stripHTML returns the visible text of an HTML fragment with entities
+// decoded and runs of whitespace collapsed to single spaces. Script and
+// style contents are dropped.
+func stripHTML(s string) string {
+	z := html.NewTokenizer(strings.NewReader(s))
+
+	var b strings.Builder
+	skip := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case html.StartTagToken:
+			if name, _ := z.TagName(); isHiddenTag(string(name)) {
+				skip++
+			}
+			b.WriteByte(' ')
+		case html.EndTagToken:
+			if name, _ := z.TagName(); isHiddenTag(string(name)) && skip > 0 {
+				skip--
+			}
+			b.WriteByte(' ')
+		case html.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+			}
+		}
+	}
+}
+
+func isHiddenTag(name string) bool {
+	return name == "script" || name == "style"
+}
+
+// truncateWords shortens s to at most max runes, cutting at the last word
+// boundary and appending an ellipsis. Strings already within max are
+// returned unchanged; max <= 0 means no limit.
+func truncateWords(s string, max int) string {
+	runes := []rune(s)
+	if max <= 0 || len(runes) <= max {
+		return s
+	}
+
+	// Reserve one rune for the ellipsis.
+	cut := runes[:max-1]
+	if i := lastSpace(cut); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRightFunc(string(cut), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + "…"
+}
+
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return i
+		}
+	}
+	return -1
+}
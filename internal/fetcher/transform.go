@@ -0,0 +1,119 @@
+package fetcher
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+)
+
+// Transformer rewrites an article on ingest. It returns false to drop the
+// article instead of saving it.
+type Transformer interface {
+	Transform(models.Article) (models.Article, bool)
+}
+
+// TitlePrefix prepends Prefix to every title. An empty Prefix uses the
+// feed name in brackets.
+type TitlePrefix struct {
+	Prefix string
+}
+
+func (t TitlePrefix) Transform(a models.Article) (models.Article, bool) {
+	prefix := t.Prefix
+	if prefix == "" {
+		prefix = "[" + a.FeedName + "] "
+	}
+	a.Title = prefix + a.Title
+	return a, true
+}
+
+// RegexDrop drops articles whose title or description matches Pattern.
+type RegexDrop struct {
+	Pattern *regexp.Regexp
+}
+
+func (t RegexDrop) Transform(a models.Article) (models.Article, bool) {
+	if t.Pattern.MatchString(a.Title) || t.Pattern.MatchString(a.Description) {
+		return a, false
+	}
+	return a, true
+}
+
+// KeywordFilter drops articles whose title or description contains any
+// Exclude term and, when Include is non-empty, those containing none of
+// the Include terms. Matching is a case-insensitive substring match.
+type KeywordFilter struct {
+	Include []string
+	Exclude []string
+}
+
+func (t KeywordFilter) Transform(a models.Article) (models.Article, bool) {
+	text := strings.ToLower(a.Title + "\n" + a.Description)
+	contains := func(terms []string) bool {
+		for _, term := range terms {
+			if term != "" && strings.Contains(text, strings.ToLower(term)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if contains(t.Exclude) {
+		return a, false
+	}
+	if len(t.Include) > 0 && !contains(t.Include) {
+		return a, false
+	}
+	return a, true
+}
+
+// NewTransformer builds the built-in Transformer described by f.
+func NewTransformer(f models.Filter) (Transformer, error) {
+	switch f.Type {
+	case models.FilterTitlePrefix:
+		return TitlePrefix{Prefix: f.Value}, nil
+	case models.FilterDropRegex:
+		re, err := regexp.Compile(f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("filter %s: %w", f.Type, err)
+		}
+		return RegexDrop{Pattern: re}, nil
+	default:
+		return nil, fmt.Errorf("unknown filter type %q", f.Type)
+	}
+}
+
+// transform runs every article through the feed's keyword rules and then
+// its filters in order, dropping those any of them rejects.
+func transform(articles []models.Article, feed models.Feed) ([]models.Article, error) {
+	var ts []Transformer
+	if len(feed.IncludeKeywords) > 0 || len(feed.ExcludeKeywords) > 0 {
+		ts = append(ts, KeywordFilter{Include: feed.IncludeKeywords, Exclude: feed.ExcludeKeywords})
+	}
+	for _, f := range feed.Filters {
+		t, err := NewTransformer(f)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	if len(ts) == 0 {
+		return articles, nil
+	}
+
+	kept := articles[:0]
+	for _, a := range articles {
+		keep := true
+		for _, t := range ts {
+			if a, keep = t.Transform(a); !keep {
+				break
+			}
+		}
+		if keep {
+			kept = append(kept, a)
+		}
+	}
+	return kept, nil
+}
@@ -4,32 +4,380 @@ import "time"
 
 // Feed represents an RSS/Atom feed source to be monitored.
 type Feed struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	URL         string    `json:"url"`
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	URL      string            `json:"url"`
+	Folder   string            `json:"folder,omitempty"`
+	Priority int               `json:"priority"`                  // higher is fetched sooner
+	Headers  map[string]string `json:"headers,omitempty" xml:"-"` // sent with every fetch; not in XML
+	Cookie   string            `json:"cookie,omitempty"`          // Cookie header for session-gated feeds
+	Filters  []Filter          `json:"filters,omitempty"`         // applied to items in order
+	Strict   bool              `json:"strict,omitempty"`          // reject malformed documents rather than repair them
+	Timezone string            `json:"timezone,omitempty"`        // IANA zone for item dates that carry none
+
+	// IDStrategy picks what article IDs are derived from; see the
+	// IDStrategy constants. Empty means IDStrategyGUID.
+	IDStrategy string `json:"id_strategy,omitempty"`
+
+	// KeepRaw keeps the body of the feed's last response for debugging;
+	// see RawResponse.
+	KeepRaw bool `json:"keep_raw,omitempty"`
+
+	// Keyword rules match titles and descriptions case-insensitively.
+	// Items matching an exclude term are skipped; when include terms are
+	// set, only items matching at least one are kept.
+	IncludeKeywords []string `json:"include_keywords,omitempty"`
+	ExcludeKeywords []string `json:"exclude_keywords,omitempty"`
+
+	// MaxArticles caps the articles kept for this feed, evicting the
+	// oldest; 0 falls back to the store-wide cap.
+	MaxArticles int `json:"max_articles,omitempty"`
+
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	Format      string    `json:"format,omitempty"` // rss, atom or json; set once fetched
 	LastFetched time.Time `json:"last_fetched"`
+	NextFetchAt time.Time `json:"next_fetch_at"` // Retry-After or stale-feed backoff
+
+	// Language and ImageURL are declared by the feed document; set once
+	// fetched.
+	Language string `json:"language,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+
+	// LastNewArticleAt is when a fetch last stored a new article; zero
+	// until one does.
+	LastNewArticleAt time.Time `json:"last_new_article_at"`
+
+	// HealthScore rates the feed from 0 (broken) to 1 (healthy) from its
+	// recent fetch results and staleness. It is computed when feeds are
+	// listed, not stored.
+	HealthScore float64 `json:"health_score"`
+}
+
+// Article ID strategies. Each falls back to the other's key when its own is
+// missing, and to the title and publish date when both are.
+const (
+	IDStrategyGUID = "guid" // the item's GUID, for feeds whose links change
+	IDStrategyLink = "link" // the item's link, for feeds whose GUIDs change
+)
+
+// Filter types understood by the fetcher.
+const (
+	FilterTitlePrefix = "title_prefix" // prepend Value (or "[feed name] ") to titles
+	FilterDropRegex   = "drop_regex"   // drop items whose title or description matches Value
+)
+
+// Filter configures one transformation applied to a feed's items on ingest.
+type Filter struct {
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+// FeedMeta holds feed-level details discovered while parsing a feed.
+type FeedMeta struct {
+	Format  string
+	MovedTo string // final URL after only permanent redirects, if tracked
+
+	Language string
+	ImageURL string
 }
 
 // Article represents a single item parsed from a feed.
 type Article struct {
 	ID          string    `json:"id"`
+	Seq         int64     `json:"seq"` // store-assigned, increases with each save
 	FeedID      string    `json:"feed_id"`
 	FeedName    string    `json:"feed_name"`
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
+	Excerpt     string    `json:"excerpt"` // short plain-text summary
 	Link        string    `json:"link"`
 	PublishedAt time.Time `json:"published_at"`
+	SavedAt     time.Time `json:"saved_at"` // zero unless queued to read later
+
+	GUID     string `json:"guid,omitempty"`      // the item's guid or Atom id, if any
+	ImageURL string `json:"image_url,omitempty"` // thumbnail for card views, if one was found
+
+	// WordCount and ReadingTimeSeconds are estimated from the plain text
+	// of the content on ingest; both are 0 when there is none.
+	WordCount          int `json:"word_count"`
+	ReadingTimeSeconds int `json:"reading_time_seconds"`
+
+	// Enclosures and Podcast carry a podcast episode's media files and
+	// iTunes details; both are left out for other items.
+	Enclosures []Enclosure `json:"enclosures,omitempty"`
+	Podcast    *Podcast    `json:"podcast,omitempty"`
+}
+
+// Enclosure is a media file attached to a feed item.
+type Enclosure struct {
+	URL    string `json:"url"`
+	Type   string `json:"type,omitempty"`
+	Length int64  `json:"length,omitempty"` // in bytes; 0 if the feed gives none
+}
+
+// Podcast holds the iTunes extension details of an episode, as written in
+// the feed.
+type Podcast struct {
+	Duration string `json:"duration,omitempty"` // seconds or [HH:]MM:SS
+	Image    string `json:"image,omitempty"`
+	Episode  string `json:"episode,omitempty"`
+}
+
+// ArticlePage wraps a page of articles with pagination metadata.
+type ArticlePage struct {
+	Data    []Article `json:"data"`
+	Total   int       `json:"total"`
+	Limit   int       `json:"limit"`
+	Offset  int       `json:"offset"`
+	HasMore bool      `json:"has_more"`
+}
+
+// FeedPage wraps a page of feeds with pagination metadata.
+type FeedPage struct {
+	Data    []Feed `json:"data"`
+	Total   int    `json:"total"`
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+	HasMore bool   `json:"has_more"`
 }
 
 // AddFeedRequest is the payload for registering a new feed.
 type AddFeedRequest struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
+	Name     string            `json:"name"`
+	URL      string            `json:"url"`
+	Folder   string            `json:"folder,omitempty"`
+	Priority int               `json:"priority,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Cookie   string            `json:"cookie,omitempty"`
+	Filters  []Filter          `json:"filters,omitempty"`
+	Strict   bool              `json:"strict,omitempty"`
+	Timezone string            `json:"timezone,omitempty"`
+
+	IDStrategy string `json:"id_strategy,omitempty"`
+	KeepRaw    bool   `json:"keep_raw,omitempty"`
+
+	IncludeKeywords []string `json:"include_keywords,omitempty"`
+	ExcludeKeywords []string `json:"exclude_keywords,omitempty"`
+
+	MaxArticles int `json:"max_articles,omitempty"`
+}
+
+// FolderCount is a folder name with the number of feeds filed under it.
+type FolderCount struct {
+	Name  string `json:"name"`
+	Feeds int    `json:"feeds"`
+}
+
+// BatchAddFeedsRequest is the payload for adding several feeds at once.
+type BatchAddFeedsRequest struct {
+	Feeds []AddFeedRequest `json:"feeds"`
+}
+
+// Reasons a batch entry can fail.
+const (
+	ReasonInvalid   = "invalid"
+	ReasonDuplicate = "duplicate"
+	ReasonFeedLimit = "feed_limit"
+)
+
+// BatchAddResult is the outcome of one entry of a batch add: either the
+// created Feed or an Error with a machine-readable Reason.
+type BatchAddResult struct {
+	URL    string `json:"url"`
+	Feed   *Feed  `json:"feed,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// BatchDeleteFeedsRequest is the payload for removing several feeds at once.
+type BatchDeleteFeedsRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// Outcomes of one entry of a batch delete.
+const (
+	ResultRemoved  = "removed"
+	ResultNotFound = "not_found"
+)
+
+// BatchDeleteResult is the outcome of one entry of a batch delete, with
+// the number of the feed's articles removed along with it.
+type BatchDeleteResult struct {
+	ID              string `json:"id"`
+	Result          string `json:"result"`
+	ArticlesRemoved int    `json:"articles_removed"`
+}
+
+// UpdateFeedRequest is the payload for editing a feed. Nil fields are left
+// unchanged.
+type UpdateFeedRequest struct {
+	Name     *string           `json:"name,omitempty"`
+	URL      *string           `json:"url,omitempty"`
+	Folder   *string           `json:"folder,omitempty"` // "" moves the feed to uncategorized
+	Priority *int              `json:"priority,omitempty"`
+	Cookie   *string           `json:"cookie,omitempty"` // "" removes the cookie
+	Headers  map[string]string `json:"headers,omitempty"`
+	Filters  []Filter          `json:"filters,omitempty"`
+	Strict   *bool             `json:"strict,omitempty"`
+	Timezone *string           `json:"timezone,omitempty"` // "" reads zoneless dates as UTC again
+
+	IDStrategy *string `json:"id_strategy,omitempty"`
+	KeepRaw    *bool   `json:"keep_raw,omitempty"` // false also drops the kept response
+
+	IncludeKeywords []string `json:"include_keywords,omitempty"`
+	ExcludeKeywords []string `json:"exclude_keywords,omitempty"`
+
+	MaxArticles *int `json:"max_articles,omitempty"` // 0 falls back to the store-wide cap
+}
+
+// MergeFeedsRequest is the payload for folding a duplicate feed into
+// another.
+type MergeFeedsRequest struct {
+	PrimaryID   string `json:"primary_id"`
+	DuplicateID string `json:"duplicate_id"`
+}
+
+// UpdateArticleRequest is the payload for re-assigning an article to
+// another feed.
+type UpdateArticleRequest struct {
+	FeedID string `json:"feed_id"`
+}
+
+// BlockRequest is the payload for adding a domain or URL to the block list.
+type BlockRequest struct {
+	Pattern string `json:"pattern"`
+}
+
+// ImportSummary reports the outcome of an OPML import.
+type ImportSummary struct {
+	Imported []Feed           `json:"imported"`
+	Skipped  []string         `json:"skipped"` // URLs already subscribed
+	Failed   []BatchAddResult `json:"failed"`  // invalid URLs and those over the feed limit
+}
+
+// ValidateFeedRequest is the payload for a dry-run feed validation.
+type ValidateFeedRequest struct {
+	URL string `json:"url"`
+}
+
+// FeedValidation describes the outcome of fetching a feed without storing it.
+type FeedValidation struct {
+	Valid     bool   `json:"valid"`
+	Title     string `json:"title,omitempty"`
+	FeedType  string `json:"feed_type,omitempty"`
+	ItemCount int    `json:"item_count"`
+	Error     string `json:"error,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// DiscoverFeedRequest is the payload for finding the feed of a site.
+type DiscoverFeedRequest struct {
+	URL string `json:"url"`
+}
+
+// How a discovered feed was found.
+const (
+	DiscoveredDirect = "direct" // the URL given is itself a feed
+	DiscoveredLink   = "link"   // declared by a <link rel="alternate"> on the page
+	DiscoveredProbe  = "probe"  // found at one of the common feed paths
+)
+
+// FeedDiscovery is a feed found for a site URL.
+type FeedDiscovery struct {
+	FeedURL   string `json:"feed_url"`
+	Title     string `json:"title,omitempty"`
+	FeedType  string `json:"feed_type,omitempty"`
+	ItemCount int    `json:"item_count"`
+	Via       string `json:"via"`
+}
+
+// RawResponse is the body of a feed's last successful HTTP response, as
+// sent apart from any Content-Encoding, kept for feeds with KeepRaw set.
+type RawResponse struct {
+	Body        []byte
+	ContentType string
+	FetchedAt   time.Time
+	Truncated   bool // Body was cut at the fetcher's size limit
+}
+
+// FetchEvent records a single fetch attempt in a feed's history.
+type FetchEvent struct {
+	Timestamp   time.Time `json:"timestamp"`
+	DurationMS  int64     `json:"duration_ms"`
+	NewArticles int       `json:"new_articles"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// CycleSummary describes the outcome of one complete fetch cycle.
+type CycleSummary struct {
+	StartedAt   time.Time `json:"started_at"`
+	Total       int       `json:"total"`
+	Succeeded   int       `json:"succeeded"`
+	Failed      int       `json:"failed"`
+	NewArticles int       `json:"new_articles"`
+	DurationMS  int64     `json:"duration_ms"`
+}
+
+// FetchStatus reports the progress of the fetch cycle in flight. When no
+// cycle is running only Running is set.
+type FetchStatus struct {
+	Running   bool       `json:"running"`
+	StartedAt *time.Time `json:"started_at"`
+	Total     int        `json:"total"`
+	Done      int        `json:"done"`
+	Pending   int        `json:"pending"`
+}
+
+// FetchIntervalRequest is the payload for changing the poll interval at
+// runtime. Interval is a Go duration string such as "10m".
+type FetchIntervalRequest struct {
+	Interval string `json:"interval"`
+}
+
+// PruneRequest is the payload for a one-off article cleanup. MaxAge is a Go
+// duration string such as "720h"; zero values skip that rule.
+type PruneRequest struct {
+	MaxPerFeed int    `json:"max_per_feed"`
+	MaxAge     string `json:"max_age"`
+}
+
+// Metrics is a dependency-free snapshot of the server's counters.
+type Metrics struct {
+	UptimeSeconds        int64      `json:"uptime_seconds"`
+	FetchCycles          int64      `json:"fetch_cycles"`
+	LastCycleAt          *time.Time `json:"last_cycle_at"` // null until a cycle completes
+	LastCycleNewArticles int        `json:"last_cycle_new_articles"`
+	SlowFetches          int64      `json:"slow_fetches"` // fetches over the slow-fetch threshold
+	Feeds                int        `json:"feeds"`
+	Articles             int        `json:"articles"`
+}
+
+// DedupStats counts the articles offered to the store since the server
+// started and why those not stored were skipped. Each seen article lands
+// in exactly one of the other counters.
+type DedupStats struct {
+	Seen           int64 `json:"articles_seen"`
+	Stored         int64 `json:"stored"`
+	DuplicateID    int64 `json:"duplicate_id"`    // already stored; IDs are derived from GUIDs or links
+	DuplicateTitle int64 `json:"duplicate_title"` // fuzzy title match, when enabled
+	Tombstoned     int64 `json:"tombstoned"`
+	IDCollisions   int64 `json:"id_collisions"` // same ID, different item
+}
+
+// BuildInfo identifies the running build.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
 }
 
 // FetchResult carries the outcome of a single feed fetch through a channel.
 type FetchResult struct {
 	FeedID   string
 	Articles []Article
+	Meta     FeedMeta
 	Err      error
+	Started  time.Time
+	Duration time.Duration
 }
@@ -0,0 +1,56 @@
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Subscription is a single feed entry found in an OPML document.
+type Subscription struct {
+	Name string
+	URL  string
+}
+
+type document struct {
+	Body struct {
+		Outlines []outline `xml:"outline"`
+	} `xml:"body"`
+}
+
+type outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr"`
+	XMLURL   string    `xml:"xmlUrl,attr"`
+	Outlines []outline `xml:"outline"`
+}
+
+// Parse reads an OPML document and returns every outline that carries an
+// xmlUrl, flattening any folder nesting.
+func Parse(r io.Reader) ([]Subscription, error) {
+	var doc document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode opml: %w", err)
+	}
+
+	var subs []Subscription
+	var walk func([]outline)
+	walk = func(outlines []outline) {
+		for _, o := range outlines {
+			if o.XMLURL != "" {
+				name := o.Title
+				if name == "" {
+					name = o.Text
+				}
+				if name == "" {
+					name = o.XMLURL
+				}
+				subs = append(subs, Subscription{Name: name, URL: o.XMLURL})
+			}
+			walk(o.Outlines)
+		}
+	}
+	walk(doc.Body.Outlines)
+
+	return subs, nil
+}
@@ -0,0 +1,38 @@
+package opml_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/opml"
+)
+
+func TestParseFlattensFolders(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<opml version="2.0">
+  <body>
+    <outline text="Go Blog" xmlUrl="https://go.dev/blog/feed.atom"/>
+    <outline text="News">
+      <outline text="HN" title="Hacker News" xmlUrl="https://hnrss.org/frontpage"/>
+    </outline>
+  </body>
+</opml>`
+
+	subs, err := opml.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(subs) != 2 {
+		t.Fatalf("expected 2 subscriptions, got %d", len(subs))
+	}
+	if subs[1].Name != "Hacker News" || subs[1].URL != "https://hnrss.org/frontpage" {
+		t.Fatalf("unexpected nested subscription: %+v", subs[1])
+	}
+}
+
+func TestParseRejectsMalformed(t *testing.T) {
+	if _, err := opml.Parse(strings.NewReader("<opml><body>")); err == nil {
+		t.Fatal("expected error for malformed OPML")
+	}
+}
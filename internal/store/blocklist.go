@@ -0,0 +1,97 @@
+package store
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// normalizeBlockPattern reduces a domain or URL to the lowercased host it
+// blocks. It returns "" for input with no usable host.
+func normalizeBlockPattern(raw string) string {
+	p := strings.ToLower(strings.TrimSpace(raw))
+	if strings.Contains(p, "://") {
+		u, err := url.Parse(p)
+		if err != nil {
+			return ""
+		}
+		p = u.Hostname()
+	}
+	p = strings.TrimPrefix(p, "*.")
+	p = strings.Trim(p, ".")
+	if i := strings.IndexAny(p, "/:"); i >= 0 {
+		p = p[:i]
+	}
+	return p
+}
+
+// hostMatches reports whether host is pattern or a subdomain of it.
+func hostMatches(host, pattern string) bool {
+	return host == pattern || strings.HasSuffix(host, "."+pattern)
+}
+
+// BlockDomain adds a domain or URL to the block list and returns the host
+// pattern stored. ok is false if the input has no usable host.
+func (s *Store) BlockDomain(raw string) (pattern string, ok bool) {
+	pattern = normalizeBlockPattern(raw)
+	if pattern == "" {
+		return "", false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blocklist[pattern] = struct{}{}
+	return pattern, true
+}
+
+// UnblockDomain removes a pattern from the block list, reporting whether it
+// was present.
+func (s *Store) UnblockDomain(raw string) bool {
+	pattern := normalizeBlockPattern(raw)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.blocklist[pattern]; !ok {
+		return false
+	}
+	delete(s.blocklist, pattern)
+	return true
+}
+
+// Blocklist returns the blocked host patterns in sorted order.
+func (s *Store) Blocklist() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]string, 0, len(s.blocklist))
+	for p := range s.blocklist {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// IsBlocked reports whether link's host matches any blocked pattern by
+// suffix, so blocking example.com also blocks news.example.com.
+func (s *Store) IsBlocked(link string) bool {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for p := range s.blocklist {
+		if hostMatches(host, p) {
+			return true
+		}
+	}
+	return false
+}
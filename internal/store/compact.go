@@ -0,0 +1,46 @@
+package store
+
+// CompactIndexes drops entries of the title dedup, per-feed cap and merged
+// article indexes whose article has since been deleted, cleared or removed
+// with its feed. The indexes otherwise only prune an entry when its key is
+// next used, so keys that never come up again would be kept forever. It
+// returns the number of entries removed.
+func (s *Store) CompactIndexes() int {
+	removed := 0
+
+	s.titleMu.Lock()
+	for key, ids := range s.titles {
+		live := s.liveIDs(ids)
+		removed += len(ids) - len(live)
+		if len(live) == 0 {
+			delete(s.titles, key)
+		} else {
+			s.titles[key] = live
+		}
+	}
+	s.titleMu.Unlock()
+
+	s.capMu.Lock()
+	for feedID, ids := range s.byFeed {
+		// An empty index of a capped feed is kept: it is complete, and
+		// dropping it would only force a rescan on the next save.
+		live := s.liveIDs(ids)
+		removed += len(ids) - len(live)
+		s.byFeed[feedID] = live
+	}
+	s.capMu.Unlock()
+
+	removed += s.compactMerged()
+	return removed
+}
+
+// liveIDs filters ids in place down to the articles still stored.
+func (s *Store) liveIDs(ids []string) []string {
+	live := ids[:0]
+	for _, id := range ids {
+		if _, ok := s.article(id); ok {
+			live = append(live, id)
+		}
+	}
+	return live
+}
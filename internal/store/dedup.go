@@ -0,0 +1,126 @@
+package store
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+)
+
+// WithTitleDedup turns on fuzzy duplicate detection: SaveArticles skips an
+// incoming article when the same feed already has one with the same
+// normalized title published within window of it. This catches items whose
+// link changed (tracking parameters, session IDs) but whose content did not.
+// A window of 0 leaves it off.
+func WithTitleDedup(window time.Duration) Option {
+	return func(s *Store) {
+		if window > 0 {
+			s.dedupWindow = window
+			s.titles = make(map[string][]string)
+		}
+	}
+}
+
+// normalizeTitle lowercases a title and collapses runs of whitespace.
+func normalizeTitle(title string) string {
+	return strings.Join(strings.Fields(strings.ToLower(title)), " ")
+}
+
+// titleKey indexes an article by feed and normalized title. It returns ""
+// for untitled articles, which are never fuzzily deduplicated.
+func titleKey(a models.Article) string {
+	t := normalizeTitle(a.Title)
+	if t == "" {
+		return ""
+	}
+	return a.FeedID + "\x00" + t
+}
+
+// withinWindow reports whether a and b were published within window of
+// each other.
+func withinWindow(a, b time.Time, window time.Duration) bool {
+	d := a.Sub(b)
+	return d > -window && d < window
+}
+
+// dropNearDuplicates removes articles that fuzzily match a stored article
+// or an earlier one in the same batch. Callers must hold titleMu. Index
+// entries whose article is gone are pruned as they are found.
+func (s *Store) dropNearDuplicates(articles []models.Article) []models.Article {
+	kept := make([]models.Article, 0, len(articles))
+	pending := make(map[string][]time.Time) // kept so far in this batch
+
+	for _, a := range articles {
+		key := titleKey(a)
+		if key == "" {
+			kept = append(kept, a)
+			continue
+		}
+
+		dup := false
+		for _, t := range pending[key] {
+			if withinWindow(a.PublishedAt, t, s.dedupWindow) {
+				dup = true
+			}
+		}
+
+		live := s.titles[key][:0]
+		for _, id := range s.titles[key] {
+			existing, ok := s.article(id)
+			if !ok {
+				continue
+			}
+			live = append(live, id)
+			if existing.ID != a.ID && withinWindow(a.PublishedAt, existing.PublishedAt, s.dedupWindow) {
+				dup = true
+			}
+		}
+		s.titles[key] = live
+
+		if !dup {
+			kept = append(kept, a)
+			pending[key] = append(pending[key], a.PublishedAt)
+		}
+	}
+	return kept
+}
+
+// indexTitles records newly stored articles for later fuzzy matching.
+// Callers must hold titleMu.
+func (s *Store) indexTitles(articles []models.Article) {
+	for _, a := range articles {
+		if key := titleKey(a); key != "" {
+			s.titles[key] = append(s.titles[key], a.ID)
+		}
+	}
+}
+
+// article looks up a stored article by ID.
+func (s *Store) article(id string) (models.Article, bool) {
+	sh := s.shards[s.shardIndex(id)]
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	a, ok := sh.articles[id]
+	return a, ok
+}
+
+// dedupCounters tally what SaveArticles did with each article it was given.
+type dedupCounters struct {
+	seen, stored, duplicateID, duplicateTitle, tombstoned, idCollisions atomic.Int64
+}
+
+// DedupStats reports how many articles SaveArticles has been given since
+// the store was created and why those it did not store were skipped.
+// Articles evicted by a feed cap count as stored.
+func (s *Store) DedupStats() models.DedupStats {
+	return models.DedupStats{
+		Seen:           s.dedup.seen.Load(),
+		Stored:         s.dedup.stored.Load(),
+		DuplicateID:    s.dedup.duplicateID.Load(),
+		DuplicateTitle: s.dedup.duplicateTitle.Load(),
+		Tombstoned:     s.dedup.tombstoned.Load(),
+		IDCollisions:   s.dedup.idCollisions.Load(),
+	}
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"slices"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+)
+
+// WithMaxArticlesPerFeed caps how many articles each feed keeps. When a
+// save pushes a feed past the cap its oldest articles are evicted in the
+// same call. A feed's own MaxArticles overrides n; 0 means no cap.
+func WithMaxArticlesPerFeed(n int) Option {
+	return func(s *Store) {
+		if n > 0 {
+			s.maxPerFeed = n
+		}
+	}
+}
+
+// articleCaps returns the cap of each feed with articles in the batch,
+// leaving out feeds without one.
+func (s *Store) articleCaps(articles []models.Article) map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	caps := make(map[string]int)
+	for _, a := range articles {
+		if _, seen := caps[a.FeedID]; seen {
+			continue
+		}
+		limit := s.maxPerFeed
+		if f, ok := s.feeds[a.FeedID]; ok && f.MaxArticles > 0 {
+			limit = f.MaxArticles
+		}
+		caps[a.FeedID] = limit
+	}
+	for feedID, limit := range caps {
+		if limit == 0 {
+			delete(caps, feedID)
+		}
+	}
+	return caps
+}
+
+// indexFeeds records newly stored articles of capped feeds. Only capped
+// feeds are indexed: an uncapped feed's index is dropped when it gains
+// articles, and rebuilt by a scan once it has a cap again. Callers must
+// hold capMu.
+func (s *Store) indexFeeds(inserted []models.Article, caps map[string]int) {
+	for _, a := range inserted {
+		if _, capped := caps[a.FeedID]; !capped {
+			delete(s.byFeed, a.FeedID)
+		} else if ids, ok := s.byFeed[a.FeedID]; ok {
+			s.byFeed[a.FeedID] = append(ids, a.ID)
+		}
+	}
+	for feedID := range caps {
+		if _, ok := s.byFeed[feedID]; !ok {
+			s.byFeed[feedID] = s.scanFeed(feedID)
+		}
+	}
+}
+
+// scanFeed returns the IDs of every stored article of a feed.
+func (s *Store) scanFeed(feedID string) []string {
+	var ids []string
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for id, a := range sh.articles {
+			if a.FeedID == feedID {
+				ids = append(ids, id)
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return ids
+}
+
+// evictOverCap deletes the oldest articles of each feed beyond its cap and
+// returns the evicted IDs. Articles queued to read later are neither
+// evicted nor counted. Callers must hold capMu; index entries whose
+// article is gone are pruned as they are found, as are repeated entries,
+// left when a rescan picks up an article a concurrent save then indexes.
+func (s *Store) evictOverCap(caps map[string]int) map[string]bool {
+	evicted := make(map[string]bool)
+	for feedID, limit := range caps {
+		if len(s.byFeed[feedID]) <= limit {
+			continue
+		}
+
+		var live, saved []string
+		var candidates []models.Article
+		seen := make(map[string]bool, len(s.byFeed[feedID]))
+		for _, id := range s.byFeed[feedID] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			a, ok := s.article(id)
+			switch {
+			case !ok:
+			case !a.SavedAt.IsZero():
+				saved = append(saved, id)
+			default:
+				candidates = append(candidates, a)
+			}
+		}
+
+		slices.SortFunc(candidates, func(a, b models.Article) int {
+			return compareArticles(a, b, SortPublishedDesc)
+		})
+		for i, a := range candidates {
+			if i < limit {
+				live = append(live, a.ID)
+			} else if s.DeleteArticle(a.ID) {
+				evicted[a.ID] = true
+			}
+		}
+		s.byFeed[feedID] = append(live, saved...)
+	}
+	return evicted
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"time"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+)
+
+// Staleness only starts to cost a feed health once its newest article is
+// older than healthStaleAfter, and costs the most, half its score, from
+// healthStaleMax on.
+const (
+	healthStaleAfter = 7 * 24 * time.Hour
+	healthStaleMax   = 30 * 24 * time.Hour
+)
+
+// healthScore rates a feed from 0 (broken) to 1 (healthy) by multiplying
+// three factors: the success rate of its recorded fetches, a penalty of
+// 1/(1+n) for n consecutive failures at the head of its history, and its
+// staleness. A feed that has never been fetched scores 1. events must be
+// newest first.
+func healthScore(f models.Feed, events []models.FetchEvent, now time.Time) float64 {
+	if len(events) == 0 {
+		return 1
+	}
+
+	ok, streak, inStreak := 0, 0, true
+	for _, ev := range events {
+		if ev.Error == "" {
+			ok++
+			inStreak = false
+		} else if inStreak {
+			streak++
+		}
+	}
+	score := float64(ok) / float64(len(events)) / float64(1+streak)
+
+	if !f.LastNewArticleAt.IsZero() {
+		if age := now.Sub(f.LastNewArticleAt); age > healthStaleAfter {
+			over := min(float64(age-healthStaleAfter)/float64(healthStaleMax-healthStaleAfter), 1)
+			score *= 1 - over/2
+		}
+	}
+	return score
+}
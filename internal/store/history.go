@@ -0,0 +1,103 @@
+package store
+
+import "github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+
+// DefaultHistorySize is how many fetch events are kept per feed when no
+// WithHistorySize option is given.
+const DefaultHistorySize = 50
+
+// ring is a fixed-capacity buffer of fetch events; once full, each new
+// event overwrites the oldest so memory stays flat.
+type ring struct {
+	events []models.FetchEvent
+	next   int
+}
+
+func newRing(size int) *ring {
+	return &ring{events: make([]models.FetchEvent, 0, size)}
+}
+
+func (r *ring) push(ev models.FetchEvent) {
+	if len(r.events) < cap(r.events) {
+		r.events = append(r.events, ev)
+		return
+	}
+	r.events[r.next] = ev
+	r.next = (r.next + 1) % cap(r.events)
+}
+
+// newestFirst returns a copy of the buffered events, most recent first.
+func (r *ring) newestFirst() []models.FetchEvent {
+	n := len(r.events)
+	out := make([]models.FetchEvent, n)
+	for i := 0; i < n; i++ {
+		// The newest event sits just before next.
+		out[i] = r.events[(r.next-1-i+2*n)%n]
+	}
+	return out
+}
+
+// WithHistorySize sets how many fetch events are retained per feed.
+// Values below 1 are ignored.
+func WithHistorySize(n int) Option {
+	return func(s *Store) {
+		if n > 0 {
+			s.historySize = n
+		}
+	}
+}
+
+// RecordFetch appends a fetch attempt to a feed's history. Events for
+// unknown feeds are dropped.
+func (s *Store) RecordFetch(feedID string, ev models.FetchEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.feeds[feedID]; !ok {
+		return
+	}
+
+	h, ok := s.history[feedID]
+	if !ok {
+		h = newRing(s.historySize)
+		s.history[feedID] = h
+	}
+	h.push(ev)
+}
+
+// FetchHistory returns a feed's recent fetch attempts, newest first, and
+// false if the feed does not exist.
+func (s *Store) FetchHistory(feedID string) ([]models.FetchEvent, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.feeds[feedID]; !ok {
+		return nil, false
+	}
+
+	h, ok := s.history[feedID]
+	if !ok {
+		return []models.FetchEvent{}, true
+	}
+	return h.newestFirst(), true
+}
+
+// SetLastCycle records the summary of the most recent fetch cycle.
+func (s *Store) SetLastCycle(summary models.CycleSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastCycle = &summary
+}
+
+// LastCycle returns the most recent fetch cycle summary, or false if no
+// cycle has completed yet.
+func (s *Store) LastCycle() (models.CycleSummary, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.lastCycle == nil {
+		return models.CycleSummary{}, false
+	}
+	return *s.lastCycle, true
+}
@@ -0,0 +1,97 @@
+package store
+
+import "github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+
+// Articles folded in by MergeFeeds keep IDs hashed from the duplicate's
+// feed, while the primary's fetches hash the same items from its own, so
+// SaveArticles would store them a second time. The merged index remembers
+// the link and GUID of every moved article under its new feed so that
+// those items are recognized as duplicates instead.
+
+// mergedKeys returns the keys a moved article is indexed by.
+func mergedKeys(a models.Article) []string {
+	var keys []string
+	if a.Link != "" {
+		keys = append(keys, "link\x00"+a.Link)
+	}
+	if a.GUID != "" {
+		keys = append(keys, "guid\x00"+a.GUID)
+	}
+	return keys
+}
+
+// indexMerged records articles moved into feedID. Callers must hold
+// mergedMu.
+func (s *Store) indexMerged(feedID string, moved []models.Article) {
+	if len(moved) == 0 {
+		return
+	}
+	keys := s.merged[feedID]
+	if keys == nil {
+		keys = make(map[string]string)
+		s.merged[feedID] = keys
+	}
+	for _, a := range moved {
+		for _, k := range mergedKeys(a) {
+			keys[k] = a.ID
+		}
+	}
+	s.hasMerged.Store(true)
+}
+
+// dropMerged removes articles that a merge already moved into their feed
+// under another ID, and returns how many it removed. Index entries whose
+// article is gone or has left the feed are pruned as they are found.
+func (s *Store) dropMerged(articles []models.Article) ([]models.Article, int) {
+	if !s.hasMerged.Load() {
+		return articles, 0
+	}
+	s.mergedMu.Lock()
+	defer s.mergedMu.Unlock()
+
+	kept := make([]models.Article, 0, len(articles))
+	for _, a := range articles {
+		if !s.isMerged(a) {
+			kept = append(kept, a)
+		}
+	}
+	return kept, len(articles) - len(kept)
+}
+
+// isMerged reports whether a is an item moved into its feed by a merge
+// under a different ID. Callers must hold mergedMu.
+func (s *Store) isMerged(a models.Article) bool {
+	keys := s.merged[a.FeedID]
+	for _, k := range mergedKeys(a) {
+		id, ok := keys[k]
+		if !ok {
+			continue
+		}
+		if existing, live := s.article(id); live && existing.FeedID == a.FeedID {
+			return id != a.ID
+		}
+		delete(keys, k)
+	}
+	return false
+}
+
+// compactMerged drops merged index entries whose article is gone or has
+// left the feed, and returns how many it removed.
+func (s *Store) compactMerged() int {
+	s.mergedMu.Lock()
+	defer s.mergedMu.Unlock()
+
+	removed := 0
+	for feedID, keys := range s.merged {
+		for k, id := range keys {
+			if a, ok := s.article(id); !ok || a.FeedID != feedID {
+				delete(keys, k)
+				removed++
+			}
+		}
+		if len(keys) == 0 {
+			delete(s.merged, feedID)
+		}
+	}
+	return removed
+}
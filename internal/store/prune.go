@@ -0,0 +1,99 @@
+package store
+
+import (
+	"slices"
+	"time"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+)
+
+// DefaultPruneBatchSize is how many articles PruneArticles deletes per
+// lock acquisition when no WithPruneBatchSize option is given.
+const DefaultPruneBatchSize = 256
+
+// WithPruneBatchSize sets how many articles PruneArticles deletes before
+// releasing its locks to let saves through. Values below 1 are ignored.
+func WithPruneBatchSize(n int) Option {
+	return func(s *Store) {
+		if n > 0 {
+			s.pruneBatch = n
+		}
+	}
+}
+
+// PruneArticles deletes articles published more than maxAge before now and
+// those beyond the newest maxPerFeed of each feed, and returns how many it
+// removed. A zero limit skips that rule. As with feed caps, articles
+// queued to read later are neither removed nor counted, and articles
+// without a publish date are never too old.
+//
+// The articles to remove are chosen from a snapshot taken under read
+// locks, then deleted in batches of WithPruneBatchSize, so a long sweep
+// only ever holds the write locks for one batch at a time. Articles
+// queued to read later after the snapshot are still kept.
+func (s *Store) PruneArticles(maxPerFeed int, maxAge time.Duration, now time.Time) int {
+	ids := s.pruneCandidates(maxPerFeed, maxAge, now)
+
+	removed := 0
+	for batch := range slices.Chunk(ids, s.pruneBatch) {
+		removed += s.deleteUnsaved(batch)
+	}
+	return removed
+}
+
+// pruneCandidates returns the IDs PruneArticles would remove from the
+// articles stored right now.
+func (s *Store) pruneCandidates(maxPerFeed int, maxAge time.Duration, now time.Time) []string {
+	byFeed := make(map[string][]models.Article)
+	for a := range s.Articles() {
+		if a.SavedAt.IsZero() {
+			byFeed[a.FeedID] = append(byFeed[a.FeedID], a)
+		}
+	}
+
+	var ids []string
+	for _, articles := range byFeed {
+		slices.SortFunc(articles, func(a, b models.Article) int {
+			return compareArticles(a, b, SortPublishedDesc)
+		})
+		for i, a := range articles {
+			overCap := maxPerFeed > 0 && i >= maxPerFeed
+			tooOld := maxAge > 0 && !a.PublishedAt.IsZero() && now.Sub(a.PublishedAt) > maxAge
+			if overCap || tooOld {
+				ids = append(ids, a.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// deleteUnsaved deletes the articles of ids that are still stored and not
+// queued to read later, and returns how many it removed. It holds capMu
+// and each shard lock it needs for the whole batch.
+func (s *Store) deleteUnsaved(ids []string) int {
+	s.capMu.Lock()
+	defer s.capMu.Unlock()
+
+	buckets := make(map[int][]string)
+	for _, id := range ids {
+		i := s.shardIndex(id)
+		buckets[i] = append(buckets[i], id)
+	}
+
+	removed := 0
+	for i, batch := range buckets {
+		sh := s.shards[i]
+		sh.mu.Lock()
+		for _, id := range batch {
+			if a, ok := sh.articles[id]; ok && a.SavedAt.IsZero() {
+				delete(sh.articles, id)
+				removed++
+			}
+		}
+		sh.mu.Unlock()
+	}
+	if removed > 0 {
+		s.generation.Add(1)
+	}
+	return removed
+}
@@ -0,0 +1,24 @@
+package store
+
+import "github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+
+// SetRawResponse keeps raw as a feed's last response, replacing the one
+// before. It is dropped unless the feed exists and has KeepRaw set.
+func (s *Store) SetRawResponse(feedID string, raw models.RawResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.feeds[feedID]; ok && f.KeepRaw {
+		s.raw[feedID] = raw
+	}
+}
+
+// RawResponse returns the last response kept for a feed, and false if none
+// was.
+func (s *Store) RawResponse(feedID string) (models.RawResponse, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	raw, ok := s.raw[feedID]
+	return raw, ok
+}
@@ -0,0 +1,43 @@
+package store
+
+// Seqs are handed out one article at a time inside each shard's lock, so a
+// save that spans several shards can publish a later Seq before an earlier
+// one is stored. A reader paging by Seq would then move past the earlier
+// one and never see it. Queries therefore return only articles at or below
+// the committed Seq: the highest one below which no save is still running.
+
+// beginSeqs registers a save that is about to assign Seqs and returns its
+// floor, the last Seq assigned before it started. Every Seq the save
+// assigns is above its floor. The save must call endSeqs with the floor
+// once its articles are stored.
+func (s *Store) beginSeqs() int64 {
+	s.seqMu.Lock()
+	defer s.seqMu.Unlock()
+
+	floor := s.seq.Load()
+	s.pendingSeqs[floor]++
+	return floor
+}
+
+// endSeqs unregisters a save started with beginSeqs.
+func (s *Store) endSeqs(floor int64) {
+	s.seqMu.Lock()
+	defer s.seqMu.Unlock()
+
+	if s.pendingSeqs[floor]--; s.pendingSeqs[floor] <= 0 {
+		delete(s.pendingSeqs, floor)
+	}
+}
+
+// committedSeq returns the highest Seq such that every article given it or
+// a lower one is stored.
+func (s *Store) committedSeq() int64 {
+	s.seqMu.Lock()
+	defer s.seqMu.Unlock()
+
+	committed := s.seq.Load()
+	for floor := range s.pendingSeqs {
+		committed = min(committed, floor)
+	}
+	return committed
+}
@@ -1,78 +1,603 @@
 package store
 
 import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"iter"
+	"log/slog"
+	"maps"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
 )
 
+// Errors returned by feed mutations.
+var (
+	ErrDuplicateFeed = errors.New("feed already subscribed")
+	ErrFeedNotFound  = errors.New("feed not found")
+	ErrNotReady      = errors.New("store not initialized")
+	ErrFeedLimit     = errors.New("feed limit reached")
+)
+
+// DefaultShards is the number of article buckets used when no WithShards
+// option is given.
+const DefaultShards = 16
+
 // Store provides thread-safe, in-memory storage for feeds and articles.
 // All public methods are safe for concurrent use.
+//
+// Articles are spread across shards keyed by a hash of their ID, each with
+// its own lock, so concurrent saves from different feeds rarely contend.
+// When both are needed, mu is always acquired before any shard lock, and
+// titleMu before capMu.
 type Store struct {
-	mu       sync.RWMutex
-	feeds    map[string]models.Feed
-	articles map[string]models.Article // keyed by article ID
+	logger *slog.Logger
+
+	mu          sync.RWMutex // guards feeds, history, raw, lastCycle, blocklist and lastCreated
+	feeds       map[string]models.Feed
+	history     map[string]*ring              // fetch events keyed by feed ID
+	raw         map[string]models.RawResponse // last responses of KeepRaw feeds
+	historySize int
+	lastCycle   *models.CycleSummary
+	blocklist   map[string]struct{} // blocked host patterns
+	lastCreated time.Time           // CreatedAt of the newest feed
+
+	maxFeeds int // CreateFeed refuses feeds beyond this; 0 means unlimited
+
+	// Fuzzy title dedup, off unless dedupWindow > 0. titleMu guards titles
+	// and is acquired before any shard lock.
+	dedupWindow time.Duration
+	titleMu     sync.Mutex
+	titles      map[string][]string // article IDs keyed by titleKey
+
+	// Per-feed article caps. capMu guards byFeed, is never held while
+	// acquiring mu, and is acquired before any shard lock. capEpoch is
+	// bumped whenever a feed's cap changes; see SaveNewArticles.
+	maxPerFeed int
+	capMu      sync.Mutex
+	byFeed     map[string][]string // article IDs keyed by feed ID
+	capEpoch   atomic.Uint64
+
+	// Links and GUIDs of articles moved by MergeFeeds, keyed by their new
+	// feed ID; see merged.go. mergedMu guards merged, is acquired after
+	// capMu and before any shard lock, and hasMerged skips it while empty.
+	mergedMu  sync.Mutex
+	merged    map[string]map[string]string
+	hasMerged atomic.Bool
+
+	pruneBatch int // articles PruneArticles deletes per lock acquisition
+
+	// updateExisting makes SaveArticles refresh the content of articles
+	// it already has; see WithArticleUpdates.
+	updateExisting bool
+
+	dedup dedupCounters
+
+	seq    atomic.Int64 // last Seq assigned to a saved article
+	shards []*shard
+
+	// seqMu guards pendingSeqs, the floors of the saves still assigning
+	// Seqs, counted by floor; see committedSeq.
+	seqMu       sync.Mutex
+	pendingSeqs map[int64]int
+
+	// generation is bumped after every change that can alter the result
+	// of an article query; see Generation.
+	generation atomic.Uint64
+}
+
+// shard is one bucket of the article map.
+type shard struct {
+	mu         sync.RWMutex
+	articles   map[string]models.Article // keyed by article ID
+	tombstones map[string]struct{}       // deleted IDs SaveArticles must not re-add
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithShards sets the number of article buckets. Values below 1 are ignored.
+func WithShards(n int) Option {
+	return func(s *Store) {
+		if n > 0 {
+			s.shards = newShards(n)
+		}
+	}
+}
+
+// WithLogger sets where the store reports anomalies such as ID collisions.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Store) {
+		s.logger = logger
+	}
+}
+
+// WithArticleUpdates makes SaveArticles refresh the title, description and
+// excerpt of an article it already has when a re-fetch brings a corrected
+// version, instead of skipping it. The article keeps its Seq and its place
+// in the read-later queue.
+func WithArticleUpdates(on bool) Option {
+	return func(s *Store) {
+		s.updateExisting = on
+	}
+}
+
+// WithMaxFeeds caps how many feeds CreateFeed will hold. Feeds added with
+// AddFeed, which seeds the defaults, are counted but never refused. n <= 0
+// means unlimited.
+func WithMaxFeeds(n int) Option {
+	return func(s *Store) {
+		s.maxFeeds = max(n, 0)
+	}
 }
 
 // New creates an empty Store ready for use.
-func New() *Store {
-	return &Store{
-		feeds:    make(map[string]models.Feed),
-		articles: make(map[string]models.Article),
+func New(opts ...Option) *Store {
+	s := &Store{
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		feeds:       make(map[string]models.Feed),
+		history:     make(map[string]*ring),
+		raw:         make(map[string]models.RawResponse),
+		blocklist:   make(map[string]struct{}),
+		byFeed:      make(map[string][]string),
+		merged:      make(map[string]map[string]string),
+		historySize: DefaultHistorySize,
+		shards:      newShards(DefaultShards),
+		pruneBatch:  DefaultPruneBatchSize,
+		pendingSeqs: make(map[int64]int),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
+}
+
+// Ready reports whether the store can serve requests. Only a Store built
+// by New is ready; the zero value is not.
+func (s *Store) Ready() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.feeds == nil || len(s.shards) == 0 {
+		return ErrNotReady
+	}
+	return nil
+}
+
+func newShards(n int) []*shard {
+	shards := make([]*shard, n)
+	for i := range shards {
+		shards[i] = &shard{
+			articles:   make(map[string]models.Article),
+			tombstones: make(map[string]struct{}),
+		}
+	}
+	return shards
+}
+
+// shardIndex returns the bucket an article ID belongs to.
+func (s *Store) shardIndex(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(len(s.shards)))
 }
 
 // ---------- Feeds ----------
 
-// AddFeed registers a new feed and returns its generated ID.
+// AddFeed registers a new feed and returns it. Adding a URL that is
+// already subscribed returns the existing feed unchanged, so seeding is
+// idempotent.
 func (s *Store) AddFeed(name, url string) models.Feed {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	id := fmt.Sprintf("feed_%d", time.Now().UnixNano())
-	feed := models.Feed{
-		ID:   id,
-		Name: name,
-		URL:  url,
+	if f, ok := s.feedByURL(url, ""); ok {
+		return f
+	}
+	return s.insertFeed(models.Feed{Name: name, URL: url})
+}
+
+// CreateFeed registers feed with a generated ID unless its URL normalizes
+// to one that is already subscribed, in which case it returns
+// ErrDuplicateFeed, or the store already holds WithMaxFeeds feeds, in
+// which case it returns ErrFeedLimit. Settings such as Headers, Filters
+// and keyword rules are taken from feed.
+func (s *Store) CreateFeed(feed models.Feed) (models.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.urlTaken(feed.URL, "") {
+		return models.Feed{}, ErrDuplicateFeed
+	}
+	if s.maxFeeds > 0 && len(s.feeds) >= s.maxFeeds {
+		return models.Feed{}, ErrFeedLimit
+	}
+	return s.insertFeed(feed), nil
+}
+
+// UpdateFeed applies the non-nil fields of req to a feed.
+func (s *Store) UpdateFeed(id string, req models.UpdateFeedRequest) (models.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.feeds[id]
+	if !ok {
+		return models.Feed{}, ErrFeedNotFound
+	}
+
+	if req.URL != nil {
+		if s.urlTaken(*req.URL, id) {
+			return models.Feed{}, ErrDuplicateFeed
+		}
+		f.URL = *req.URL
+	}
+	if req.Name != nil {
+		f.Name = *req.Name
+	}
+	if req.Folder != nil {
+		f.Folder = *req.Folder
+	}
+	if req.Priority != nil {
+		f.Priority = *req.Priority
+	}
+	if req.MaxArticles != nil && f.MaxArticles != *req.MaxArticles {
+		f.MaxArticles = *req.MaxArticles
+		// The index is only kept current while the feed is capped, so it
+		// is rebuilt by the next save that needs it.
+		s.capMu.Lock()
+		delete(s.byFeed, id)
+		s.capMu.Unlock()
+		s.capEpoch.Add(1)
+	}
+	if req.Cookie != nil {
+		f.Cookie = *req.Cookie
+	}
+	if req.Strict != nil {
+		f.Strict = *req.Strict
+	}
+	if req.Timezone != nil {
+		f.Timezone = *req.Timezone
+	}
+	if req.KeepRaw != nil {
+		f.KeepRaw = *req.KeepRaw
+		if !f.KeepRaw {
+			delete(s.raw, id)
+		}
+	}
+	if req.IDStrategy != nil {
+		f.IDStrategy = *req.IDStrategy
+	}
+	if req.Headers != nil {
+		f.Headers = maps.Clone(req.Headers)
+	}
+	if req.Filters != nil {
+		f.Filters = slices.Clone(req.Filters)
+	}
+	if req.IncludeKeywords != nil {
+		f.IncludeKeywords = slices.Clone(req.IncludeKeywords)
+	}
+	if req.ExcludeKeywords != nil {
+		f.ExcludeKeywords = slices.Clone(req.ExcludeKeywords)
+	}
+
+	s.feeds[id] = f
+	s.generation.Add(1)
+	return f, nil
+}
+
+// urlTaken reports whether a feed other than exceptID already uses a URL
+// that normalizes like url. Callers must hold mu.
+func (s *Store) urlTaken(url, exceptID string) bool {
+	_, ok := s.feedByURL(url, exceptID)
+	return ok
+}
+
+// feedByURL finds the feed, other than exceptID, whose URL normalizes to
+// the same form as url. Callers must hold mu.
+func (s *Store) feedByURL(url, exceptID string) (models.Feed, bool) {
+	norm := NormalizeURL(url)
+	for id, f := range s.feeds {
+		if id != exceptID && NormalizeURL(f.URL) == norm {
+			return f, true
+		}
 	}
-	s.feeds[id] = feed
+	return models.Feed{}, false
+}
+
+// FeedID derives a feed's ID from its normalized URL, so the same feed gets
+// the same ID however often it is added, removed and re-added.
+func FeedID(url string) string {
+	h := sha256.Sum256([]byte(NormalizeURL(url)))
+	return fmt.Sprintf("feed_%x", h[:8])
+}
+
+// insertFeed stores feed under the ID derived from its URL. Callers must
+// hold mu. Feeds keep their ID when their URL is edited, so if that feed
+// still holds the derived ID a numeric suffix is added.
+func (s *Store) insertFeed(feed models.Feed) models.Feed {
+	// CreatedAt is kept strictly increasing so feeds added within the
+	// clock's resolution still sort in the order they were added.
+	feed.CreatedAt = time.Now()
+	if !feed.CreatedAt.After(s.lastCreated) {
+		feed.CreatedAt = s.lastCreated.Add(time.Nanosecond)
+	}
+	s.lastCreated = feed.CreatedAt
+
+	feed.ID = FeedID(feed.URL)
+	for n := 2; ; n++ {
+		if _, taken := s.feeds[feed.ID]; !taken {
+			break
+		}
+		feed.ID = fmt.Sprintf("%s_%d", FeedID(feed.URL), n)
+	}
+	feed.Enabled = true
+	feed.Headers = maps.Clone(feed.Headers)
+	feed.Filters = slices.Clone(feed.Filters)
+	feed.IncludeKeywords = slices.Clone(feed.IncludeKeywords)
+	feed.ExcludeKeywords = slices.Clone(feed.ExcludeKeywords)
+	s.feeds[feed.ID] = feed
 	return feed
 }
 
-// RemoveFeed deletes a feed and all of its articles.
-func (s *Store) RemoveFeed(id string) bool {
+// RemoveFeed deletes a feed and all of its articles, returning how many
+// articles went with it. ok is false if there is no such feed.
+func (s *Store) RemoveFeed(id string) (removed int, ok bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, ok := s.feeds[id]; !ok {
-		return false
+		return 0, false
 	}
 
 	delete(s.feeds, id)
+	delete(s.history, id)
+	delete(s.raw, id)
+
+	s.capMu.Lock()
+	delete(s.byFeed, id)
+	s.capMu.Unlock()
 
-	for key, art := range s.articles {
-		if art.FeedID == id {
-			delete(s.articles, key)
+	s.mergedMu.Lock()
+	delete(s.merged, id)
+	s.mergedMu.Unlock()
+
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		for key, art := range sh.articles {
+			if art.FeedID == id {
+				delete(sh.articles, key)
+				removed++
+			}
 		}
+		sh.mu.Unlock()
 	}
-	return true
+	s.generation.Add(1)
+	return removed, true
 }
 
-// ListFeeds returns every registered feed.
+// FeedArticleCount returns how many stored articles belong to a feed.
+func (s *Store) FeedArticleCount(feedID string) int {
+	n := 0
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, a := range sh.articles {
+			if a.FeedID == feedID {
+				n++
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return n
+}
+
+// GetFeed returns the feed with the given ID.
+func (s *Store) GetFeed(id string) (models.Feed, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.feeds[id]
+	return f, ok
+}
+
+// ListFeeds returns every registered feed, sorted by name, with its
+// current HealthScore.
 func (s *Store) ListFeeds() []models.Feed {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	now := time.Now()
 	feeds := make([]models.Feed, 0, len(s.feeds))
 	for _, f := range s.feeds {
+		var events []models.FetchEvent
+		if h, ok := s.history[f.ID]; ok {
+			events = h.newestFirst()
+		}
+		f.HealthScore = healthScore(f, events, now)
 		feeds = append(feeds, f)
 	}
+	slices.SortFunc(feeds, compareFeeds)
 	return feeds
 }
 
+// Feed sort orders accepted by FeedQuery.
+const (
+	FeedSortName        = "name"         // the default
+	FeedSortCreatedDesc = "created_desc" // most recently added first
+	FeedSortHealthAsc   = "health_asc"   // least healthy first
+)
+
+// FeedQuery selects a page of feeds. A Limit of 0 means no limit.
+type FeedQuery struct {
+	Limit  int
+	Offset int
+	Sort   string // one of the FeedSort constants; "" sorts by name
+
+	// NeverFetched keeps only feeds with no successful fetch yet, i.e.
+	// with a zero LastFetched.
+	NeverFetched bool
+}
+
+// ListFeedsPage returns the page of feeds selected by q, along with the
+// total number of feeds it selects.
+func (s *Store) ListFeedsPage(q FeedQuery) ([]models.Feed, int) {
+	feeds := s.ListFeeds()
+	if q.NeverFetched {
+		feeds = slices.DeleteFunc(feeds, func(f models.Feed) bool { return !f.LastFetched.IsZero() })
+	}
+	total := len(feeds)
+
+	switch q.Sort {
+	case FeedSortCreatedDesc:
+		slices.SortStableFunc(feeds, func(a, b models.Feed) int {
+			return b.CreatedAt.Compare(a.CreatedAt)
+		})
+	case FeedSortHealthAsc:
+		slices.SortStableFunc(feeds, func(a, b models.Feed) int {
+			return cmp.Compare(a.HealthScore, b.HealthScore)
+		})
+	}
+
+	feeds = feeds[min(q.Offset, total):]
+	if q.Limit > 0 && len(feeds) > q.Limit {
+		feeds = feeds[:q.Limit]
+	}
+	return feeds, total
+}
+
+// compareFeeds orders feeds by name, case-insensitively, then by ID so
+// feeds with the same name always list in the same order.
+func compareFeeds(a, b models.Feed) int {
+	return cmp.Or(
+		strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)),
+		strings.Compare(a.ID, b.ID),
+	)
+}
+
+// FeedCount returns the number of registered feeds.
+func (s *Store) FeedCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.feeds)
+}
+
+// Uncategorized is the folder name reported for, and used to query, feeds
+// that are not filed in any folder.
+const Uncategorized = "uncategorized"
+
+// Folders lists the distinct folder names with their feed counts, sorted by
+// name. Feeds with no folder are counted under Uncategorized.
+func (s *Store) Folders() []models.FolderCount {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, f := range s.feeds {
+		counts[folderOf(f)]++
+	}
+
+	folders := make([]models.FolderCount, 0, len(counts))
+	for name, n := range counts {
+		folders = append(folders, models.FolderCount{Name: name, Feeds: n})
+	}
+	sort.Slice(folders, func(i, j int) bool { return folders[i].Name < folders[j].Name })
+	return folders
+}
+
+// folderOf returns the folder a feed is listed under.
+func folderOf(f models.Feed) string {
+	if f.Folder == "" {
+		return Uncategorized
+	}
+	return f.Folder
+}
+
+// SetPriorities sets the Priority of several feeds at once, keyed by feed
+// ID. Either every feed is updated or, if any does not exist, none is. The
+// updated feeds are returned in fetch order: highest priority first, then
+// by name.
+func (s *Store) SetPriorities(priorities map[string]int) ([]models.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := slices.Sorted(maps.Keys(priorities))
+	for _, id := range ids {
+		if _, ok := s.feeds[id]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrFeedNotFound, id)
+		}
+	}
+
+	updated := make([]models.Feed, 0, len(ids))
+	for _, id := range ids {
+		f := s.feeds[id]
+		f.Priority = priorities[id]
+		s.feeds[id] = f
+		updated = append(updated, f)
+	}
+	slices.SortStableFunc(updated, func(a, b models.Feed) int {
+		return cmp.Or(cmp.Compare(b.Priority, a.Priority), strings.Compare(a.Name, b.Name))
+	})
+	return updated, nil
+}
+
+// SetFeedEnabled pauses or resumes fetching of a feed without touching its
+// articles. It returns the updated feed and false if the feed does not exist.
+func (s *Store) SetFeedEnabled(id string, enabled bool) (models.Feed, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.feeds[id]
+	if !ok {
+		return models.Feed{}, false
+	}
+	if enabled && !f.Enabled {
+		// Re-enabling a feed restarts its staleness clock and lifts any
+		// backoff, so it is fetched on the next cycle.
+		if !f.LastNewArticleAt.IsZero() {
+			f.LastNewArticleAt = time.Now()
+		}
+		f.NextFetchAt = time.Time{}
+	}
+	f.Enabled = enabled
+	s.feeds[id] = f
+	return f, true
+}
+
+// UpdateFeedMeta records details discovered by the latest successful fetch.
+func (s *Store) UpdateFeedMeta(feedID string, meta models.FeedMeta) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.feeds[feedID]; ok {
+		f.Format = meta.Format
+		f.Language = meta.Language
+		f.ImageURL = meta.ImageURL
+		s.feeds[feedID] = f
+		s.generation.Add(1)
+	}
+}
+
+// SetNextFetch stops the scheduler from fetching a feed before t.
+func (s *Store) SetNextFetch(feedID string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.feeds[feedID]; ok {
+		f.NextFetchAt = t
+		s.feeds[feedID] = f
+	}
+}
+
 // UpdateLastFetched records when a feed was last successfully fetched.
 func (s *Store) UpdateLastFetched(feedID string, t time.Time) {
 	s.mu.Lock()
@@ -84,44 +609,744 @@ func (s *Store) UpdateLastFetched(feedID string, t time.Time) {
 	}
 }
 
+// UpdateLastNewArticle records when a fetch last stored new articles.
+func (s *Store) UpdateLastNewArticle(feedID string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.feeds[feedID]; ok {
+		f.LastNewArticleAt = t
+		s.feeds[feedID] = f
+	}
+}
+
 // ---------- Articles ----------
 
-// SaveArticles persists a batch of articles, skipping duplicates by link.
+// SaveArticles persists a batch of articles, skipping duplicates by ID
+// and tombstoned IDs, and near-duplicates by title when WithTitleDedup is
+// set. Each shard is locked once per call, so the existence check and
+// insert for a given ID are atomic. Every stored article is given the next
+// Seq; Seqs are unique and never reused, even after deletion. With
+// WithArticleUpdates, changed content of already stored articles is
+// written over theirs. Feeds pushed past their article cap lose their
+// oldest articles before it returns. It returns how many articles were
+// added and kept; updates are not counted.
 func (s *Store) SaveArticles(articles []models.Article) int {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	return len(s.SaveNewArticles(articles))
+}
+
+// SaveNewArticles is SaveArticles returning the articles it added and
+// kept, with their Seq set, rather than how many.
+func (s *Store) SaveNewArticles(articles []models.Article) []models.Article {
+	epoch := s.capEpoch.Load()
+	caps := s.articleCaps(articles)
+	s.dedup.seen.Add(int64(len(articles)))
 
-	saved := 0
+	var inserted, updated []models.Article
+	if s.dedupWindow > 0 {
+		s.titleMu.Lock()
+		defer s.titleMu.Unlock()
+		n := len(articles)
+		articles = s.dropNearDuplicates(articles)
+		s.dedup.duplicateTitle.Add(int64(n - len(articles)))
+	}
+	articles, merged := s.dropMerged(articles)
+	s.dedup.duplicateID.Add(int64(merged))
+
+	buckets := make(map[int][]models.Article)
 	for _, a := range articles {
-		if _, exists := s.articles[a.ID]; !exists {
-			s.articles[a.ID] = a
-			saved++
+		i := s.shardIndex(a.ID)
+		buckets[i] = append(buckets[i], a)
+	}
+
+	var collisions [][2]models.Article
+	floor := s.beginSeqs()
+	for i, batch := range buckets {
+		sh := s.shards[i]
+		sh.mu.Lock()
+		for _, a := range batch {
+			if _, blocked := sh.tombstones[a.ID]; blocked {
+				s.dedup.tombstoned.Add(1)
+				continue
+			}
+			existing, exists := sh.articles[a.ID]
+			if !exists {
+				a.Seq = s.seq.Add(1)
+				sh.articles[a.ID] = a
+				inserted = append(inserted, a)
+				continue
+			}
+			if !sameItem(existing, a) {
+				collisions = append(collisions, [2]models.Article{existing, a})
+				continue
+			}
+			s.dedup.duplicateID.Add(1)
+			if s.updateExisting && contentChanged(existing, a) {
+				existing.Title, existing.Description, existing.Excerpt = a.Title, a.Description, a.Excerpt
+				existing.WordCount, existing.ReadingTimeSeconds = a.WordCount, a.ReadingTimeSeconds
+				sh.articles[a.ID] = existing
+				updated = append(updated, existing)
+			}
+		}
+		sh.mu.Unlock()
+	}
+	s.endSeqs(floor)
+	s.dedup.stored.Add(int64(len(inserted)))
+	s.dedup.idCollisions.Add(int64(len(collisions)))
+
+	// The same ID for a different link means the truncated hash collided;
+	// the incoming article is still dropped, but loudly.
+	for _, c := range collisions {
+		s.logger.Warn("article ID collision, incoming article dropped",
+			"id", c[0].ID,
+			"existing_link", c[0].Link,
+			"incoming_link", c[1].Link,
+		)
+	}
+
+	if s.dedupWindow > 0 {
+		s.indexTitles(inserted)
+		s.indexTitles(updated)
+	}
+
+	// Saves of uncapped feeds leave capMu alone unless a cap changed while
+	// they ran, in which case the index may need dropping.
+	var evicted map[string]bool
+	if len(caps) > 0 || s.capEpoch.Load() != epoch {
+		s.capMu.Lock()
+		s.indexFeeds(inserted, caps)
+		evicted = s.evictOverCap(caps)
+		s.capMu.Unlock()
+	}
+	saved := make([]models.Article, 0, len(inserted))
+	for _, a := range inserted {
+		if !evicted[a.ID] {
+			saved = append(saved, a)
 		}
 	}
+	if len(inserted) > 0 || len(updated) > 0 {
+		s.generation.Add(1)
+	}
 	return saved
 }
 
+// SaveArticlesBatch is SaveArticles for the articles of several feeds at
+// once, keyed by feed ID. Every lock is taken once for the whole batch
+// rather than once per feed. It returns how many articles each feed added
+// and kept, with an entry for every feed in batch.
+func (s *Store) SaveArticlesBatch(batch map[string][]models.Article) map[string]int {
+	counts := make(map[string]int, len(batch))
+	for feedID, stored := range s.SaveNewArticlesBatch(batch) {
+		counts[feedID] = len(stored)
+	}
+	return counts
+}
+
+// SaveNewArticlesBatch is SaveArticlesBatch returning the articles each
+// feed added and kept rather than how many.
+func (s *Store) SaveNewArticlesBatch(batch map[string][]models.Article) map[string][]models.Article {
+	var all []models.Article
+	for _, articles := range batch {
+		all = append(all, articles...)
+	}
+
+	saved := make(map[string][]models.Article, len(batch))
+	for feedID := range batch {
+		saved[feedID] = []models.Article{}
+	}
+	for _, a := range s.SaveNewArticles(all) {
+		saved[a.FeedID] = append(saved[a.FeedID], a)
+	}
+	return saved
+}
+
+// sameItem reports whether two articles with the same ID are the same feed
+// item, matched by link or, as IDs may come from it, by GUID. Otherwise the
+// truncated hash behind the ID collided. Feeds are not compared: an article
+// moved or merged into another feed keeps the ID hashed from its old one,
+// which refetches of the old feed still produce.
+func sameItem(a, b models.Article) bool {
+	return a.Link == b.Link || (a.GUID != "" && a.GUID == b.GUID)
+}
+
+// contentChanged reports whether incoming carries different content for
+// the article stored as existing.
+func contentChanged(existing, incoming models.Article) bool {
+	return existing.Title != incoming.Title ||
+		existing.Description != incoming.Description ||
+		existing.Excerpt != incoming.Excerpt
+}
+
+// DeleteArticle removes a stored article. A later fetch may add it again
+// unless it is also tombstoned.
+func (s *Store) DeleteArticle(id string) bool {
+	sh := s.shards[s.shardIndex(id)]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if _, ok := sh.articles[id]; !ok {
+		return false
+	}
+	delete(sh.articles, id)
+	s.generation.Add(1)
+	return true
+}
+
+// ClearArticles removes every stored article, leaving feeds, their fetch
+// state and tombstones in place. It returns the number removed.
+func (s *Store) ClearArticles() int {
+	s.capMu.Lock()
+	clear(s.byFeed)
+	s.capMu.Unlock()
+
+	s.mergedMu.Lock()
+	clear(s.merged)
+	s.mergedMu.Unlock()
+
+	removed := 0
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		removed += len(sh.articles)
+		sh.articles = make(map[string]models.Article)
+		sh.mu.Unlock()
+	}
+	s.generation.Add(1)
+	return removed
+}
+
+// SaveForLater adds an article to the read-later queue. Saving an article
+// that is already queued keeps its original place. It returns false if the
+// article does not exist.
+func (s *Store) SaveForLater(id string) bool {
+	sh := s.shards[s.shardIndex(id)]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	a, ok := sh.articles[id]
+	if !ok {
+		return false
+	}
+	if a.SavedAt.IsZero() {
+		a.SavedAt = time.Now()
+		sh.articles[id] = a
+		s.generation.Add(1)
+	}
+	return true
+}
+
+// RemoveFromSaveLater takes an article off the read-later queue. It returns
+// false if the article does not exist.
+func (s *Store) RemoveFromSaveLater(id string) bool {
+	sh := s.shards[s.shardIndex(id)]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	a, ok := sh.articles[id]
+	if !ok {
+		return false
+	}
+	a.SavedAt = time.Time{}
+	sh.articles[id] = a
+	s.generation.Add(1)
+	return true
+}
+
+// MoveArticle re-assigns a stored article to another feed, taking on its
+// name. From then on the article is removed with its new feed, not its old
+// one. If the feed has an article cap, its oldest articles beyond it are
+// evicted, which may be the moved one. It returns false if the article or
+// the feed does not exist.
+func (s *Store) MoveArticle(id, newFeedID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	feed, ok := s.feeds[newFeedID]
+	if !ok {
+		return false
+	}
+
+	s.titleMu.Lock()
+	defer s.titleMu.Unlock()
+	s.capMu.Lock()
+	defer s.capMu.Unlock()
+
+	sh := s.shards[s.shardIndex(id)]
+	sh.mu.Lock()
+	a, ok := sh.articles[id]
+	if !ok {
+		sh.mu.Unlock()
+		return false
+	}
+	moved := a
+	moved.FeedID, moved.FeedName = feed.ID, feed.Name
+	sh.articles[id] = moved
+	sh.mu.Unlock()
+	s.generation.Add(1)
+
+	if s.dedupWindow > 0 {
+		if key := titleKey(a); key != "" {
+			s.titles[key] = slices.DeleteFunc(s.titles[key], func(v string) bool { return v == id })
+		}
+		s.indexTitles([]models.Article{moved})
+	}
+	if ids, ok := s.byFeed[a.FeedID]; ok {
+		s.byFeed[a.FeedID] = slices.DeleteFunc(ids, func(v string) bool { return v == id })
+	}
+	if ids, ok := s.byFeed[moved.FeedID]; ok {
+		s.byFeed[moved.FeedID] = append(ids, id)
+	}
+	limit := s.maxPerFeed
+	if feed.MaxArticles > 0 {
+		limit = feed.MaxArticles
+	}
+	if limit > 0 {
+		if _, ok := s.byFeed[feed.ID]; !ok {
+			s.byFeed[feed.ID] = s.scanFeed(feed.ID)
+		}
+		s.evictOverCap(map[string]int{feed.ID: limit})
+	}
+	return true
+}
+
+// MergeFeeds folds the duplicate feed into primary: the duplicate's
+// articles are re-assigned to primary, keeping their IDs, except those
+// whose link primary already has, which are dropped; then the duplicate is
+// removed. Items moved this way are not saved again when primary's own
+// fetches bring them under a different ID. If primary has an article cap, its oldest articles beyond it
+// are evicted. It returns how many articles were moved, and false if
+// either feed does not exist or they are the same feed.
+func (s *Store) MergeFeeds(primaryID, dupID string) (int, bool) {
+	if primaryID == dupID {
+		return 0, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	primary, ok := s.feeds[primaryID]
+	if _, dupOK := s.feeds[dupID]; !ok || !dupOK {
+		return 0, false
+	}
+
+	s.titleMu.Lock()
+	defer s.titleMu.Unlock()
+	s.capMu.Lock()
+	defer s.capMu.Unlock()
+
+	links := make(map[string]bool)
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, a := range sh.articles {
+			if a.FeedID == primaryID && a.Link != "" {
+				links[a.Link] = true
+			}
+		}
+		sh.mu.RUnlock()
+	}
+
+	var moved []models.Article
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		for id, a := range sh.articles {
+			if a.FeedID != dupID {
+				continue
+			}
+			if links[a.Link] {
+				delete(sh.articles, id)
+				continue
+			}
+			a.FeedID, a.FeedName = primary.ID, primary.Name
+			sh.articles[id] = a
+			moved = append(moved, a)
+		}
+		sh.mu.Unlock()
+	}
+
+	delete(s.feeds, dupID)
+	delete(s.history, dupID)
+	delete(s.raw, dupID)
+
+	if s.dedupWindow > 0 {
+		for key := range s.titles {
+			if strings.HasPrefix(key, dupID+"\x00") {
+				delete(s.titles, key)
+			}
+		}
+		s.indexTitles(moved)
+	}
+	delete(s.byFeed, dupID)
+	delete(s.byFeed, primaryID)
+
+	s.mergedMu.Lock()
+	for k, id := range s.merged[dupID] {
+		if s.merged[primaryID] == nil {
+			s.merged[primaryID] = make(map[string]string)
+		}
+		s.merged[primaryID][k] = id
+	}
+	delete(s.merged, dupID)
+	s.indexMerged(primaryID, moved)
+	s.mergedMu.Unlock()
+
+	limit := s.maxPerFeed
+	if primary.MaxArticles > 0 {
+		limit = primary.MaxArticles
+	}
+	if limit > 0 {
+		s.byFeed[primaryID] = s.scanFeed(primaryID)
+		s.evictOverCap(map[string]int{primaryID: limit})
+	}
+	s.generation.Add(1)
+	return len(moved), true
+}
+
+// Tombstone blocks an article ID so SaveArticles never stores it again.
+func (s *Store) Tombstone(id string) {
+	sh := s.shards[s.shardIndex(id)]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	sh.tombstones[id] = struct{}{}
+}
+
+// Generation returns a counter that changes whenever stored articles, or
+// the feed details they are filtered by, change. Two equal readings mean an
+// article query would return the same result both times.
+func (s *Store) Generation() uint64 {
+	return s.generation.Load()
+}
+
+// folderFeeds returns the IDs of the feeds listed under folder. The map is
+// non-nil even when the folder is empty.
+func (s *Store) folderFeeds(folder string) map[string]bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make(map[string]bool)
+	for id, f := range s.feeds {
+		if folderOf(f) == folder {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// languageFeeds returns the IDs of the feeds whose declared language is
+// lang or, for a bare language like "en", one of its regional variants.
+// Matching ignores case. Feeds that declare no language never match.
+func (s *Store) languageFeeds(lang string) map[string]bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make(map[string]bool)
+	for id, f := range s.feeds {
+		if f.Language == "" {
+			continue
+		}
+		base, _, _ := strings.Cut(f.Language, "-")
+		if strings.EqualFold(f.Language, lang) || strings.EqualFold(base, lang) {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// Articles iterates over every stored article in no particular order,
+// for exports and other full dumps. Each shard is copied under its read
+// lock and released before its articles are yielded, so writers are only
+// blocked for one shard's copy at a time and never while the caller works.
+// Each shard is a point-in-time copy; writes to shards not yet reached may
+// or may not be seen.
+func (s *Store) Articles() iter.Seq[models.Article] {
+	return func(yield func(models.Article) bool) {
+		var buf []models.Article
+		for _, sh := range s.shards {
+			sh.mu.RLock()
+			buf = buf[:0]
+			for _, a := range sh.articles {
+				buf = append(buf, a)
+			}
+			sh.mu.RUnlock()
+
+			for _, a := range buf {
+				if !yield(a) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ArticleCount returns the number of stored articles.
+func (s *Store) ArticleCount() int {
+	n := 0
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		n += len(sh.articles)
+		sh.mu.RUnlock()
+	}
+	return n
+}
+
+// Article sort orders accepted by ArticleQuery.
+const (
+	SortPublishedDesc = "published_desc" // newest first (default)
+	SortPublishedAsc  = "published_asc"
+	SortSeqAsc        = "seq_asc"    // save order, for incremental sync
+	SortSavedDesc     = "saved_desc" // most recently queued to read later first
+)
+
+// Article mixes accepted by ArticleQuery.
+const (
+	MixChronological = "chronological" // the sort order alone (default)
+	MixBalanced      = "balanced"      // feeds take turns; see ArticleQuery.Mix
+)
+
+// sortKeyDesc orders by PublishedAt, then ID, both descending: the order
+// of keyset pages. It is chosen by ArticleQuery.Before, not requested.
+const sortKeyDesc = "key_desc"
+
+// ArticleKey is the position of an article in keyset paging.
+type ArticleKey struct {
+	PublishedAt time.Time
+	ID          string
+}
+
+// precedes reports whether k comes before a in sortKeyDesc order. The
+// zero key precedes every article.
+func (k ArticleKey) precedes(a models.Article) bool {
+	if k == (ArticleKey{}) {
+		return true
+	}
+	if c := a.PublishedAt.Compare(k.PublishedAt); c != 0 {
+		return c < 0
+	}
+	return a.ID < k.ID
+}
+
+// ArticleQuery selects a page of articles. Zero values mean "no filter".
+type ArticleQuery struct {
+	FeedIDs  []string // match articles from any of these feeds
+	Folder   string   // only feeds in this folder; Uncategorized for none
+	Language string   // only feeds declaring this language; "en" also matches "en-US"
+	AfterSeq int64    // only articles saved after this Seq
+	Saved    bool     // only articles queued to read later
+	Limit    int      // <= 0 means no limit
+	Offset   int
+	Sort     string
+
+	// PerFeedLimit keeps only each feed's first articles in the sort
+	// order, before Offset and Limit apply, so that a prolific feed cannot
+	// crowd out the rest. Total counts what is left. <= 0 means no cap.
+	PerFeedLimit int
+
+	// Mix set to MixBalanced interleaves feeds round-robin: each feed's
+	// first article in the sort order, then each one's second, and so on,
+	// with feeds taking their turns in the order of their first article.
+	// It applies after PerFeedLimit and before Offset and Limit.
+	Mix string
+
+	// Before keeps only the articles after this key, newest first, and
+	// orders them by PublishedAt and then ID instead of Sort. Unlike
+	// Offset, paging this way neither skips nor repeats articles when
+	// newer ones are saved between pages. The first page is fetched with
+	// the zero key, since the order of Sort breaks ties differently.
+	Before *ArticleKey
+}
+
 // ListArticles returns articles sorted newest-first.
 // If feedID is non-empty only articles from that feed are returned.
 // limit <= 0 means no limit.
 func (s *Store) ListArticles(feedID string, limit int) []models.Article {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	q := ArticleQuery{Limit: limit}
+	if feedID != "" {
+		q.FeedIDs = []string{feedID}
+	}
+	page, _ := s.QueryArticles(q)
+	return page
+}
 
-	result := make([]models.Article, 0, len(s.articles))
-	for _, a := range s.articles {
-		if feedID != "" && a.FeedID != feedID {
-			continue
+// compareArticles orders a and b for the given sort. Ties on the sort key
+// fall back to Seq, then ID, in the same direction, so equal timestamps
+// still give the same order on every call.
+func compareArticles(a, b models.Article, order string) int {
+	var c int
+	switch order {
+	case sortKeyDesc:
+		return cmp.Or(b.PublishedAt.Compare(a.PublishedAt), strings.Compare(b.ID, a.ID))
+	case SortPublishedAsc:
+		c = a.PublishedAt.Compare(b.PublishedAt)
+	case SortSeqAsc:
+		c = cmp.Compare(a.Seq, b.Seq)
+	case SortSavedDesc:
+		c = b.SavedAt.Compare(a.SavedAt)
+	default:
+		c = b.PublishedAt.Compare(a.PublishedAt)
+	}
+	if c != 0 {
+		return c
+	}
+	if order == SortPublishedAsc || order == SortSeqAsc {
+		return cmp.Or(cmp.Compare(a.Seq, b.Seq), strings.Compare(a.ID, b.ID))
+	}
+	return cmp.Or(cmp.Compare(b.Seq, a.Seq), strings.Compare(b.ID, a.ID))
+}
+
+// QueryArticles returns the page of articles matching q, sorted newest-first
+// unless q.Sort says otherwise, along with the total number of matches
+// before limit and offset apply.
+func (s *Store) QueryArticles(q ArticleQuery) ([]models.Article, int) {
+	page, total, _ := s.QueryArticlesContext(context.Background(), q)
+	return page, total
+}
+
+// ctxCheckEvery is how many articles, or sort comparisons, pass between
+// checks for cancellation in QueryArticlesContext.
+const ctxCheckEvery = 1024
+
+// QueryArticlesContext is QueryArticles for large stores: it gives up,
+// returning ctx's error and no articles, once ctx is done, checking
+// periodically while scanning and sorting so a client that has gone away
+// doesn't keep shard locks held.
+func (s *Store) QueryArticlesContext(ctx context.Context, q ArticleQuery) ([]models.Article, int, error) {
+	var inFolder map[string]bool
+	if q.Folder != "" {
+		inFolder = s.folderFeeds(q.Folder)
+	}
+	var inLanguage map[string]bool
+	if q.Language != "" {
+		inLanguage = s.languageFeeds(q.Language)
+	}
+
+	order := q.Sort
+	if q.Before != nil {
+		order = sortKeyDesc
+	}
+
+	// With a limit only the first Offset+Limit matches can be returned, so
+	// between shards the matches are cut back to those rather than held
+	// until the end.
+	keep := 0
+	if q.Limit > 0 && q.PerFeedLimit <= 0 && q.Mix != MixBalanced {
+		keep = q.Offset + q.Limit
+	}
+
+	// Articles of saves still running are left for the next query, so
+	// that paging by AfterSeq never skips one.
+	committed := s.committedSeq()
+
+	result := make([]models.Article, 0)
+	total, scanned := 0, 0
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, a := range sh.articles {
+			if scanned++; scanned%ctxCheckEvery == 0 && ctx.Err() != nil {
+				sh.mu.RUnlock()
+				return nil, 0, ctx.Err()
+			}
+			if len(q.FeedIDs) > 0 && !slices.Contains(q.FeedIDs, a.FeedID) {
+				continue
+			}
+			if inFolder != nil && !inFolder[a.FeedID] {
+				continue
+			}
+			if inLanguage != nil && !inLanguage[a.FeedID] {
+				continue
+			}
+			if a.Seq <= q.AfterSeq || a.Seq > committed {
+				continue
+			}
+			if q.Saved && a.SavedAt.IsZero() {
+				continue
+			}
+			if q.Before != nil && !q.Before.precedes(a) {
+				continue
+			}
+			total++
+			result = append(result, a)
 		}
-		result = append(result, a)
+		sh.mu.RUnlock()
+
+		if keep > 0 && len(result) >= 2*keep {
+			if err := sortArticles(ctx, result, order); err != nil {
+				return nil, 0, err
+			}
+			result = result[:keep]
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	if err := sortArticles(ctx, result, order); err != nil {
+		return nil, 0, err
 	}
+	if q.PerFeedLimit > 0 {
+		result = capPerFeed(result, q.PerFeedLimit)
+		total = len(result)
+	}
+	if q.Mix == MixBalanced {
+		result = interleaveFeeds(result)
+	}
+
+	if q.Offset > 0 {
+		if q.Offset >= len(result) {
+			return make([]models.Article, 0), total, nil
+		}
+		result = result[q.Offset:]
+	}
+
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[:q.Limit]
+	}
+	return result, total, nil
+}
 
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].PublishedAt.After(result[j].PublishedAt)
+// capPerFeed keeps the first limit articles of each feed, in place and in
+// their existing order.
+func capPerFeed(articles []models.Article, limit int) []models.Article {
+	counts := make(map[string]int)
+	return slices.DeleteFunc(articles, func(a models.Article) bool {
+		counts[a.FeedID]++
+		return counts[a.FeedID] > limit
 	})
+}
+
+// interleaveFeeds reorders sorted articles so that feeds take turns, as
+// described for MixBalanced.
+func interleaveFeeds(articles []models.Article) []models.Article {
+	var order []string
+	byFeed := make(map[string][]models.Article)
+	for _, a := range articles {
+		if _, ok := byFeed[a.FeedID]; !ok {
+			order = append(order, a.FeedID)
+		}
+		byFeed[a.FeedID] = append(byFeed[a.FeedID], a)
+	}
 
-	if limit > 0 && len(result) > limit {
-		result = result[:limit]
+	mixed := make([]models.Article, 0, len(articles))
+	for round := 0; len(mixed) < len(articles); round++ {
+		for _, feedID := range order {
+			if feed := byFeed[feedID]; round < len(feed) {
+				mixed = append(mixed, feed[round])
+			}
+		}
+	}
+	return mixed
+}
+
+// sortArticles sorts articles in the given order, giving up with ctx's
+// error once ctx is done. Once it is, every pair compares equal, which
+// lets the sort finish quickly so the error can be returned.
+func sortArticles(ctx context.Context, articles []models.Article, order string) error {
+	compared, cancelled := 0, false
+	sort.Slice(articles, func(i, j int) bool {
+		if compared++; compared%ctxCheckEvery == 0 && ctx.Err() != nil {
+			cancelled = true
+		}
+		return !cancelled && compareArticles(articles[i], articles[j], order) < 0
+	})
+	if cancelled {
+		return ctx.Err()
 	}
-	return result
+	return nil
 }
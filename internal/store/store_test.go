@@ -1,6 +1,16 @@
 package store_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -24,15 +34,182 @@ func TestAddAndListFeeds(t *testing.T) {
 	}
 }
 
+func TestFeedIDsAreDerivedFromURL(t *testing.T) {
+	s := store.New()
+
+	f := s.AddFeed("Go Blog", "https://go.dev/blog/feed.atom")
+	if f.ID != store.FeedID("https://go.dev/blog/feed.atom") {
+		t.Fatalf("expected ID derived from the URL, got %s", f.ID)
+	}
+	if again := s.AddFeed("Go Blog (again)", "HTTPS://go.dev/blog/feed.atom/"); again.ID != f.ID || again.Name != "Go Blog" {
+		t.Fatalf("expected re-adding the same URL to return the existing feed, got %+v", again)
+	}
+	if n := len(s.ListFeeds()); n != 1 {
+		t.Fatalf("expected 1 feed after re-adding, got %d", n)
+	}
+
+	s.RemoveFeed(f.ID)
+	if readded := s.AddFeed("Go Blog", "https://go.dev/blog/feed.atom"); readded.ID != f.ID {
+		t.Fatalf("expected the same ID after re-adding, got %s and %s", f.ID, readded.ID)
+	}
+	if other := store.New().AddFeed("Go Blog", "https://go.dev/blog/feed.atom"); other.ID != f.ID {
+		t.Fatalf("expected the same ID in another store, got %s and %s", f.ID, other.ID)
+	}
+}
+
+func TestFeedIDSurvivesURLChange(t *testing.T) {
+	s := store.New()
+
+	old := "https://example.com/old.xml"
+	moved := s.AddFeed("Moved", old)
+	newURL := "https://example.com/new.xml"
+	if _, err := s.UpdateFeed(moved.ID, models.UpdateFeedRequest{URL: &newURL}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The moved feed keeps its ID, so a new subscription to the old URL
+	// needs a different one.
+	replacement := s.AddFeed("Replacement", old)
+	if replacement.ID == moved.ID {
+		t.Fatalf("expected a distinct ID, both got %s", moved.ID)
+	}
+	if f, ok := s.GetFeed(moved.ID); !ok || f.URL != newURL {
+		t.Fatalf("moved feed was overwritten: %+v", f)
+	}
+}
+
+func TestListFeedsPage(t *testing.T) {
+	s := store.New()
+	for _, name := range []string{"delta", "Alpha", "charlie", "Bravo", "echo"} {
+		s.AddFeed(name, "https://example.com/"+name)
+	}
+
+	var names []string
+	for _, f := range s.ListFeeds() {
+		names = append(names, f.Name)
+	}
+	if want := []string{"Alpha", "Bravo", "charlie", "delta", "echo"}; !slices.Equal(names, want) {
+		t.Fatalf("expected feeds sorted by name %v, got %v", want, names)
+	}
+
+	page, total := s.ListFeedsPage(store.FeedQuery{Limit: 2, Offset: 1})
+	if total != 5 || len(page) != 2 || page[0].Name != "Bravo" || page[1].Name != "charlie" {
+		t.Fatalf("expected [Bravo charlie] of 5, got %d feeds of %d: %+v", len(page), total, page)
+	}
+
+	if page, total = s.ListFeedsPage(store.FeedQuery{Limit: 2, Offset: 10}); total != 5 || len(page) != 0 {
+		t.Fatalf("expected an empty page past the end, got %d feeds of %d", len(page), total)
+	}
+	if page, _ = s.ListFeedsPage(store.FeedQuery{Offset: 3}); len(page) != 2 {
+		t.Fatalf("expected no limit to return the rest, got %d feeds", len(page))
+	}
+}
+
+func TestListFeedsByCreation(t *testing.T) {
+	s := store.New()
+	names := []string{"b", "d", "a", "e", "c"}
+	for _, name := range names {
+		s.AddFeed(name, "https://example.com/"+name)
+	}
+
+	page, _ := s.ListFeedsPage(store.FeedQuery{Sort: store.FeedSortCreatedDesc})
+	for i, f := range page {
+		if want := names[len(names)-1-i]; f.Name != want {
+			t.Fatalf("expected %s at %d in newest-first order, got %s", want, i, f.Name)
+		}
+		if i > 0 && !f.CreatedAt.Before(page[i-1].CreatedAt) {
+			t.Fatalf("created_at not strictly decreasing at %d", i)
+		}
+	}
+}
+
+func TestCreateFeedRejectsDuplicates(t *testing.T) {
+	s := store.New()
+
+	if _, err := s.CreateFeed(models.Feed{Name: "Go Blog", URL: "https://go.dev/blog/feed.atom"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := s.CreateFeed(models.Feed{Name: "Go Blog again", URL: "HTTPS://Go.dev:443/blog/feed.atom/"})
+	if !errors.Is(err, store.ErrDuplicateFeed) {
+		t.Fatalf("expected ErrDuplicateFeed, got %v", err)
+	}
+
+	if len(s.ListFeeds()) != 1 {
+		t.Fatal("duplicate feed must not be stored")
+	}
+}
+
+func TestCreateFeedEnforcesFeedLimit(t *testing.T) {
+	s := store.New(store.WithMaxFeeds(2))
+	s.AddFeed("Seeded", "https://example.com/seeded")
+	first, err := s.CreateFeed(models.Feed{Name: "First", URL: "https://example.com/1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := s.CreateFeed(models.Feed{Name: "Second", URL: "https://example.com/2"}); !errors.Is(err, store.ErrFeedLimit) {
+		t.Fatalf("expected ErrFeedLimit, got %v", err)
+	}
+
+	s.RemoveFeed(first.ID)
+	if _, err := s.CreateFeed(models.Feed{Name: "Second", URL: "https://example.com/2"}); err != nil {
+		t.Fatalf("expected removing a feed to free a slot, got %v", err)
+	}
+}
+
+func TestUpdateFeed(t *testing.T) {
+	s := store.New()
+	a := s.AddFeed("A", "https://example.com/a")
+	s.AddFeed("B", "https://example.com/b")
+
+	name := "Renamed"
+	updated, err := s.UpdateFeed(a.ID, models.UpdateFeedRequest{
+		Name:    &name,
+		Headers: map[string]string{"Accept": "application/atom+xml"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Name != "Renamed" || updated.URL != a.URL || updated.Headers["Accept"] != "application/atom+xml" {
+		t.Fatalf("unexpected update result: %+v", updated)
+	}
+
+	taken := "https://example.com/b/"
+	if _, err := s.UpdateFeed(a.ID, models.UpdateFeedRequest{URL: &taken}); !errors.Is(err, store.ErrDuplicateFeed) {
+		t.Fatalf("expected ErrDuplicateFeed, got %v", err)
+	}
+
+	if _, err := s.UpdateFeed("missing", models.UpdateFeedRequest{Name: &name}); !errors.Is(err, store.ErrFeedNotFound) {
+		t.Fatalf("expected ErrFeedNotFound, got %v", err)
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	cases := map[string]string{
+		"https://Example.com/feed/":     "https://example.com/feed",
+		"http://example.com:80/rss#top": "http://example.com/rss",
+		"https://example.com:8443/rss":  "https://example.com:8443/rss",
+		"  https://example.com/?a=1  ":  "https://example.com?a=1",
+		"not a url":                     "not a url",
+	}
+
+	for in, want := range cases {
+		if got := store.NormalizeURL(in); got != want {
+			t.Errorf("NormalizeURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
 func TestRemoveFeed(t *testing.T) {
 	s := store.New()
 	f := s.AddFeed("Test", "https://example.com/rss")
 
-	if !s.RemoveFeed(f.ID) {
+	if _, ok := s.RemoveFeed(f.ID); !ok {
 		t.Fatal("expected removal to succeed")
 	}
 
-	if s.RemoveFeed(f.ID) {
+	if _, ok := s.RemoveFeed(f.ID); ok {
 		t.Fatal("expected removal of non-existent feed to return false")
 	}
 
@@ -50,10 +227,16 @@ func TestRemoveFeedCascadesArticles(t *testing.T) {
 		{ID: "a2", FeedID: f.ID, Title: "Post 2"},
 	}
 	s.SaveArticles(articles)
+	s.SaveArticles([]models.Article{{ID: "b1", FeedID: "other"}})
 
-	s.RemoveFeed(f.ID)
+	if n := s.FeedArticleCount(f.ID); n != 2 {
+		t.Fatalf("expected 2 articles counted, got %d", n)
+	}
+	if removed, _ := s.RemoveFeed(f.ID); removed != 2 {
+		t.Fatalf("expected 2 articles removed, got %d", removed)
+	}
 
-	if len(s.ListArticles("", 0)) != 0 {
+	if len(s.ListArticles(f.ID, 0)) != 0 {
 		t.Fatal("expected articles to be removed with feed")
 	}
 }
@@ -78,6 +261,27 @@ func TestSaveArticlesDeduplication(t *testing.T) {
 	}
 }
 
+func TestSaveArticlesWarnsOnIDCollision(t *testing.T) {
+	var buf bytes.Buffer
+	s := store.New(store.WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+
+	s.SaveArticles([]models.Article{{ID: "abcd", FeedID: "f1", Link: "https://example.com/a"}})
+
+	// Same link again: an ordinary duplicate, nothing to report.
+	s.SaveArticles([]models.Article{{ID: "abcd", FeedID: "f1", Link: "https://example.com/a"}})
+	if buf.Len() != 0 {
+		t.Fatalf("expected no warning for a plain duplicate, got %s", buf.String())
+	}
+
+	saved := s.SaveArticles([]models.Article{{ID: "abcd", FeedID: "f1", Link: "https://example.com/b"}})
+	if saved != 0 {
+		t.Fatalf("expected colliding article to be skipped, saved %d", saved)
+	}
+	if !strings.Contains(buf.String(), "collision") || !strings.Contains(buf.String(), "https://example.com/b") {
+		t.Fatalf("expected a collision warning, got %q", buf.String())
+	}
+}
+
 func TestListArticlesSortedAndFiltered(t *testing.T) {
 	s := store.New()
 
@@ -107,3 +311,1438 @@ func TestListArticlesSortedAndFiltered(t *testing.T) {
 		t.Fatalf("expected 1 article with limit, got %d", len(limited))
 	}
 }
+
+func TestListArticlesOrderIsStableForEqualTimestamps(t *testing.T) {
+	s := store.New()
+
+	// A first fetch without dates stamps every item with the same time.
+	published := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var batch []models.Article
+	for _, id := range []string{"e", "b", "d", "a", "c", "f", "h", "g"} {
+		batch = append(batch, models.Article{ID: id, FeedID: "f1", PublishedAt: published})
+	}
+	s.SaveArticles(batch)
+
+	want := s.ListArticles("", 0)
+	for i := 1; i < len(want); i++ {
+		if want[i-1].Seq < want[i].Seq {
+			t.Fatalf("expected ties broken by newest Seq first, got %d before %d", want[i-1].Seq, want[i].Seq)
+		}
+	}
+	for range 20 {
+		got := s.ListArticles("", 0)
+		for i := range got {
+			if got[i].ID != want[i].ID {
+				t.Fatalf("order changed between calls at %d: %s vs %s", i, got[i].ID, want[i].ID)
+			}
+		}
+	}
+
+	asc, _ := s.QueryArticles(store.ArticleQuery{Sort: store.SortPublishedAsc})
+	for i := range asc {
+		if asc[i].ID != want[len(want)-1-i].ID {
+			t.Fatalf("expected oldest-first to reverse newest-first, got %s at %d", asc[i].ID, i)
+		}
+	}
+}
+
+func TestQueryArticlesOffsetAndTotal(t *testing.T) {
+	s := store.New()
+
+	now := time.Now()
+	s.SaveArticles([]models.Article{
+		{ID: "a", FeedID: "f1", PublishedAt: now},
+		{ID: "b", FeedID: "f1", PublishedAt: now.Add(-time.Hour)},
+		{ID: "c", FeedID: "f2", PublishedAt: now.Add(-2 * time.Hour)},
+	})
+
+	page, total := s.QueryArticles(store.ArticleQuery{Limit: 1, Offset: 1})
+	if total != 3 {
+		t.Fatalf("expected total 3, got %d", total)
+	}
+	if len(page) != 1 || page[0].ID != "b" {
+		t.Fatalf("expected page [b], got %+v", page)
+	}
+
+	page, total = s.QueryArticles(store.ArticleQuery{FeedIDs: []string{"f1"}, Offset: 5})
+	if total != 2 || len(page) != 0 {
+		t.Fatalf("expected empty page with total 2, got %d items, total %d", len(page), total)
+	}
+}
+
+func TestQueryArticlesPerFeedLimit(t *testing.T) {
+	s := store.New()
+
+	now := time.Now()
+	var articles []models.Article
+	for i := range 10 {
+		articles = append(articles, models.Article{ID: fmt.Sprintf("busy-%d", i), FeedID: "busy", PublishedAt: now.Add(-time.Duration(i) * time.Minute)})
+	}
+	articles = append(articles,
+		models.Article{ID: "quiet-0", FeedID: "quiet", PublishedAt: now.Add(-time.Hour)},
+		models.Article{ID: "quiet-1", FeedID: "quiet", PublishedAt: now.Add(-2 * time.Hour)},
+	)
+	s.SaveArticles(articles)
+
+	page, _ := s.QueryArticles(store.ArticleQuery{Limit: 5})
+	for _, a := range page {
+		if a.FeedID != "busy" {
+			t.Fatalf("expected the busy feed to fill an uncapped page, got %+v", page)
+		}
+	}
+
+	page, total := s.QueryArticles(store.ArticleQuery{Limit: 4, PerFeedLimit: 3})
+	if total != 5 {
+		t.Fatalf("expected total 5 after capping, got %d", total)
+	}
+	var ids []string
+	for _, a := range page {
+		ids = append(ids, a.ID)
+	}
+	if want := []string{"busy-0", "busy-1", "busy-2", "quiet-0"}; !slices.Equal(ids, want) {
+		t.Fatalf("expected %v, got %v", want, ids)
+	}
+}
+
+func TestQueryArticlesBalancedMix(t *testing.T) {
+	s := store.New()
+
+	now := time.Now()
+	s.SaveArticles([]models.Article{
+		{ID: "a0", FeedID: "a", PublishedAt: now},
+		{ID: "a1", FeedID: "a", PublishedAt: now.Add(-time.Minute)},
+		{ID: "a2", FeedID: "a", PublishedAt: now.Add(-2 * time.Minute)},
+		{ID: "a3", FeedID: "a", PublishedAt: now.Add(-3 * time.Minute)},
+		{ID: "b0", FeedID: "b", PublishedAt: now.Add(-time.Hour)},
+		{ID: "b1", FeedID: "b", PublishedAt: now.Add(-2 * time.Hour)},
+		{ID: "c0", FeedID: "c", PublishedAt: now.Add(-30 * time.Minute)},
+	})
+
+	ids := func(q store.ArticleQuery) []string {
+		page, _ := s.QueryArticles(q)
+		var ids []string
+		for _, a := range page {
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
+
+	if got, want := ids(store.ArticleQuery{Mix: store.MixBalanced}), []string{"a0", "c0", "b0", "a1", "b1", "a2", "a3"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if got, want := ids(store.ArticleQuery{Mix: store.MixBalanced, Limit: 2, Offset: 3}), []string{"a1", "b1"}; !slices.Equal(got, want) {
+		t.Fatalf("expected the page %v, got %v", want, got)
+	}
+	if got, want := ids(store.ArticleQuery{Limit: 3}), []string{"a0", "a1", "a2"}; !slices.Equal(got, want) {
+		t.Fatalf("expected chronological order by default, got %v", got)
+	}
+}
+
+func TestSaveArticlesBatch(t *testing.T) {
+	s := store.New(store.WithMaxArticlesPerFeed(2))
+	s.SaveArticles([]models.Article{{ID: "a-old", FeedID: "a", Link: "https://example.com/a-old"}})
+
+	now := time.Now()
+	counts := s.SaveArticlesBatch(map[string][]models.Article{
+		"a": {
+			{ID: "a-old", FeedID: "a", Link: "https://example.com/a-old"},
+			{ID: "a-new", FeedID: "a", Link: "https://example.com/a-new", PublishedAt: now},
+		},
+		"b": {
+			{ID: "b-1", FeedID: "b", PublishedAt: now},
+			{ID: "b-2", FeedID: "b", PublishedAt: now.Add(-time.Minute)},
+			{ID: "b-3", FeedID: "b", PublishedAt: now.Add(-time.Hour)},
+		},
+		"c": nil,
+	})
+
+	// b-3 is stored, then evicted over b's cap, so it does not count.
+	if want := map[string]int{"a": 1, "b": 2, "c": 0}; !maps.Equal(counts, want) {
+		t.Fatalf("expected counts %v, got %v", want, counts)
+	}
+	var ids []string
+	for _, a := range s.ListArticles("", 0) {
+		ids = append(ids, a.ID)
+	}
+	slices.Sort(ids)
+	if want := []string{"a-new", "a-old", "b-1", "b-2"}; !slices.Equal(ids, want) {
+		t.Fatalf("expected %v stored, got %v", want, ids)
+	}
+}
+
+func TestQueryArticlesPagesMatchFullSort(t *testing.T) {
+	s := store.New(store.WithShards(8))
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	batch := make([]models.Article, 500)
+	for i := range batch {
+		// Shared timestamps exercise the Seq and ID tie-breaks.
+		batch[i] = models.Article{ID: fmt.Sprint("a", i), FeedID: "f1", PublishedAt: base.Add(time.Duration(i%37) * time.Minute)}
+	}
+	s.SaveArticles(batch)
+
+	all := s.ListArticles("", 0)
+	for _, offset := range []int{0, 7, 250, 495} {
+		page, total := s.QueryArticles(store.ArticleQuery{Limit: 10, Offset: offset})
+		if total != len(all) {
+			t.Fatalf("offset %d: expected total %d, got %d", offset, len(all), total)
+		}
+		want := all[offset:min(offset+10, len(all))]
+		if !slices.EqualFunc(page, want, func(a, b models.Article) bool { return a.ID == b.ID }) {
+			t.Fatalf("offset %d: page differs from the full sort", offset)
+		}
+	}
+}
+
+func TestQueryArticlesKeysetPaging(t *testing.T) {
+	s := store.New()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	batch := make([]models.Article, 30)
+	for i := range batch {
+		// Groups of three share a timestamp, so pages split on ID ties.
+		batch[i] = models.Article{ID: fmt.Sprintf("a%02d", i), FeedID: "f1", PublishedAt: base.Add(time.Duration(i/3) * time.Minute)}
+	}
+	s.SaveArticles(batch)
+
+	seen := make(map[string]bool)
+	key := &store.ArticleKey{}
+	for page := 0; ; page++ {
+		got, _ := s.QueryArticles(store.ArticleQuery{Limit: 7, Before: key})
+		if len(got) == 0 {
+			break
+		}
+		for _, a := range got {
+			if seen[a.ID] {
+				t.Fatalf("page %d repeats %s", page, a.ID)
+			}
+			seen[a.ID] = true
+		}
+		last := got[len(got)-1]
+		key = &store.ArticleKey{PublishedAt: last.PublishedAt, ID: last.ID}
+
+		// Newer arrivals between pages must not shift the rest.
+		s.SaveArticles([]models.Article{{ID: fmt.Sprint("new", page), FeedID: "f1", PublishedAt: base.Add(time.Hour)}})
+	}
+
+	for _, a := range batch {
+		if !seen[a.ID] {
+			t.Fatalf("paging skipped %s", a.ID)
+		}
+	}
+	if len(seen) != len(batch) {
+		t.Fatalf("expected %d articles, saw %d", len(batch), len(seen))
+	}
+}
+
+func TestQueryArticlesContextAbortsWhenCancelled(t *testing.T) {
+	s := store.New()
+
+	articles := make([]models.Article, 20000)
+	for i := range articles {
+		articles[i] = models.Article{ID: fmt.Sprintf("a%d", i), FeedID: "f1", PublishedAt: time.Unix(int64(i), 0)}
+	}
+	s.SaveArticles(articles)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	page, total, err := s.QueryArticlesContext(ctx, store.ArticleQuery{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if page != nil || total != 0 {
+		t.Fatalf("expected no results, got %d of %d", len(page), total)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("query took %v to notice cancellation", elapsed)
+	}
+
+	if page, total, err = s.QueryArticlesContext(context.Background(), store.ArticleQuery{Limit: 1}); err != nil || total != 20000 || len(page) != 1 {
+		t.Fatalf("expected a normal query to succeed, got %d of %d (err %v)", len(page), total, err)
+	}
+}
+
+func TestFetchHistoryIsCappedAndOrdered(t *testing.T) {
+	s := store.New(store.WithHistorySize(3))
+	f := s.AddFeed("Test", "https://example.com/rss")
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		s.RecordFetch(f.ID, models.FetchEvent{
+			Timestamp:   start.Add(time.Duration(i) * time.Minute),
+			NewArticles: i,
+		})
+	}
+
+	history, ok := s.FetchHistory(f.ID)
+	if !ok {
+		t.Fatal("expected history for existing feed")
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected history capped at 3, got %d", len(history))
+	}
+	for i, want := range []int{4, 3, 2} {
+		if history[i].NewArticles != want {
+			t.Fatalf("expected newest-first order, got %+v", history)
+		}
+	}
+
+	if _, ok := s.FetchHistory("missing"); ok {
+		t.Fatal("expected no history for unknown feed")
+	}
+
+	s.RemoveFeed(f.ID)
+	s.RecordFetch(f.ID, models.FetchEvent{})
+	if _, ok := s.FetchHistory(f.ID); ok {
+		t.Fatal("expected history to be dropped with the feed")
+	}
+}
+
+func TestQueryArticlesMultipleFeeds(t *testing.T) {
+	s := store.New()
+	s.SaveArticles([]models.Article{
+		{ID: "a", FeedID: "f1"},
+		{ID: "b", FeedID: "f2"},
+		{ID: "c", FeedID: "f3"},
+	})
+
+	page, total := s.QueryArticles(store.ArticleQuery{FeedIDs: []string{"f1", "f3"}})
+	if total != 2 || len(page) != 2 {
+		t.Fatalf("expected 2 articles from f1 and f3, got %+v", page)
+	}
+	for _, a := range page {
+		if a.FeedID == "f2" {
+			t.Fatal("article from f2 should be excluded")
+		}
+	}
+}
+
+func TestDeleteArticle(t *testing.T) {
+	s := store.New()
+	s.SaveArticles([]models.Article{{ID: "a", FeedID: "f1"}, {ID: "b", FeedID: "f1"}})
+
+	if !s.DeleteArticle("a") {
+		t.Fatal("expected delete to succeed")
+	}
+	if s.DeleteArticle("a") {
+		t.Fatal("expected second delete to report not found")
+	}
+	if got := s.ListArticles("", 0); len(got) != 1 || got[0].ID != "b" {
+		t.Fatalf("expected only b to remain, got %+v", got)
+	}
+
+	// Without a tombstone the next fetch brings it back.
+	if saved := s.SaveArticles([]models.Article{{ID: "a", FeedID: "f1"}}); saved != 1 {
+		t.Fatalf("expected untombstoned article to be re-added, saved %d", saved)
+	}
+}
+
+func TestMoveArticle(t *testing.T) {
+	s := store.New(store.WithMaxArticlesPerFeed(2))
+	from := s.AddFeed("From", "https://example.com/from")
+	to := s.AddFeed("To", "https://example.com/to")
+	s.SaveArticles([]models.Article{
+		{ID: "a", FeedID: from.ID, FeedName: from.Name, PublishedAt: time.Unix(1, 0)},
+		{ID: "b", FeedID: to.ID, FeedName: to.Name, PublishedAt: time.Unix(2, 0)},
+	})
+
+	if s.MoveArticle("a", "missing") {
+		t.Fatal("expected a move to an unknown feed to fail")
+	}
+	if s.MoveArticle("missing", to.ID) {
+		t.Fatal("expected moving an unknown article to fail")
+	}
+	if !s.MoveArticle("a", to.ID) {
+		t.Fatal("expected the move to succeed")
+	}
+
+	got := s.ListArticles(to.ID, 0)
+	if len(got) != 2 || got[1].ID != "a" || got[1].FeedName != "To" {
+		t.Fatalf("expected a to belong to To, got %+v", got)
+	}
+
+	// The moved article counts towards its new feed's cap.
+	s.SaveArticles([]models.Article{{ID: "c", FeedID: to.ID, PublishedAt: time.Unix(3, 0)}})
+	if got := s.ListArticles(to.ID, 0); len(got) != 2 || got[1].ID != "b" {
+		t.Fatalf("expected a evicted as the oldest of To, got %+v", got)
+	}
+
+	// Moving into a full feed evicts its oldest article straight away.
+	s.SaveArticles([]models.Article{{ID: "d", FeedID: from.ID, PublishedAt: time.Unix(4, 0)}})
+	s.MoveArticle("d", to.ID)
+	if got := s.ListArticles(to.ID, 0); len(got) != 2 || got[0].ID != "d" || got[1].ID != "c" {
+		t.Fatalf("expected b evicted by the move into To, got %+v", got)
+	}
+
+	// It now goes with its new feed, not its old one.
+	s.MoveArticle("c", from.ID)
+	if removed, _ := s.RemoveFeed(to.ID); removed != 1 {
+		t.Fatalf("expected 1 article removed with To, got %d", removed)
+	}
+	if n := s.FeedArticleCount(from.ID); n != 1 {
+		t.Fatalf("expected c to survive under From, got %d", n)
+	}
+}
+
+func TestMovedArticleIsADuplicateOnRefetch(t *testing.T) {
+	var buf bytes.Buffer
+	s := store.New(store.WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	from := s.AddFeed("From", "https://example.com/from")
+	to := s.AddFeed("To", "https://example.com/to")
+	item := models.Article{ID: "a", FeedID: from.ID, Link: "https://example.com/a"}
+
+	s.SaveArticles([]models.Article{item})
+	s.MoveArticle("a", to.ID)
+	for range 2 {
+		if saved := s.SaveArticles([]models.Article{item}); saved != 0 {
+			t.Fatalf("expected the refetched item skipped, saved %d", saved)
+		}
+	}
+
+	if stats := s.DedupStats(); stats.IDCollisions != 0 || stats.DuplicateID != 2 {
+		t.Fatalf("expected 2 duplicates and no collisions, got %+v", stats)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no collision warning, got %s", buf.String())
+	}
+	if got := s.ListArticles(to.ID, 0); len(got) != 1 || got[0].FeedID != to.ID {
+		t.Fatalf("expected the article to stay in To, got %+v", got)
+	}
+}
+
+func TestGenerationTracksArticleChanges(t *testing.T) {
+	s := store.New()
+	feed := s.AddFeed("Feed", "https://example.com/feed")
+
+	changes := []struct {
+		name string
+		fn   func()
+	}{
+		{"save", func() { s.SaveArticles([]models.Article{{ID: "a1", FeedID: feed.ID, Link: "https://example.com/1"}}) }},
+		{"save for later", func() { s.SaveForLater("a1") }},
+		{"remove from save later", func() { s.RemoveFromSaveLater("a1") }},
+		{"delete", func() { s.DeleteArticle("a1") }},
+		{"clear", func() { s.ClearArticles() }},
+	}
+	for _, c := range changes {
+		before := s.Generation()
+		c.fn()
+		if s.Generation() == before {
+			t.Fatalf("%s: expected the generation to change", c.name)
+		}
+	}
+
+	before := s.Generation()
+	s.SaveArticles([]models.Article{{ID: "a1", FeedID: feed.ID, Link: "https://example.com/1"}})
+	s.SaveArticles([]models.Article{{ID: "a1", FeedID: feed.ID, Link: "https://example.com/1"}})
+	if got := s.Generation(); got != before+1 {
+		t.Fatalf("expected only the first save to change the generation, went from %d to %d", before, got)
+	}
+}
+
+func TestClearArticlesKeepsFeeds(t *testing.T) {
+	s := store.New()
+	feed := s.AddFeed("Feed", "https://example.com/rss")
+	fetched := time.Now()
+	s.UpdateLastFetched(feed.ID, fetched)
+	s.SaveArticles([]models.Article{{ID: "a", FeedID: feed.ID}, {ID: "b", FeedID: feed.ID}})
+
+	if removed := s.ClearArticles(); removed != 2 {
+		t.Fatalf("expected 2 removed, got %d", removed)
+	}
+	if got := s.ListArticles("", 0); len(got) != 0 {
+		t.Fatalf("expected no articles, got %d", len(got))
+	}
+	got, ok := s.GetFeed(feed.ID)
+	if !ok || !got.LastFetched.Equal(fetched) {
+		t.Fatalf("expected feed and LastFetched to remain, got %+v", got)
+	}
+}
+
+func TestTombstonePreventsReAdd(t *testing.T) {
+	s := store.New()
+	s.SaveArticles([]models.Article{{ID: "spam", FeedID: "f1"}})
+
+	s.DeleteArticle("spam")
+	s.Tombstone("spam")
+
+	if saved := s.SaveArticles([]models.Article{{ID: "spam", FeedID: "f1"}}); saved != 0 {
+		t.Fatalf("expected tombstoned article to be skipped, saved %d", saved)
+	}
+	if got := s.ListArticles("", 0); len(got) != 0 {
+		t.Fatalf("expected no articles, got %+v", got)
+	}
+}
+
+func TestBlocklistMatchesHostSuffix(t *testing.T) {
+	s := store.New()
+	if p, ok := s.BlockDomain("https://Example.com/some/path"); !ok || p != "example.com" {
+		t.Fatalf("expected URL to reduce to example.com, got %q", p)
+	}
+	if _, ok := s.BlockDomain("  "); ok {
+		t.Fatal("expected empty pattern to be rejected")
+	}
+
+	tests := []struct {
+		link string
+		want bool
+	}{
+		{"https://example.com/a", true},
+		{"https://news.EXAMPLE.com/a", true},
+		{"https://notexample.com/a", false},
+		{"https://example.org/a", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := s.IsBlocked(tt.link); got != tt.want {
+			t.Errorf("IsBlocked(%q) = %v, want %v", tt.link, got, tt.want)
+		}
+	}
+
+	if !s.UnblockDomain("example.com") || s.IsBlocked("https://example.com/a") {
+		t.Fatal("expected unblock to lift the block")
+	}
+}
+
+func TestFoldersAndFolderQuery(t *testing.T) {
+	s := store.New()
+	tech, _ := s.CreateFeed(models.Feed{Name: "Tech", URL: "https://tech.example/rss", Folder: "news"})
+	world, _ := s.CreateFeed(models.Feed{Name: "World", URL: "https://world.example/rss", Folder: "news"})
+	misc := s.AddFeed("Misc", "https://misc.example/rss")
+
+	folders := s.Folders()
+	want := []models.FolderCount{{Name: "news", Feeds: 2}, {Name: store.Uncategorized, Feeds: 1}}
+	if len(folders) != len(want) || folders[0] != want[0] || folders[1] != want[1] {
+		t.Fatalf("expected %+v, got %+v", want, folders)
+	}
+
+	s.SaveArticles([]models.Article{
+		{ID: "a", FeedID: tech.ID},
+		{ID: "b", FeedID: world.ID},
+		{ID: "c", FeedID: misc.ID},
+	})
+
+	if _, total := s.QueryArticles(store.ArticleQuery{Folder: "news"}); total != 2 {
+		t.Fatalf("expected 2 articles in news, got %d", total)
+	}
+	page, _ := s.QueryArticles(store.ArticleQuery{Folder: store.Uncategorized})
+	if len(page) != 1 || page[0].ID != "c" {
+		t.Fatalf("expected only c uncategorized, got %+v", page)
+	}
+	if _, total := s.QueryArticles(store.ArticleQuery{Folder: "missing"}); total != 0 {
+		t.Fatalf("expected no articles in an unknown folder, got %d", total)
+	}
+}
+
+func TestLanguageQuery(t *testing.T) {
+	s := store.New()
+	us := s.AddFeed("US", "https://us.example/rss")
+	uk := s.AddFeed("UK", "https://uk.example/rss")
+	br := s.AddFeed("BR", "https://br.example/rss")
+	unknown := s.AddFeed("Unknown", "https://unknown.example/rss")
+	s.UpdateFeedMeta(us.ID, models.FeedMeta{Language: "en-US"})
+	s.UpdateFeedMeta(uk.ID, models.FeedMeta{Language: "en"})
+	s.UpdateFeedMeta(br.ID, models.FeedMeta{Language: "pt-BR"})
+
+	s.SaveArticles([]models.Article{
+		{ID: "a", FeedID: us.ID},
+		{ID: "b", FeedID: uk.ID},
+		{ID: "c", FeedID: br.ID},
+		{ID: "d", FeedID: unknown.ID},
+	})
+
+	cases := []struct {
+		lang string
+		want int
+	}{
+		{"en", 2},
+		{"EN-us", 1},
+		{"pt", 1},
+		{"fr", 0},
+	}
+	for _, tc := range cases {
+		if _, total := s.QueryArticles(store.ArticleQuery{Language: tc.lang}); total != tc.want {
+			t.Errorf("lang %q: expected %d articles, got %d", tc.lang, tc.want, total)
+		}
+	}
+}
+
+func TestTitleDedupSkipsNearDuplicates(t *testing.T) {
+	s := store.New(store.WithTitleDedup(24 * time.Hour))
+	pub := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s.SaveArticles([]models.Article{
+		{ID: "a", FeedID: "f1", Title: "Go 1.22 Released", Link: "https://example.com/go?utm=1", PublishedAt: pub},
+	})
+
+	saved := s.SaveArticles([]models.Article{
+		// Same story with a new tracking parameter and messier title.
+		{ID: "b", FeedID: "f1", Title: "  go 1.22   released ", Link: "https://example.com/go?utm=2", PublishedAt: pub.Add(time.Hour)},
+		// Same title in another feed is not a duplicate.
+		{ID: "c", FeedID: "f2", Title: "Go 1.22 Released", Link: "https://other.example/go", PublishedAt: pub},
+	})
+	if saved != 1 {
+		t.Fatalf("expected only the other feed's article to be saved, saved %d", saved)
+	}
+	if _, total := s.QueryArticles(store.ArticleQuery{FeedIDs: []string{"f1"}}); total != 1 {
+		t.Fatalf("expected 1 article in f1, got %d", total)
+	}
+}
+
+func TestTitleDedupKeepsDistantSameTitle(t *testing.T) {
+	s := store.New(store.WithTitleDedup(24 * time.Hour))
+	pub := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	saved := s.SaveArticles([]models.Article{
+		{ID: "a", FeedID: "f1", Title: "Weekly Roundup", Link: "https://example.com/w1", PublishedAt: pub},
+		{ID: "b", FeedID: "f1", Title: "Weekly Roundup", Link: "https://example.com/w2", PublishedAt: pub.Add(7 * 24 * time.Hour)},
+		{ID: "c", FeedID: "f1", Title: "Weekly Roundup", Link: "https://example.com/w2b", PublishedAt: pub.Add(7*24*time.Hour + time.Minute)},
+	})
+	if saved != 2 {
+		t.Fatalf("expected the two weekly issues to be saved and the in-batch repeat dropped, saved %d", saved)
+	}
+}
+
+func TestTitleDedupOffByDefault(t *testing.T) {
+	s := store.New()
+	pub := time.Now()
+
+	saved := s.SaveArticles([]models.Article{
+		{ID: "a", FeedID: "f1", Title: "Same", PublishedAt: pub},
+		{ID: "b", FeedID: "f1", Title: "Same", PublishedAt: pub},
+	})
+	if saved != 2 {
+		t.Fatalf("expected both articles without WithTitleDedup, saved %d", saved)
+	}
+}
+
+func TestDedupStatsCountEveryArticleOnce(t *testing.T) {
+	s := store.New(store.WithTitleDedup(24*time.Hour), store.WithLogger(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))))
+	pub := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s.SaveArticles([]models.Article{
+		{ID: "a", FeedID: "f1", Title: "First", Link: "https://example.com/a", PublishedAt: pub},
+		{ID: "gone", FeedID: "f1", Title: "Gone", Link: "https://example.com/gone", PublishedAt: pub},
+	})
+	s.Tombstone("gone")
+	s.SaveArticles([]models.Article{
+		{ID: "a", FeedID: "f1", Title: "First", Link: "https://example.com/a", PublishedAt: pub},
+		{ID: "a2", FeedID: "f1", Title: "first", Link: "https://example.com/a?utm=x", PublishedAt: pub},
+		{ID: "gone", FeedID: "f1", Title: "Gone", Link: "https://example.com/gone", PublishedAt: pub},
+		{ID: "a", FeedID: "f1", Title: "Other", Link: "https://example.com/other", PublishedAt: pub},
+		{ID: "b", FeedID: "f1", Title: "Second", Link: "https://example.com/b", PublishedAt: pub},
+	})
+
+	want := models.DedupStats{Seen: 7, Stored: 3, DuplicateID: 1, DuplicateTitle: 1, Tombstoned: 1, IDCollisions: 1}
+	if got := s.DedupStats(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestPruneArticles(t *testing.T) {
+	s := store.New()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	s.SaveArticles([]models.Article{
+		{ID: "f1-new", FeedID: "f1", PublishedAt: now.Add(-time.Hour)},
+		{ID: "f1-mid", FeedID: "f1", PublishedAt: now.Add(-2 * time.Hour)},
+		{ID: "f1-old", FeedID: "f1", PublishedAt: now.Add(-48 * time.Hour)},
+		{ID: "f1-saved", FeedID: "f1", PublishedAt: now.Add(-72 * time.Hour)},
+		{ID: "f1-undated", FeedID: "f1"},
+		{ID: "f2-old", FeedID: "f2", PublishedAt: now.Add(-48 * time.Hour)},
+	})
+	s.SaveForLater("f1-saved")
+
+	if removed := s.PruneArticles(0, 24*time.Hour, now); removed != 2 {
+		t.Fatalf("expected the two old unsaved articles to be pruned, removed %d", removed)
+	}
+	if removed := s.PruneArticles(1, 0, now); removed != 2 {
+		t.Fatalf("expected f1-mid and f1-undated to be pruned, removed %d", removed)
+	}
+
+	var left []string
+	for a := range s.Articles() {
+		left = append(left, a.ID)
+	}
+	slices.Sort(left)
+	if want := []string{"f1-new", "f1-saved"}; !slices.Equal(left, want) {
+		t.Fatalf("expected %v to remain, got %v", want, left)
+	}
+}
+
+func TestPruneArticlesInBatches(t *testing.T) {
+	s := store.New(store.WithPruneBatchSize(3))
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	var old []models.Article
+	for i := range 50 {
+		old = append(old, models.Article{ID: fmt.Sprintf("old-%d", i), FeedID: "f1", PublishedAt: now.Add(-48 * time.Hour)})
+	}
+	s.SaveArticles(old)
+	s.SaveForLater("old-7")
+	before := s.Generation()
+
+	// Saves keep landing while the sweep runs between batches.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 50 {
+			s.SaveArticles([]models.Article{{ID: fmt.Sprintf("new-%d", i), FeedID: "f1", PublishedAt: now}})
+		}
+	}()
+	removed := s.PruneArticles(0, 24*time.Hour, now)
+	<-done
+
+	if removed != 49 {
+		t.Fatalf("expected 49 old articles pruned, removed %d", removed)
+	}
+	if s.Generation() == before {
+		t.Fatal("expected pruning to bump the generation")
+	}
+	for a := range s.Articles() {
+		if strings.HasPrefix(a.ID, "old-") && a.ID != "old-7" {
+			t.Fatalf("expected %s to be pruned", a.ID)
+		}
+	}
+	if n := s.ArticleCount(); n != 51 {
+		t.Fatalf("expected the saved article and 50 new ones to remain, got %d", n)
+	}
+}
+
+func TestMergeFeeds(t *testing.T) {
+	s := store.New(store.WithTitleDedup(24 * time.Hour))
+	primary := s.AddFeed("Primary", "https://example.com/feed")
+	dup := s.AddFeed("Duplicate", "https://example.com/old-feed")
+	other := s.AddFeed("Other", "https://other.example/feed")
+	pub := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s.SaveArticles([]models.Article{
+		{ID: "p1", FeedID: primary.ID, Title: "Shared", Link: "https://example.com/shared", PublishedAt: pub},
+		{ID: "d1", FeedID: dup.ID, Title: "Shared", Link: "https://example.com/shared", PublishedAt: pub},
+		{ID: "d2", FeedID: dup.ID, Title: "Only in duplicate", Link: "https://example.com/only", PublishedAt: pub},
+		{ID: "o1", FeedID: other.ID, Title: "Elsewhere", Link: "https://other.example/1", PublishedAt: pub},
+	})
+
+	moved, ok := s.MergeFeeds(primary.ID, dup.ID)
+	if !ok || moved != 1 {
+		t.Fatalf("expected 1 article moved, got %d (ok=%v)", moved, ok)
+	}
+	if _, ok := s.GetFeed(dup.ID); ok {
+		t.Fatal("expected the duplicate feed to be removed")
+	}
+	got, _ := s.QueryArticles(store.ArticleQuery{FeedIDs: []string{primary.ID}})
+	slices.SortFunc(got, func(a, b models.Article) int { return strings.Compare(a.ID, b.ID) })
+	if len(got) != 2 || got[0].ID != "d2" || got[1].ID != "p1" || got[0].FeedName != "Primary" {
+		t.Fatalf("expected p1 and d2 under the primary feed, got %+v", got)
+	}
+	if s.ArticleCount() != 3 {
+		t.Fatalf("expected the duplicate's shared-link article to be dropped, have %d articles", s.ArticleCount())
+	}
+
+	// The moved article now dedups by title within the primary feed.
+	if saved := s.SaveArticles([]models.Article{
+		{ID: "p2", FeedID: primary.ID, Title: "only in duplicate", Link: "https://example.com/only?utm=1", PublishedAt: pub},
+	}); saved != 0 {
+		t.Fatalf("expected the moved article to be indexed under the primary feed, saved %d", saved)
+	}
+
+	for _, ids := range [][2]string{{primary.ID, primary.ID}, {primary.ID, "missing"}, {"missing", other.ID}} {
+		if _, ok := s.MergeFeeds(ids[0], ids[1]); ok {
+			t.Fatalf("expected merging %v to fail", ids)
+		}
+	}
+	if _, ok := s.GetFeed(other.ID); !ok {
+		t.Fatal("a failed merge must not remove the duplicate")
+	}
+}
+
+func TestMergedArticlesAreNotSavedAgainByThePrimary(t *testing.T) {
+	s := store.New()
+	primary := s.AddFeed("Primary", "https://example.com/feed")
+	dup := s.AddFeed("Duplicate", "https://example.com/old-feed")
+
+	s.SaveArticles([]models.Article{
+		{ID: "dup-x", FeedID: dup.ID, GUID: "x", Link: "https://example.com/x"},
+		{ID: "dup-y", FeedID: dup.ID, GUID: "y", Link: "https://example.com/y"},
+	})
+	if moved, _ := s.MergeFeeds(primary.ID, dup.ID); moved != 2 {
+		t.Fatalf("expected 2 articles moved, got %d", moved)
+	}
+
+	// The primary's next fetch hashes the same items from its own feed ID;
+	// one matches by GUID though its link changed, one by link.
+	saved := s.SaveArticles([]models.Article{
+		{ID: "primary-x", FeedID: primary.ID, GUID: "x", Link: "https://example.com/x?utm=1"},
+		{ID: "primary-y", FeedID: primary.ID, Link: "https://example.com/y"},
+		{ID: "primary-z", FeedID: primary.ID, GUID: "z", Link: "https://example.com/z"},
+	})
+	if saved != 1 {
+		t.Fatalf("expected only the new item saved, saved %d", saved)
+	}
+	if n := s.FeedArticleCount(primary.ID); n != 3 {
+		t.Fatalf("expected 3 articles under the primary feed, got %d", n)
+	}
+
+	// Once the moved article is gone, the item may be saved again.
+	s.DeleteArticle("dup-x")
+	if saved := s.SaveArticles([]models.Article{{ID: "primary-x", FeedID: primary.ID, GUID: "x"}}); saved != 1 {
+		t.Fatalf("expected the item saved after its moved copy was deleted, saved %d", saved)
+	}
+}
+
+func TestSetPriorities(t *testing.T) {
+	s := store.New()
+	a := s.AddFeed("A", "https://example.com/a")
+	b := s.AddFeed("B", "https://example.com/b")
+	c := s.AddFeed("C", "https://example.com/c")
+
+	feeds, err := s.SetPriorities(map[string]int{a.ID: 1, b.ID: 7, c.ID: 7})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, f := range feeds {
+		names = append(names, f.Name)
+	}
+	if want := []string{"B", "C", "A"}; !slices.Equal(names, want) {
+		t.Fatalf("expected fetch order %v, got %v", want, names)
+	}
+
+	if _, err := s.SetPriorities(map[string]int{a.ID: 100, "missing": 1}); !errors.Is(err, store.ErrFeedNotFound) {
+		t.Fatalf("expected ErrFeedNotFound, got %v", err)
+	}
+	if got, _ := s.GetFeed(a.ID); got.Priority != 1 {
+		t.Fatalf("expected a failed update to change nothing, priority is %d", got.Priority)
+	}
+}
+
+func TestCompactIndexes(t *testing.T) {
+	s := store.New(store.WithTitleDedup(24*time.Hour), store.WithMaxArticlesPerFeed(10))
+	pub := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var articles []models.Article
+	for i := range 4 {
+		articles = append(articles, models.Article{ID: fmt.Sprint("a", i), FeedID: "f1", Title: fmt.Sprint("Story ", i), PublishedAt: pub})
+	}
+	s.SaveArticles(articles)
+	s.DeleteArticle("a0")
+	s.DeleteArticle("a1")
+
+	// Each deleted article leaves a title entry and a cap entry behind.
+	if removed := s.CompactIndexes(); removed != 4 {
+		t.Fatalf("expected 4 stale entries removed, got %d", removed)
+	}
+	if removed := s.CompactIndexes(); removed != 0 {
+		t.Fatalf("expected nothing left to compact, got %d", removed)
+	}
+
+	// Surviving entries still dedup; compacted titles can be saved again.
+	saved := s.SaveArticles([]models.Article{
+		{ID: "b2", FeedID: "f1", Title: "Story 2", PublishedAt: pub.Add(time.Hour)},
+		{ID: "b0", FeedID: "f1", Title: "Story 0", PublishedAt: pub.Add(time.Hour)},
+	})
+	if saved != 1 {
+		t.Fatalf("expected only the compacted title to be saved again, saved %d", saved)
+	}
+
+	// ClearArticles resets the cap index itself, leaving 3 titles.
+	s.ClearArticles()
+	if removed := s.CompactIndexes(); removed != 3 {
+		t.Fatalf("expected 3 title entries removed after clearing, got %d", removed)
+	}
+}
+
+func TestSaveArticlesAssignsSeq(t *testing.T) {
+	s := store.New()
+	pub := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s.SaveArticles([]models.Article{{ID: "a", PublishedAt: pub}, {ID: "b", PublishedAt: pub}})
+	s.SaveArticles([]models.Article{{ID: "a", PublishedAt: pub}, {ID: "c", PublishedAt: pub}})
+
+	page, _ := s.QueryArticles(store.ArticleQuery{Sort: store.SortSeqAsc})
+	if len(page) != 3 || page[2].ID != "c" {
+		t.Fatalf("expected c to be saved last, got %+v", page)
+	}
+	for i, a := range page {
+		if a.Seq != int64(i+1) {
+			t.Fatalf("expected seq %d at position %d, got %d", i+1, i, a.Seq)
+		}
+	}
+
+	// Seqs survive deletion, so a cursor stays valid.
+	s.DeleteArticle("a")
+	s.SaveArticles([]models.Article{{ID: "d", PublishedAt: pub}})
+	page, _ = s.QueryArticles(store.ArticleQuery{AfterSeq: 2, Sort: store.SortSeqAsc})
+	if len(page) != 2 || page[0].ID != "c" || page[1].ID != "d" || page[1].Seq != 4 {
+		t.Fatalf("expected c then d after seq 2, got %+v", page)
+	}
+}
+
+func TestAfterSeqPollingSkipsNothingDuringSaves(t *testing.T) {
+	const writers, perWriter, batchSize = 4, 50, 8
+	s := store.New()
+	total := writers * perWriter * batchSize
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWriter {
+				batch := make([]models.Article, batchSize)
+				for j := range batch {
+					batch[j] = models.Article{ID: fmt.Sprintf("w%d-%d-%d", w, i, j), FeedID: "f1"}
+				}
+				s.SaveArticles(batch)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var cursor int64
+	for finished := false; !finished || cursor < int64(total); {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		page, _ := s.QueryArticles(store.ArticleQuery{AfterSeq: cursor, Sort: store.SortSeqAsc})
+		for _, a := range page {
+			if a.Seq != cursor+1 {
+				t.Fatalf("expected seq %d after cursor %d, got %d", cursor+1, cursor, a.Seq)
+			}
+			cursor = a.Seq
+		}
+		if finished && len(page) == 0 && cursor < int64(total) {
+			t.Fatalf("expected %d articles, polling stopped at seq %d", total, cursor)
+		}
+	}
+}
+
+func TestSaveForLaterQueue(t *testing.T) {
+	s := store.New()
+	s.SaveArticles([]models.Article{{ID: "a"}, {ID: "b"}, {ID: "c"}})
+
+	if s.SaveForLater("missing") {
+		t.Fatal("expected false for an unknown article")
+	}
+	for _, id := range []string{"b", "a", "c"} {
+		s.SaveForLater(id)
+		time.Sleep(time.Millisecond)
+	}
+	first := s.ListArticles("", 0)
+	s.SaveForLater("b") // already queued: keeps its place
+
+	page, total := s.QueryArticles(store.ArticleQuery{Saved: true, Sort: store.SortSavedDesc})
+	if total != 3 {
+		t.Fatalf("expected 3 queued articles, got %d", total)
+	}
+	if got := []string{page[0].ID, page[1].ID, page[2].ID}; !slices.Equal(got, []string{"c", "a", "b"}) {
+		t.Fatalf("expected most recently saved first, got %v", got)
+	}
+
+	// A re-fetch of a queued article must not drop it from the queue.
+	s.SaveArticles(first)
+	s.RemoveFromSaveLater("a")
+	if _, total := s.QueryArticles(store.ArticleQuery{Saved: true}); total != 2 {
+		t.Fatalf("expected 2 queued articles after removing one, got %d", total)
+	}
+}
+
+func TestSaveArticlesUpdatesContentWhenEnabled(t *testing.T) {
+	original := models.Article{ID: "a", FeedID: "f1", Title: "Go 1.22 Relased", Link: "https://example.com/go"}
+	corrected := original
+	corrected.Title = "Go 1.22 Released"
+
+	s := store.New(store.WithArticleUpdates(true))
+	s.SaveArticles([]models.Article{original})
+	s.SaveForLater("a")
+
+	if saved := s.SaveArticles([]models.Article{corrected}); saved != 0 {
+		t.Fatalf("expected an update not to count as saved, got %d", saved)
+	}
+	got := s.ListArticles("f1", 0)
+	if len(got) != 1 || got[0].Title != corrected.Title {
+		t.Fatalf("expected the corrected title, got %+v", got)
+	}
+	if got[0].SavedAt.IsZero() || got[0].Seq != 1 {
+		t.Fatalf("expected read-later state and seq kept, got %+v", got[0])
+	}
+
+	// By default the first version is kept.
+	s = store.New()
+	s.SaveArticles([]models.Article{original})
+	s.SaveArticles([]models.Article{corrected})
+	if got := s.ListArticles("f1", 0); got[0].Title != original.Title {
+		t.Fatalf("expected the original title without WithArticleUpdates, got %q", got[0].Title)
+	}
+}
+
+func TestConcurrentSaveArticles(t *testing.T) {
+	s := store.New(store.WithShards(8))
+
+	const writers, perWriter = 20, 200
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			batch := make([]models.Article, perWriter)
+			for i := range batch {
+				batch[i] = models.Article{ID: fmt.Sprintf("w%d-a%d", w, i), FeedID: fmt.Sprintf("f%d", w)}
+			}
+			s.SaveArticles(batch)
+		}(w)
+	}
+	wg.Wait()
+
+	if got := len(s.ListArticles("", 0)); got != writers*perWriter {
+		t.Fatalf("expected %d articles, got %d", writers*perWriter, got)
+	}
+
+	if got := len(s.ListArticles("f3", 0)); got != perWriter {
+		t.Fatalf("expected %d articles for f3, got %d", perWriter, got)
+	}
+}
+
+func TestConcurrentSaveArticlesOverlapping(t *testing.T) {
+	s := store.New()
+
+	const pool, writers, window = 500, 32, 200
+
+	var wg sync.WaitGroup
+	var totalNew atomic.Int64
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// Each writer saves a sliding window of the shared pool so
+			// neighbouring writers overlap heavily.
+			batch := make([]models.Article, window)
+			for i := range batch {
+				batch[i] = models.Article{ID: fmt.Sprintf("a%d", (w*25+i)%pool)}
+			}
+			totalNew.Add(int64(s.SaveArticles(batch)))
+		}(w)
+	}
+	wg.Wait()
+
+	distinct := len(s.ListArticles("", 0))
+	if distinct != pool {
+		t.Fatalf("expected %d distinct articles, got %d", pool, distinct)
+	}
+	if int(totalNew.Load()) != distinct {
+		t.Fatalf("summed new counts %d do not match distinct articles %d", totalNew.Load(), distinct)
+	}
+}
+
+func TestArticlesIteratorWithConcurrentWrites(t *testing.T) {
+	s := store.New()
+	const existing = 1000
+	batch := make([]models.Article, existing)
+	for i := range batch {
+		batch[i] = models.Article{ID: fmt.Sprintf("old%d", i), FeedID: "f1"}
+	}
+	s.SaveArticles(batch)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			s.SaveArticles([]models.Article{{ID: fmt.Sprintf("new%d", i), FeedID: "f2"}})
+		}
+	}()
+
+	seen := make(map[string]bool)
+	for a := range s.Articles() {
+		if seen[a.ID] {
+			t.Fatalf("article %s yielded twice", a.ID)
+		}
+		seen[a.ID] = true
+		if strings.HasPrefix(a.ID, "old") && a.FeedID != "f1" {
+			t.Fatalf("corrupted article %+v", a)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	for i := 0; i < existing; i++ {
+		if !seen[fmt.Sprintf("old%d", i)] {
+			t.Fatalf("article old%d present before iteration was not yielded", i)
+		}
+	}
+}
+
+func TestArticlesIteratorStopsEarly(t *testing.T) {
+	s := store.New()
+	s.SaveArticles([]models.Article{{ID: "a"}, {ID: "b"}, {ID: "c"}})
+
+	n := 0
+	for range s.Articles() {
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Fatalf("expected iteration to stop after 2, got %d", n)
+	}
+}
+
+// BenchmarkFullRead compares a full ListArticles copy with the Articles
+// iterator while a writer saves concurrently. max-write-wait-ns is the
+// longest a single SaveArticles call was blocked during the reads.
+func BenchmarkFullRead(b *testing.B) {
+	const n = 100_000
+	reads := []struct {
+		name string
+		read func(s *store.Store)
+	}{
+		{"ListArticles", func(s *store.Store) {
+			for range s.ListArticles("", 0) {
+			}
+		}},
+		{"Articles", func(s *store.Store) {
+			for range s.Articles() {
+			}
+		}},
+	}
+
+	for _, r := range reads {
+		b.Run(r.name, func(b *testing.B) {
+			s := store.New()
+			batch := make([]models.Article, n)
+			for i := range batch {
+				batch[i] = models.Article{ID: fmt.Sprintf("a%d", i), FeedID: "f1"}
+			}
+			s.SaveArticles(batch)
+
+			stop := make(chan struct{})
+			var maxWait atomic.Int64
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; ; i++ {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					start := time.Now()
+					s.SaveArticles([]models.Article{{ID: fmt.Sprintf("w%d", i%1000), FeedID: "f2"}})
+					if d := int64(time.Since(start)); d > maxWait.Load() {
+						maxWait.Store(d)
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.read(s)
+			}
+			b.StopTimer()
+			close(stop)
+			wg.Wait()
+			b.ReportMetric(float64(maxWait.Load()), "max-write-wait-ns")
+		})
+	}
+}
+
+func BenchmarkSaveArticlesBatch(b *testing.B) {
+	const feeds, perFeed = 200, 20
+	batchFor := func(round int) map[string][]models.Article {
+		batch := make(map[string][]models.Article, feeds)
+		for f := range feeds {
+			feedID := fmt.Sprintf("f%d", f)
+			for i := range perFeed {
+				batch[feedID] = append(batch[feedID], models.Article{ID: fmt.Sprintf("%s-%d-%d", feedID, round, i), FeedID: feedID})
+			}
+		}
+		return batch
+	}
+
+	b.Run("per-feed", func(b *testing.B) {
+		s := store.New()
+		for n := 0; n < b.N; n++ {
+			for _, articles := range batchFor(n) {
+				s.SaveArticles(articles)
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		s := store.New()
+		for n := 0; n < b.N; n++ {
+			s.SaveArticlesBatch(batchFor(n))
+		}
+	})
+}
+
+// BenchmarkSaveArticlesParallel saves from one feed per goroutine. Without
+// a cap, saves only contend on shard locks; with one they also share the
+// cap index.
+func BenchmarkSaveArticlesParallel(b *testing.B) {
+	for _, shards := range []int{1, store.DefaultShards} {
+		for _, limit := range []int{0, 100} {
+			b.Run(fmt.Sprintf("shards=%d/cap=%d", shards, limit), func(b *testing.B) {
+				benchmarkSaveParallel(b, store.New(store.WithShards(shards), store.WithMaxArticlesPerFeed(limit)))
+			})
+		}
+	}
+}
+
+func benchmarkSaveParallel(b *testing.B, s *store.Store) {
+	var mu sync.Mutex
+	next := 0
+
+	b.RunParallel(func(pb *testing.PB) {
+		mu.Lock()
+		feed := next
+		next++
+		mu.Unlock()
+
+		i := 0
+		for pb.Next() {
+			s.SaveArticles([]models.Article{
+				{ID: fmt.Sprintf("f%d-a%d", feed, i), FeedID: fmt.Sprintf("f%d", feed)},
+			})
+			i++
+		}
+	})
+}
+
+// BenchmarkPruneArticles compares deleting every pruned article under one
+// lock acquisition with the default batch size while a writer saves
+// concurrently. max-write-wait-ns is the longest a single
+// SaveArticles call was blocked during the sweep.
+func BenchmarkPruneArticles(b *testing.B) {
+	const n = 100_000
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := make([]models.Article, n)
+	for i := range old {
+		old[i] = models.Article{ID: fmt.Sprintf("a%d", i), FeedID: "f1", PublishedAt: now.Add(-48 * time.Hour)}
+	}
+
+	for _, size := range []int{n, store.DefaultPruneBatchSize} {
+		b.Run(fmt.Sprintf("batch=%d", size), func(b *testing.B) {
+			s := store.New(store.WithPruneBatchSize(size))
+
+			stop := make(chan struct{})
+			var pruning atomic.Bool // only saves started mid-sweep are timed
+			var maxWait atomic.Int64
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; ; i++ {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					timed := pruning.Load()
+					start := time.Now()
+					s.SaveArticles([]models.Article{{ID: fmt.Sprintf("w%d", i%1000), FeedID: "f2"}})
+					if d := int64(time.Since(start)); timed && d > maxWait.Load() {
+						maxWait.Store(d)
+					}
+				}
+			}()
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				s.SaveArticles(old)
+				pruning.Store(true)
+				b.StartTimer()
+				s.PruneArticles(0, 24*time.Hour, now)
+				b.StopTimer()
+				pruning.Store(false)
+				b.StartTimer()
+			}
+			b.StopTimer()
+			close(stop)
+			wg.Wait()
+			b.ReportMetric(float64(maxWait.Load()), "max-write-wait-ns")
+		})
+	}
+}
+
+func TestTrending(t *testing.T) {
+	s := store.New()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+	s.SaveArticles([]models.Article{
+		{ID: "busy-1h", FeedID: "busy", PublishedAt: ago(time.Hour)},
+		{ID: "busy-2h", FeedID: "busy", PublishedAt: ago(2 * time.Hour)},
+		{ID: "busy-3h", FeedID: "busy", PublishedAt: ago(3 * time.Hour)},
+		{ID: "busy-5h", FeedID: "busy", PublishedAt: ago(5 * time.Hour)},
+		{ID: "busy-7h", FeedID: "busy", PublishedAt: ago(7 * time.Hour)},
+		{ID: "quiet-10m", FeedID: "quiet", PublishedAt: ago(10 * time.Minute)},
+		{ID: "future", FeedID: "quiet", PublishedAt: now.Add(time.Hour)},
+	})
+
+	ids := func(articles []models.Article) []string {
+		var out []string
+		for _, a := range articles {
+			out = append(out, a.ID)
+		}
+		return out
+	}
+
+	// The busy feed's newest items outrank the quiet feed's fresher one,
+	// but its older items do not.
+	got := ids(s.Trending(6*time.Hour, now, 0))
+	if want := []string{"busy-1h", "busy-2h", "quiet-10m", "busy-3h", "busy-5h"}; !slices.Equal(got, want) {
+		t.Fatalf("6h window: expected %v, got %v", want, got)
+	}
+
+	// In a narrower window both feeds are equally active, so recency wins.
+	got = ids(s.Trending(90*time.Minute, now, 0))
+	if want := []string{"quiet-10m", "busy-1h"}; !slices.Equal(got, want) {
+		t.Fatalf("90m window: expected %v, got %v", want, got)
+	}
+
+	if got := s.Trending(6*time.Hour, now, 2); len(got) != 2 || got[0].ID != "busy-1h" {
+		t.Fatalf("expected the top 2, got %v", ids(got))
+	}
+}
+
+func TestArticleCapHoldsAfterSave(t *testing.T) {
+	s := store.New(store.WithMaxArticlesPerFeed(5))
+	capped := s.AddFeed("Capped", "https://example.com/capped")
+	own, err := s.CreateFeed(models.Feed{Name: "Own cap", URL: "https://example.com/own", MaxArticles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := func(feedID string, from, n int) []models.Article {
+		articles := make([]models.Article, n)
+		for i := range articles {
+			articles[i] = models.Article{
+				ID:          fmt.Sprintf("%s-%d", feedID, from+i),
+				FeedID:      feedID,
+				PublishedAt: time.Unix(int64(from+i), 0),
+			}
+		}
+		return articles
+	}
+
+	if saved := s.SaveArticles(batch(capped.ID, 0, 3)); saved != 3 {
+		t.Fatalf("expected 3 saved under the cap, got %d", saved)
+	}
+	// Queued to read later, so never evicted and not counted.
+	s.SaveForLater(capped.ID + "-0")
+
+	if saved := s.SaveArticles(batch(capped.ID, 3, 100)); saved != 5 {
+		t.Fatalf("expected only the 5 newest of the batch kept, got %d", saved)
+	}
+	articles := s.ListArticles(capped.ID, 0)
+	if len(articles) != 6 {
+		t.Fatalf("expected 5 articles plus the saved one, got %d", len(articles))
+	}
+	if articles[0].ID != capped.ID+"-102" || articles[4].ID != capped.ID+"-98" || articles[5].ID != capped.ID+"-0" {
+		t.Fatalf("expected the newest 5 and the saved article, got %s..%s, %s", articles[0].ID, articles[4].ID, articles[5].ID)
+	}
+
+	s.SaveArticles(batch(own.ID, 0, 10))
+	if n := len(s.ListArticles(own.ID, 0)); n != 2 {
+		t.Fatalf("expected the feed's own cap of 2, got %d", n)
+	}
+
+	// Raising the feed's cap lets it grow; lowering it trims on the next save.
+	one := 1
+	s.UpdateFeed(own.ID, models.UpdateFeedRequest{MaxArticles: &one})
+	s.SaveArticles(batch(own.ID, 10, 1))
+	if got := s.ListArticles(own.ID, 0); len(got) != 1 || got[0].ID != own.ID+"-10" {
+		t.Fatalf("expected only the newest article after lowering the cap, got %+v", got)
+	}
+}
+
+func TestArticleCapHoldsUnderConcurrentSaves(t *testing.T) {
+	const limit, writers, perWriter = 10, 8, 50
+	s := store.New()
+	capped, err := s.CreateFeed(models.Feed{Name: "Capped", URL: "https://example.com/capped", MaxArticles: limit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := s.AddFeed("Open", "https://example.com/open")
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWriter {
+				n := w*perWriter + i
+				s.SaveArticles([]models.Article{
+					{ID: fmt.Sprintf("c%d", n), FeedID: capped.ID, PublishedAt: time.Unix(int64(n), 0)},
+					{ID: fmt.Sprintf("o%d", n), FeedID: open.ID, PublishedAt: time.Unix(int64(n), 0)},
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(s.ListArticles(capped.ID, 0)); n != limit {
+		t.Fatalf("expected the capped feed to hold %d articles, got %d", limit, n)
+	}
+	if n := len(s.ListArticles(open.ID, 0)); n != writers*perWriter {
+		t.Fatalf("expected every article of the uncapped feed kept, got %d", n)
+	}
+}
+
+func TestFeedHealthScore(t *testing.T) {
+	s := store.New()
+	steady := s.AddFeed("Steady", "https://example.com/steady")
+	flaky := s.AddFeed("Flaky", "https://example.com/flaky")
+	failing := s.AddFeed("Failing", "https://example.com/failing")
+	stale := s.AddFeed("Stale", "https://example.com/stale")
+	s.AddFeed("Fresh", "https://example.com/fresh") // never fetched
+
+	ok := models.FetchEvent{}
+	fail := models.FetchEvent{Error: "boom"}
+	record := func(feedID string, events ...models.FetchEvent) {
+		for _, ev := range events {
+			s.RecordFetch(feedID, ev)
+		}
+	}
+	record(steady.ID, ok, ok, ok, ok)
+	record(flaky.ID, ok, fail, ok, ok)
+	record(failing.ID, ok, ok, fail, fail) // two failures in a row, most recent last
+	record(stale.ID, ok, ok, ok, ok)
+	s.UpdateLastNewArticle(stale.ID, time.Now().Add(-60*24*time.Hour))
+
+	scores := make(map[string]float64)
+	for _, f := range s.ListFeeds() {
+		scores[f.Name] = f.HealthScore
+	}
+	if scores["Steady"] != 1 || scores["Fresh"] != 1 {
+		t.Fatalf("expected healthy and unfetched feeds to score 1, got %v", scores)
+	}
+	if !(scores["Failing"] < scores["Flaky"] && scores["Flaky"] < scores["Steady"]) {
+		t.Fatalf("expected Failing < Flaky < Steady, got %v", scores)
+	}
+	if scores["Stale"] != 0.5 {
+		t.Fatalf("expected a long-stale feed to lose half its score, got %v", scores["Stale"])
+	}
+
+	page, _ := s.ListFeedsPage(store.FeedQuery{Sort: store.FeedSortHealthAsc})
+	var order []string
+	for _, f := range page {
+		order = append(order, f.Name)
+	}
+	if want := []string{"Failing", "Stale", "Flaky", "Fresh", "Steady"}; !slices.Equal(order, want) {
+		t.Fatalf("expected %v least healthy first, got %v", want, order)
+	}
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/raffaelramalhorosa/rss-aggregator/internal/models"
+)
+
+// Trending returns up to limit articles published within window before now,
+// best first. An article scores higher the newer it is and the more its
+// feed has published in the window, so busy feeds surface first. A limit
+// of 0 means no limit.
+func (s *Store) Trending(window time.Duration, now time.Time, limit int) []models.Article {
+	since := now.Add(-window)
+
+	var recent []models.Article
+	perFeed := make(map[string]int)
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, a := range sh.articles {
+			if a.PublishedAt.After(since) && !a.PublishedAt.After(now) {
+				recent = append(recent, a)
+				perFeed[a.FeedID]++
+			}
+		}
+		sh.mu.RUnlock()
+	}
+
+	busiest := 0
+	for _, n := range perFeed {
+		busiest = max(busiest, n)
+	}
+
+	scores := make(map[string]float64, len(recent))
+	for _, a := range recent {
+		scores[a.ID] = trendingScore(now.Sub(a.PublishedAt), window, perFeed[a.FeedID], busiest)
+	}
+	slices.SortFunc(recent, func(a, b models.Article) int {
+		return cmp.Or(cmp.Compare(scores[b.ID], scores[a.ID]), compareArticles(a, b, SortPublishedDesc))
+	})
+
+	if limit > 0 && len(recent) > limit {
+		recent = recent[:limit]
+	}
+	return recent
+}
+
+// trendingScore combines recency, from 1 for a brand-new article down to 0
+// at the edge of the window, with the feed's share of the busiest feed's
+// output. Activity can at most double an article's score, so a fresh item
+// from a quiet feed still beats a stale one from a busy feed.
+func trendingScore(age, window time.Duration, feedCount, busiest int) float64 {
+	recency := 1 - float64(age)/float64(window)
+	activity := float64(feedCount) / float64(busiest)
+	return recency * (1 + activity)
+}
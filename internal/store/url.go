@@ -0,0 +1,31 @@
+package store
+
+import (
+	"net/url"
+	"strings"
+)
+
+// NormalizeURL reduces a feed URL to a canonical form so that trivially
+// different spellings of the same feed compare equal: the scheme and host
+// are lowercased, default ports, fragments and trailing slashes are dropped.
+// Unparseable input is returned trimmed but otherwise unchanged.
+func NormalizeURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+
+	return u.String()
+}